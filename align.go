@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// alignPrompt asks for the script to be timed against the audio rather
+// than for a fresh transcript. The script follows it.
+const alignPrompt = `Below is the script of this audio: the text that is spoken in it. Align the script to the audio instead of transcribing from scratch. Use the script's words, spelling and punctuation exactly; don't correct, paraphrase or add to it. Leave out parts of the script that aren't spoken in the audio (the audio may be only a part of it) and don't add speech that isn't in the script.
+
+Script:
+`
+
+// alignFlags are the align command's flag values.
+type alignFlags struct {
+	outputJSON bool
+	noCache    bool
+	opts       options
+	g          globalFlags
+}
+
+// flagSet returns the align command's flags, bound to v.
+func (v *alignFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("align", flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Language, "language", "", "Spoken language of the audio, as a hint (e.g. German, pt)")
+	fs.StringVar(&v.opts.format, "f", "srt", "Output format: srt, vtt, json")
+	fs.StringVar(&v.opts.format, "format", "srt", "Output format: srt, vtt, json")
+	fs.BoolVar(&v.outputJSON, "json", false, "Output as JSON (same as --format json)")
+	fs.StringVar(&v.opts.output, "o", "", "Write the subtitles to this file instead of stdout")
+	fs.StringVar(&v.opts.output, "output", "", "Write the subtitles to this file instead of stdout")
+	fs.BoolVar(&v.noCache, "no-cache", false, "Always call the API instead of reusing a cached alignment")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe align [options] <audio> <transcript>\n\n")
+		fmt.Fprintf(os.Stderr, "Times an existing transcript (a script, an article read aloud) against the audio\n")
+		fmt.Fprintf(os.Stderr, "and outputs it as subtitles, keeping its wording.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runAlign implements the align subcommand.
+func runAlign(args []string) {
+	var v alignFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	opts, g := v.opts, v.g
+	g.initLogging(false)
+	opts.verbose = g.verbose
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	input, scriptFile := fs.Arg(0), fs.Arg(1)
+	if v.outputJSON {
+		opts.format = "json"
+	}
+	if opts.format != "srt" && opts.format != "vtt" && opts.format != "json" {
+		errorf("Error: Unknown format %q (want srt, vtt or json)", opts.format)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(input); err != nil && !isURL(input) && !isObject(input) {
+		errorf("Error: File not found: %s", input)
+		os.Exit(exitNotFound)
+	}
+	data, err := os.ReadFile(scriptFile)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(exitNotFound)
+	}
+	script := strings.TrimSpace(string(data))
+	if script == "" {
+		errorf("Error: %s is empty", scriptFile)
+		os.Exit(1)
+	}
+
+	opts.Prompt = transcribe.DefaultPrompt + "\n\n" + alignPrompt + script
+	opts.Timestamps = true
+	opts.Upload = "auto"
+	opts.ChunkDuration = transcribe.DefaultChunkDuration
+	opts.ChunkOverlap = transcribe.DefaultChunkOverlap
+	opts.MaxContinuations = transcribe.DefaultMaxContinuations
+
+	client := g.newClient()
+	if !v.noCache {
+		client.Cache = newCache()
+	}
+	if opts.verbose {
+		client.Logf = debugf
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := transcribeFile(ctx, client, input, opts)
+	if err != nil {
+		_, code := errorClass(err)
+		errorf("Error %v", err)
+		os.Exit(code)
+	}
+	if got, want := len(strings.Fields(result.Transcription)), len(strings.Fields(script)); got < want*9/10 {
+		warnf("Warning: only %d of the script's %d words were aligned; the audio may not match it", got, want)
+	}
+	debugf("%s", client.Stats.Snapshot().Summary())
+
+	out := renderResult(result, opts)
+	if opts.output == "" {
+		fmt.Print(out)
+		return
+	}
+	if err := writeOutput(opts.output, []byte(out)); err != nil {
+		errorf("Error writing output: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/websocket"
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// clientToken is one client allowed to use the server, from --tokens.
+type clientToken struct {
+	name  string
+	token string
+}
+
+// loadTokens reads a --tokens file: one client per line, its name and its
+// token separated by whitespace. Blank lines and # comments are skipped.
+func loadTokens(path string) ([]clientToken, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tokens []clientToken
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a name and a token", path, n)
+		}
+		if seen[fields[1]] {
+			return nil, fmt.Errorf("%s:%d: token already given to another client", path, n)
+		}
+		seen[fields[1]] = true
+		tokens = append(tokens, clientToken{name: fields[0], token: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return tokens, nil
+}
+
+type (
+	principalKey struct{}
+	callerKeyKey struct{}
+)
+
+// principal returns the name of the client a request was authenticated
+// as, or "" when the server has no --tokens.
+func principal(ctx context.Context) string {
+	name, _ := ctx.Value(principalKey{}).(string)
+	return name
+}
+
+// withCallerKey carries a caller's own Gemini API key to the
+// transcription.
+func withCallerKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, callerKeyKey{}, key)
+}
+
+func callerKey(ctx context.Context) string {
+	key, _ := ctx.Value(callerKeyKey{}).(string)
+	return key
+}
+
+// clientFor returns a copy of the server's client for one transcription,
+// counting its own usage and using the caller's Gemini key when the
+// request brought one.
+func (s *server) clientFor(ctx context.Context) *transcribe.Client {
+	client := *s.client
+	client.Stats = transcribe.NewStats()
+	if key := callerKey(ctx); key != "" {
+		client.APIKey, client.Keys = key, nil
+	}
+	return &client
+}
+
+// authenticate returns the client a request's token belongs to. Tokens
+// come as "Authorization: Bearer <token>" or "X-API-Key: <token>", and
+// for WebSockets, which browsers can't add headers to, as ?token=.
+func (s *server) authenticate(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.Header.Get("X-API-Key")
+	}
+	if token == "" && websocket.IsUpgrade(r) {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return "", false
+	}
+	// Compare with every token, in constant time, so timing doesn't
+	// reveal how close a guess was
+	name, found := "", false
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.token)) == 1 {
+			name, found = t.name, true
+		}
+	}
+	return name, found
+}
+
+// withAuth requires a valid token on every request when the server has
+// --tokens, and logs each request with the client that made it. The web
+// UI's page (/) and /healthz are served without a token, so a browser
+// can load the page and a load balancer can probe the server. It also
+// accepts the caller's Gemini key in X-Gemini-Api-Key with
+// --allow-client-keys.
+func (s *server) withAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		grpc := strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+		if key := r.Header.Get("X-Gemini-Api-Key"); key != "" {
+			if !s.allowClientKeys {
+				rejectRequest(w, grpc, http.StatusBadRequest, "this server doesn't accept X-Gemini-Api-Key (see --allow-client-keys)")
+				return
+			}
+			ctx = withCallerKey(ctx, key)
+		}
+		if s.tokens == nil {
+			h.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		name, ok := s.authenticate(r)
+		if !ok {
+			warnf("Rejected %s %s from %s: missing or unknown token", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gemini-transcribe"`)
+			rejectRequest(w, grpc, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r.WithContext(context.WithValue(ctx, principalKey{}, name)))
+		if rec.status == 0 {
+			// Taken over by a WebSocket
+			rec.status = http.StatusSwitchingProtocols
+		}
+		infof("%s: %s %s %d in %s", name, r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// rejectRequest answers a request that didn't get past withAuth, as a
+// gRPC status for gRPC calls.
+func rejectRequest(w http.ResponseWriter, grpc bool, status int, msg string) {
+	if !grpc {
+		writeJSONError(w, status, msg)
+		return
+	}
+	code := grpcInvalidArgument
+	if status == http.StatusUnauthorized {
+		code = grpcUnauthenticated
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	writeGRPCStatus(w, code, msg)
+}
+
+// isLoopback reports whether a listen address only accepts connections
+// from this machine.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// statusRecorder notes the status code a handler answered with. Unwrap
+// lets http.ResponseController reach the connection underneath, to flush
+// or take it over.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
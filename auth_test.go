@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTokens(t *testing.T) {
+	tests := []struct {
+		name, file, want string
+	}{
+		{"ok", "# clients\nanna  s3cret\n\nben\tother\n", "anna=s3cret,ben=other"},
+		{"one field", "anna\n", "tokens:1: want a name and a token"},
+		{"reused token", "anna x\nben x\n", "tokens:2: token already given to another client"},
+		{"empty", "# nobody\n", "no tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens")
+			os.WriteFile(path, []byte(tt.file), 0600)
+			tokens, err := loadTokens(path)
+			var got []string
+			for _, tok := range tokens {
+				got = append(got, tok.name+"="+tok.token)
+			}
+			if err != nil {
+				got = []string{err.Error()}
+			}
+			if s := strings.Join(got, ","); !strings.HasSuffix(s, tt.want) {
+				t.Errorf("loadTokens() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	// The handler answers with who the request came from and its key
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, principal(r.Context())+"|"+callerKey(r.Context()))
+	})
+	tokens := []clientToken{{"anna", "tok-a"}, {"ben", "tok-b"}}
+	tests := []struct {
+		name       string
+		tokens     []clientToken
+		clientKeys bool
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{"open server", nil, false, "/transcribe", nil, 200, "|"},
+		{"bearer token", tokens, false, "/transcribe", map[string]string{"Authorization": "Bearer tok-b"}, 200, "ben|"},
+		{"api key header", tokens, false, "/jobs", map[string]string{"X-API-Key": "tok-a"}, 200, "anna|"},
+		{"no token", tokens, false, "/transcribe", nil, 401, "missing or invalid token"},
+		{"wrong token", tokens, false, "/transcribe", map[string]string{"Authorization": "Bearer tok-c"}, 401, "missing or invalid token"},
+		{"query token needs a websocket", tokens, false, "/transcribe?token=tok-a", nil, 401, "missing or invalid token"},
+		{"health check open", tokens, false, "/healthz", nil, 200, "|"},
+		{"caller key", tokens, true, "/transcribe", map[string]string{"X-API-Key": "tok-a", "X-Gemini-Api-Key": "AIza-own"}, 200, "anna|AIza-own"},
+		{"caller key refused", nil, false, "/transcribe", map[string]string{"X-Gemini-Api-Key": "AIza-own"}, 400, "--allow-client-keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &server{tokens: tt.tokens, allowClientKeys: tt.clientKeys}
+			req := httptest.NewRequest("POST", tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			s.withAuth(echo).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{"localhost:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.168.1.2:8080", false},
+		{"nonsense", false},
+	}
+	for _, tt := range tests {
+		if got := isLoopback(tt.addr); got != tt.want {
+			t.Errorf("isLoopback(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// mediaExts lists the extensions picked up when a directory is given as input.
+var mediaExts = map[string]bool{
+	".mp3": true, ".wav": true, ".ogg": true, ".flac": true, ".m4a": true, ".aac": true,
+	".mp4": true, ".webm": true, ".mov": true, ".avi": true, ".mkv": true,
+}
+
+// isBatchInput reports whether the -i value plus any positional arguments
+// name more than a single plain file.
+func isBatchInput(input string, rest []string) bool {
+	if len(rest) > 0 {
+		return true
+	}
+	if isURL(input) {
+		return false
+	}
+	if isObject(input) {
+		// A bucket prefix, like a directory, ends in a slash
+		return strings.HasSuffix(input, "/")
+	}
+	if strings.ContainsAny(input, "*?[") {
+		return true
+	}
+	info, err := os.Stat(input)
+	return err == nil && info.IsDir()
+}
+
+// expandInputs resolves directories and bucket prefixes (non-recursively,
+// media files only) and glob patterns into a sorted, de-duplicated list of
+// files.
+func expandInputs(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if isURL(pattern) || isObject(pattern) && !strings.HasSuffix(pattern, "/") {
+			add(pattern)
+			continue
+		}
+		if isObject(pattern) {
+			objects, err := listObjects(context.Background(), pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range objects {
+				add(o)
+			}
+			continue
+		}
+		if info, err := os.Stat(pattern); err == nil {
+			if !info.IsDir() {
+				add(pattern)
+				continue
+			}
+			entries, err := os.ReadDir(pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				if !e.IsDir() && mediaExts[strings.ToLower(filepath.Ext(e.Name()))] {
+					add(filepath.Join(pattern, e.Name()))
+				}
+			}
+			continue
+		}
+
+		if !strings.ContainsAny(pattern, "*?[") {
+			return nil, notFoundError("file not found: " + pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, notFoundError("no files match " + pattern)
+		}
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				add(m)
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, notFoundError("no input files found")
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// batchOutputPath derives the output file for an input: same basename,
+// extension from the output format (lecture.mp3 -> lecture.srt), placed in
+// outDir or, when that is empty, next to the input. URLs are named after
+// the last path element and go to the current directory by default. Either
+// may be an s3:// or gs:// URI.
+func batchOutputPath(input, outDir, format string) string {
+	if isURL(input) {
+		base := urlBaseName(input)
+		return joinOutput(outDir, strings.TrimSuffix(base, filepath.Ext(base))+outputExt(format))
+	}
+	name := strings.TrimSuffix(input, filepath.Ext(input)) + outputExt(format)
+	if outDir == "" {
+		return name
+	}
+	return joinOutput(outDir, filepath.Base(name))
+}
+
+// checkOutputCollisions reports inputs that would write the same output
+// file, which happens when same-named files from different directories go
+// to one --output directory.
+func checkOutputCollisions(inputs []string, opts options) error {
+	owner := map[string]string{}
+	for _, input := range inputs {
+		out := opts.manifest.outputPath(input, opts.output, opts.format)
+		if prev, ok := owner[out]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", prev, input, out)
+		}
+		owner[out] = input
+	}
+	return nil
+}
+
+// checkSidecarInputs rejects --sidecar for URL inputs, which have no
+// directory to write next to.
+func checkSidecarInputs(inputs []string, opts options) error {
+	if !opts.sidecar {
+		return nil
+	}
+	for _, input := range inputs {
+		if isURL(input) {
+			return fmt.Errorf("--sidecar needs local files, but %s is a URL", input)
+		}
+	}
+	return nil
+}
+
+// outputExists reports whether every output file for input is already
+// there, so batch mode can skip it without --overwrite.
+func outputExists(input string, opts options) (string, bool) {
+	outPath := opts.manifest.outputPath(input, opts.output, opts.format)
+	for _, f := range opts.formatList() {
+		// The other formats go next to the first, as writeResult puts them
+		if !outputExistsAt(batchOutputPath(outPath, "", f)) {
+			return outPath, false
+		}
+	}
+	return outPath, true
+}
+
+// batchOutcome is what one batch item reports back to the summary.
+type batchOutcome struct {
+	outPath     string
+	silent      bool
+	skipped     bool
+	dryRun      bool
+	interrupted bool
+	err         error
+}
+
+// runBatch transcribes every input with up to opts.jobs files in flight,
+// writing one output file per input. Outcomes are reported in input order as
+// they become available, followed by a summary of successes, skips and
+// failures. It returns 0 when every file succeeded, otherwise the exit code
+// shared by all failures or exitError when they differ. Progress is kept in
+// a state file for --resume. Inputs finished by an earlier run (done maps
+// them to their outputs) are skipped, and so, without opts.overwrite, are
+// inputs whose output already exists. When ctx is cancelled no new files
+// are started and exitInterrupted is returned.
+func runBatch(ctx context.Context, client *transcribe.Client, inputs []string, opts options, failOnEmpty bool, done map[string]string) int {
+	statePath := batchStatePath(opts.output)
+	if opts.dryRun != nil || opts.manifest != nil || isObject(opts.output) {
+		// A manifest run keeps its progress in the results file instead,
+		// and running it again skips the finished rows, as it does for
+		// outputs in a bucket
+		statePath = ""
+	}
+	progress := newBatchProgress(statePath, inputs, done)
+	jobs := max(opts.jobs, 1)
+	outcomes := make([]chan batchOutcome, len(inputs))
+	for i := range outcomes {
+		outcomes[i] = make(chan batchOutcome, 1)
+	}
+
+	queue := make(chan int)
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range queue {
+				if ctx.Err() != nil {
+					outcomes[i] <- batchOutcome{interrupted: true}
+					continue
+				}
+				o := transcribeBatchItem(ctx, client, inputs[i], opts, failOnEmpty)
+				progress.finish(i, o)
+				outcomes[i] <- o
+			}
+		}()
+	}
+	go func() {
+		for i, input := range inputs {
+			if out, ok := done[input]; ok {
+				outcomes[i] <- batchOutcome{outPath: out, skipped: true}
+				continue
+			}
+			if out, ok := outputExists(input, opts); ok && !opts.overwrite {
+				o := batchOutcome{outPath: out, skipped: true}
+				progress.finish(i, o)
+				outcomes[i] <- o
+				continue
+			}
+			queue <- i
+		}
+		close(queue)
+	}()
+
+	var failed []string
+	succeeded, skipped, pending, exitCode := 0, 0, 0, 0
+	results := make([]batchOutcome, len(inputs))
+	for i, input := range inputs {
+		o := <-outcomes[i]
+		results[i] = o
+		switch {
+		case o.interrupted:
+			pending++
+		case o.err != nil:
+			reportError(opts, input, o.err, fmt.Sprintf("[%d/%d] %s: Error %v", i+1, len(inputs), input, o.err))
+			failed = append(failed, fmt.Sprintf("%s: %v", input, o.err))
+			if _, code := errorClass(o.err); exitCode == 0 || exitCode == code {
+				exitCode = code
+			} else {
+				exitCode = exitError
+			}
+		case o.dryRun:
+			succeeded++
+		case o.skipped:
+			debugf("[%d/%d] %s: already done -> %s", i+1, len(inputs), input, o.outPath)
+			skipped++
+		case o.silent:
+			infof("[%d/%d] %s: no speech detected -> %s", i+1, len(inputs), input, o.outPath)
+			succeeded++
+		default:
+			infof("[%d/%d] %s -> %s", i+1, len(inputs), input, o.outPath)
+			succeeded++
+		}
+	}
+
+	if opts.dryRun != nil {
+		opts.dryRun.summary()
+	} else if skipped < len(inputs) {
+		infof("\nTranscribed %d of %d files", succeeded, len(inputs)-skipped)
+	}
+	if skipped > 0 {
+		infof("Skipped %d already transcribed (--overwrite redoes them)", skipped)
+	}
+	if len(failed) > 0 {
+		errorf("Failed (%d):\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	if pending > 0 {
+		warnf("Interrupted with %d files left", pending)
+		exitCode = exitInterrupted
+	}
+	progress.close(pending+len(failed) == 0)
+	if opts.manifest != nil {
+		if err := opts.manifest.writeResults(results); err != nil {
+			warnf("Warning: can't write manifest results: %v", err)
+		} else {
+			infof("Results written to %s", opts.manifest.resultsPath())
+		}
+	}
+	debugf("%s", client.Stats.Snapshot().Summary())
+	if opts.showCost {
+		infof("%s", describeCost(opts.Model, client.Stats.Snapshot()))
+	}
+	return exitCode
+}
+
+// transcribeBatchItem transcribes one input and writes its output file.
+func transcribeBatchItem(ctx context.Context, client *transcribe.Client, input string, opts options, failOnEmpty bool) batchOutcome {
+	start := time.Now()
+	// Per-file stats feed the JSON meta; the run total is merged after.
+	fileClient := *client
+	fileClient.Stats = transcribe.NewStats()
+	opts = opts.manifest.apply(input, opts)
+	result, err := transcribeFile(ctx, &fileClient, input, opts)
+	snap := fileClient.Stats.Snapshot()
+	client.Stats.Merge(snap)
+
+	if ctx.Err() != nil {
+		return batchOutcome{interrupted: true}
+	}
+	if errors.Is(err, transcribe.ErrDryRun) {
+		return batchOutcome{dryRun: true}
+	}
+	silent := errors.Is(err, transcribe.ErrNoSpeech)
+	if silent && failOnEmpty || !silent && err != nil {
+		sendWebhook(opts, newWebhookPayload(result, "", snap, time.Since(start), err))
+		return batchOutcome{err: err}
+	}
+
+	result.Meta = &snap
+	result.Usage = usageFor(result.Model, snap, opts.showCost)
+	outPath := opts.manifest.outputPath(input, opts.output, opts.format)
+	werr := makeOutputDir(filepath.Dir(outPath))
+	if werr == nil {
+		werr = writeResult(result, outPath, opts)
+	}
+	if werr != nil {
+		err = fmt.Errorf("writing output: %v", werr)
+		sendWebhook(opts, newWebhookPayload(result, "", snap, time.Since(start), err))
+		return batchOutcome{err: err}
+	}
+	if err := updateInput(ctx, client, result, opts); err != nil {
+		sendWebhook(opts, newWebhookPayload(result, outPath, snap, time.Since(start), err))
+		return batchOutcome{err: err}
+	}
+	recordTranscript(opts, result)
+	sendWebhook(opts, newWebhookPayload(result, outPath, snap, time.Since(start), err))
+	return batchOutcome{outPath: outPath, silent: silent}
+}
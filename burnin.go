@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// hasVideo reports whether ffprobe finds a video stream in path, so
+// --burn-in can refuse audio before anything is transcribed.
+func hasVideo(ctx context.Context, ffprobe, path string) bool {
+	out, err := proc.Command(ctx, ffprobe, "-v", "error", "-select_streams", "v",
+		"-show_entries", "stream=codec_type", "-of", "csv=p=0", path).Output()
+	return err == nil && strings.Contains(string(out), "video")
+}
+
+// burnSubtitles re-encodes the video at input into output with the
+// segments drawn onto the picture as subtitles (ffmpeg's subtitles
+// filter, which needs libass). The audio is re-encoded for the output's
+// container too. onProgress, when set, follows the encoding in seconds of
+// video.
+func burnSubtitles(ctx context.Context, ffmpeg, input, output string, result jsonResult, onProgress func(transcribe.Progress)) error {
+	srt, err := os.CreateTemp("", "gemini-transcribe-burn-*.srt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(srt.Name())
+	if _, err := srt.WriteString(transcribe.FormatSRT(result.Segments)); err != nil {
+		srt.Close()
+		return err
+	}
+	srt.Close()
+
+	cmd := proc.Command(ctx, ffmpeg, "-v", "error", "-nostats", "-progress", "pipe:1", "-y",
+		"-i", input, "-map", "0:v:0", "-map", "0:a?",
+		"-vf", "subtitles="+escapeFilterPath(srt.Name()),
+		output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// -progress writes key=value lines, with out_time_us for how far
+	// the encoding has got
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		us, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if n, err := strconv.ParseInt(us, 10, 64); ok && err == nil && onProgress != nil {
+			onProgress(transcribe.Progress{Stage: "burn-in", Done: float64(n) / 1e6, Total: result.Duration})
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		os.Remove(output)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &transcribe.FFmpegError{Err: err, Stderr: stderr.String()}
+	}
+	if onProgress != nil && result.Duration > 0 {
+		onProgress(transcribe.Progress{Stage: "burn-in", Done: result.Duration, Total: result.Duration})
+	}
+	return nil
+}
+
+// escapeFilterPath escapes a file name for use as a filter option in a
+// filtergraph, where it's unescaped twice: once as the option's value and
+// once as part of the graph. Backslashes become slashes first, so Windows
+// paths survive.
+func escapeFilterPath(path string) string {
+	path = strings.ReplaceAll(path, `\`, "/")
+	escape := func(s, special string) string {
+		var b strings.Builder
+		for _, r := range s {
+			if strings.ContainsRune(special, r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	return escape(escape(path, `\':`), `\'[],;`)
+}
+
+// sameFile reports whether a and b name the same existing file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// minChapterLength is the shortest chapter YouTube accepts, in seconds.
+const minChapterLength = 10
+
+// transcriptChapters turns the topics of the segments into chapters, for
+// --chapters and --write-chapters: a new chapter wherever the topic
+// changes, the first at 0:00 as YouTube requires, and topics shorter than
+// minChapterLength folded into the chapter before.
+func transcriptChapters(result jsonResult) []chapter {
+	var topics []chapter
+	for _, s := range result.Segments {
+		if s.Topic != "" && (len(topics) == 0 || topics[len(topics)-1].Title != s.Topic) {
+			topics = append(topics, chapter{Start: s.Start, Title: s.Topic})
+		}
+	}
+	end := transcriptEnd(result)
+	var chapters []chapter
+	for i, c := range topics {
+		until := end
+		if i+1 < len(topics) {
+			until = topics[i+1].Start
+		}
+		// A short first topic gives way to the next, which moves to 0:00
+		if until-c.Start < minChapterLength && (len(chapters) > 0 || i+1 < len(topics)) {
+			continue
+		}
+		if n := len(chapters); n > 0 && chapters[n-1].Title == c.Title {
+			continue
+		}
+		chapters = append(chapters, c)
+	}
+	if len(chapters) > 0 {
+		chapters[0].Start = 0
+	}
+	return chapters
+}
+
+// transcriptEnd is where the recording ends, as far as the result tells.
+func transcriptEnd(result jsonResult) float64 {
+	end := result.Duration
+	if n := len(result.Segments); n > 0 {
+		end = max(end, result.Segments[n-1].End)
+	}
+	return end
+}
+
+// renderChapters renders the chapters as a YouTube chapter list, one
+// "0:00 Title" line each, ready to paste into a video description.
+func renderChapters(result jsonResult) string {
+	var b strings.Builder
+	for _, c := range transcriptChapters(result) {
+		fmt.Fprintf(&b, "%s %s\n", formatClock(math.Floor(c.Start)), c.Title)
+	}
+	return b.String()
+}
+
+// writeChapters replaces the chapters of the input file, an MP4 or
+// Matroska file, with the transcript's, for --write-chapters.
+func writeChapters(ctx context.Context, client *transcribe.Client, result jsonResult) error {
+	path := result.File
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return errors.New("--write-chapters only works with local files")
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".mp4", ".m4a", ".m4v", ".m4b", ".mov", ".mkv", ".mka", ".webm":
+	default:
+		return fmt.Errorf("--write-chapters supports MP4 (M4A, M4V, M4B, MOV) and Matroska (MKV, WebM) files, not %s", ext)
+	}
+	chapters := transcriptChapters(result)
+	if len(chapters) == 0 {
+		return errors.New("the transcript has no topics to make chapters from")
+	}
+	return rewriteFile(path, func(tmp string) error {
+		return editMetadata(ctx, ffmpegBinary(client), path, tmp, "", chapters, transcriptEnd(result))
+	})
+}
+
+// updateInput writes the transcript and its chapters back into the input
+// file, for --embed and --write-chapters.
+func updateInput(ctx context.Context, client *transcribe.Client, result jsonResult, opts options) error {
+	if result.Transcription == "" {
+		return nil
+	}
+	if opts.embed {
+		if err := embedTranscript(ctx, client, result); err != nil {
+			return fmt.Errorf("embedding the transcript: %v", err)
+		}
+	}
+	if opts.chapterMeta {
+		if err := writeChapters(ctx, client, result); err != nil {
+			return fmt.Errorf("writing chapters: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// globalFlags are the flags every command that calls the API takes: the
+// credentials, the endpoint, the proxy and logging.
+type globalFlags struct {
+	apiKey   string
+	baseURL  string
+	proxy    string
+	verbose  bool
+	quiet    bool
+	logLevel string
+	logJSON  bool
+}
+
+// register adds the global flags to fs.
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.apiKey, "k", "", "Gemini API key, or several separated by commas to rotate between (or set GEMINI_API_KEY)")
+	fs.StringVar(&g.apiKey, "key", "", "Gemini API key, or several separated by commas to rotate between (or set GEMINI_API_KEY)")
+	fs.StringVar(&g.baseURL, "b", "", "Custom API base URL (or set GEMINI_BASE_URL)")
+	fs.StringVar(&g.baseURL, "base-url", "", "Custom API base URL (or set GEMINI_BASE_URL)")
+	fs.StringVar(&g.proxy, "proxy", "", "Proxy for API requests and downloads, e.g. http://proxy:3128 or socks5://127.0.0.1:1080 (default: HTTP_PROXY/HTTPS_PROXY)")
+	fs.BoolVar(&g.verbose, "v", false, "Verbose output")
+	fs.BoolVar(&g.verbose, "verbose", false, "Verbose output")
+	fs.BoolVar(&g.quiet, "q", false, "Only log errors")
+	fs.BoolVar(&g.quiet, "quiet", false, "Only log errors")
+	fs.StringVar(&g.logLevel, "log-level", "", "Log level on stderr: debug, info, warn or error (default: info, debug with -v, error with -q)")
+	fs.BoolVar(&g.logJSON, "log-json", false, "Log to stderr as JSON lines")
+}
+
+// initLogging sets up logging from the flags, exiting on an invalid
+// --log-level, and turns verbose on when debug messages are shown.
+func (g *globalFlags) initLogging(timestamps bool) {
+	level := slog.LevelInfo
+	switch {
+	case g.logLevel != "":
+		var err error
+		if level, err = parseLogLevel(g.logLevel); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+	case g.verbose:
+		level = slog.LevelDebug
+	case g.quiet:
+		level = slog.LevelError
+	}
+	setupLogging(level, g.logJSON, timestamps)
+	g.verbose = level <= slog.LevelDebug
+}
+
+// newClient returns a client for the public API configured from the global
+// flags, exiting when no API key is set or the proxy is invalid. Logging is
+// left to the command.
+func (g *globalFlags) newClient() *transcribe.Client {
+	keys := splitList(mustResolveAPIKey(g.apiKey))
+	if len(keys) == 0 {
+		errorf("Error: %v", errNoAPIKey)
+		os.Exit(exitAuth)
+	}
+	client := transcribe.NewClient(keys[0])
+	if len(keys) > 1 {
+		client.Keys = transcribe.NewKeyPool(keys)
+	}
+	client.BaseURL = resolveBaseURL(g.baseURL)
+	httpClient, err := newHTTPClient(g.proxy)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	client.HTTPClient = httpClient
+	objectStore.HTTPClient = httpClient
+	return client
+}
+
+// printCommands lists the subcommands for the main usage text.
+func printCommands() {
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  transcribe  Transcribe audio and video files (the default when no command is given)\n")
+	fmt.Fprintf(os.Stderr, "  translate   Transcribe and translate into another language (--to)\n")
+	fmt.Fprintf(os.Stderr, "  summarize   Transcribe and output only a summary\n")
+	fmt.Fprintf(os.Stderr, "  minutes     Extract meeting minutes from a recording or transcript\n")
+	fmt.Fprintf(os.Stderr, "  align       Time an existing transcript against the audio as subtitles\n")
+	fmt.Fprintf(os.Stderr, "  eval        Score a transcript against a reference by word error rate\n")
+	fmt.Fprintf(os.Stderr, "  watch       Transcribe files as they appear in a directory\n")
+	fmt.Fprintf(os.Stderr, "  feed        Transcribe new episodes of a podcast RSS feed\n")
+	fmt.Fprintf(os.Stderr, "  search      Search the transcripts stored with --db\n")
+	fmt.Fprintf(os.Stderr, "  serve       Run an HTTP transcription server\n")
+	fmt.Fprintf(os.Stderr, "  models      List the models that accept audio\n")
+	fmt.Fprintf(os.Stderr, "  init        Set up the API key, default model and output format\n")
+	fmt.Fprintf(os.Stderr, "  config      Show or create the config file\n")
+	fmt.Fprintf(os.Stderr, "  completion  Print a shell completion script\n\n")
+	fmt.Fprintf(os.Stderr, "Run gemini-transcribe <command> -h for the options of a command.\n\n")
+}
+
+// configTemplate is written by config init.
+const configTemplate = `# gemini-transcribe configuration. Flags on the command line take
+# precedence over the profile in use.
+
+# default_profile: work
+
+profiles:
+  # work:
+  #   model: gemini-2.5-pro
+  #   api_key_env: WORK_GEMINI_KEY   # or api_key, or api_key_file
+  #   base_url: https://gemini-proxy.example.com
+  #   preset: meeting-minutes        # or prompt, or prompt_file
+  #   format: srt
+
+presets:
+  # podcast: Transcribe this podcast episode, labelling each host by name.
+`
+
+// configFlagSet returns the config command's flags.
+func configFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe config <path|show|init|set-key|delete-key>\n\n")
+		fmt.Fprintf(os.Stderr, "  path        Print where the config file is read from\n")
+		fmt.Fprintf(os.Stderr, "  show        List the profiles and prompt presets it defines\n")
+		fmt.Fprintf(os.Stderr, "  init        Create a commented starter config file\n")
+		fmt.Fprintf(os.Stderr, "  set-key     Store the API key (read from stdin) in the OS keyring\n")
+		fmt.Fprintf(os.Stderr, "  delete-key  Remove the API key from the OS keyring\n")
+	}
+	return fs
+}
+
+// runConfig implements the config subcommand.
+func runConfig(args []string) {
+	fs := configFlagSet()
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	switch fs.Arg(0) {
+	case "set-key":
+		setKey()
+		return
+	case "delete-key":
+		if err := keyringDelete(); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+		infof("Removed the API key from the keyring")
+		return
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	switch fs.Arg(0) {
+	case "path":
+		fmt.Println(cfg.path)
+	case "show":
+		if !cfg.exists {
+			infof("No config file at %s; run gemini-transcribe config init to create one", cfg.path)
+			return
+		}
+		fmt.Printf("Config file: %s\n", cfg.path)
+		fmt.Println("\nProfiles:")
+		for _, name := range sortedKeys(cfg.Profiles) {
+			marker := ""
+			if name == cfg.DefaultProfile {
+				marker = " (default)"
+			}
+			fmt.Printf("  %s%s%s\n", name, marker, describeProfile(cfg.Profiles[name]))
+		}
+		fmt.Println("\nPresets:")
+		for _, name := range presetNames() {
+			fmt.Printf("  %s (built in)\n", name)
+		}
+		for _, name := range sortedKeys(cfg.Presets) {
+			fmt.Printf("  %s\n", name)
+		}
+	case "init":
+		if cfg.exists {
+			errorf("Error: %s already exists", cfg.path)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(cfg.path), 0755); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(cfg.path, []byte(configTemplate), 0600); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+		infof("Created %s", cfg.path)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+// setKey implements config set-key: it reads the key from stdin, without
+// echoing it when stdin is a terminal, and stores it in the OS keyring.
+func setKey() {
+	key, err := readSecret(bufio.NewReader(os.Stdin), "Gemini API key: ")
+	if err != nil {
+		errorf("Error reading the key: %v", err)
+		os.Exit(1)
+	}
+	if !validKeyringKey(key) {
+		errorf("Error: expected the API key on a single line")
+		os.Exit(1)
+	}
+	if err := keyringSet(key); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	infof("Stored the API key in the keyring")
+}
+
+// readSecret reads a line from in, prompting with label and turning echo
+// off while it is typed when stdin is a terminal.
+func readSecret(in *bufio.Reader, label string) (string, error) {
+	hidden := false
+	if isTerminal(os.Stdin) {
+		fmt.Fprint(os.Stderr, label)
+		// Best effort: stty isn't there on Windows
+		hidden = stty("-echo") == nil
+	}
+	line, err := in.ReadString('\n')
+	if hidden {
+		stty("echo")
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// stty changes the settings of the terminal on stdin.
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// describeProfile summarises the settings a profile overrides, leaving out
+// the API key itself.
+func describeProfile(p profile) string {
+	var parts []string
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+	add("model", p.Model)
+	add("base_url", p.BaseURL)
+	add("preset", p.Preset)
+	add("prompt_file", p.PromptFile)
+	add("format", p.Format)
+	if p.Prompt != "" {
+		parts = append(parts, "prompt=...")
+	}
+	switch {
+	case p.APIKey != "":
+		parts = append(parts, "api_key=(set)")
+	case p.APIKeyEnv != "":
+		add("api_key_env", p.APIKeyEnv)
+	case p.APIKeyFile != "":
+		add("api_key_file", p.APIKeyFile)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// comparison is one model's run for --compare.
+type comparison struct {
+	Model   string     `json:"model"`
+	Seconds float64    `json:"seconds"`
+	Usage   *usageJSON `json:"usage,omitempty"`
+	// Difference is the word error rate against the first model's
+	// transcript.
+	Difference    *float64 `json:"difference,omitempty"`
+	Transcription string   `json:"transcription"`
+	Error         string   `json:"error,omitempty"`
+
+	edits []wordEdit
+}
+
+// compareJSON is the --compare output with --json.
+type compareJSON struct {
+	File   string       `json:"file"`
+	Models []comparison `json:"models"`
+}
+
+// runCompare transcribes input with each of models at once and prints
+// how the other transcripts differ from the first one's, followed by the
+// time, tokens and cost each model took. It returns the exit code.
+func runCompare(ctx context.Context, client *transcribe.Client, input string, models []string, opts options) int {
+	runs := make([]comparison, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			modelClient := *client
+			modelClient.Stats = transcribe.NewStats()
+			modelOpts := opts
+			modelOpts.Model, modelOpts.FallbackModels = model, nil
+			start := time.Now()
+			result, err := transcribeFile(ctx, &modelClient, input, modelOpts)
+			snap := modelClient.Stats.Snapshot()
+			client.Stats.Merge(snap)
+			runs[i] = comparison{
+				Model:         model,
+				Seconds:       time.Since(start).Seconds(),
+				Usage:         usageFor(model, snap, true),
+				Transcription: result.Transcription,
+			}
+			if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+				runs[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	if opts.dryRun != nil {
+		opts.dryRun.summary()
+		return 0
+	}
+	if ctx.Err() != nil {
+		errorf("Error transcribing: %v", errInterrupted)
+		return exitInterrupted
+	}
+
+	base := evalWords(runs[0].Transcription, false)
+	code := 0
+	for i := range runs {
+		if runs[i].Error != "" {
+			code = 1
+			continue
+		}
+		if i == 0 || runs[0].Error != "" || len(base) == 0 {
+			continue
+		}
+		runs[i].edits = alignWords(base, evalWords(runs[i].Transcription, false))
+		errs := 0
+		for _, e := range runs[i].edits {
+			if e.op != opHit {
+				errs++
+			}
+		}
+		diff := float64(errs) / float64(len(base))
+		runs[i].Difference = &diff
+	}
+
+	if opts.format == "json" {
+		out, _ := json.MarshalIndent(compareJSON{File: input, Models: runs}, "", "  ")
+		fmt.Println(string(out))
+		return code
+	}
+
+	color := isTerminal(os.Stdout)
+	if runs[0].Error == "" {
+		fmt.Printf("=== %s ===\n%s\n\n", runs[0].Model, runs[0].Transcription)
+	}
+	for _, r := range runs[1:] {
+		if r.edits != nil {
+			fmt.Printf("=== %s, compared with %s ===\n%s\n", r.Model, runs[0].Model, renderWordDiff(r.edits, color))
+		} else if r.Error == "" {
+			fmt.Printf("=== %s ===\n%s\n\n", r.Model, r.Transcription)
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "MODEL\tTIME\tTOKENS\tCOST\tDIFFERENCE\n")
+	for _, r := range runs {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%s\tfailed: %s\n", r.Model, r.Error)
+			continue
+		}
+		cost, difference := "unknown", "-"
+		if r.Usage.CostUSD != nil {
+			cost = formatCost(*r.Usage.CostUSD)
+		}
+		if r.Difference != nil {
+			difference = fmt.Sprintf("%.1f%%", *r.Difference*100)
+		}
+		fmt.Fprintf(tw, "%s\t%.1fs\t%d\t%s\t%s\n", r.Model, r.Seconds, r.Usage.TotalTokens, cost, difference)
+	}
+	tw.Flush()
+	return code
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// completeCommand is the hidden command the completion scripts call with
+// the words typed so far; it prints the candidates for the last one, one
+// per line. No candidates means the shell should complete file names.
+const completeCommand = "__complete"
+
+// commandNames are the commands offered as the first word.
+var commandNames = []string{"transcribe", "translate", "summarize", "minutes", "align", "eval", "watch", "feed", "search", "serve", "models", "init", "config", "completion"}
+
+// flagSets builds the flag set of each command, the same one the command
+// parses its arguments with, so completion can list its flags without
+// running it.
+var flagSets = map[string]func() *flag.FlagSet{
+	"transcribe": func() *flag.FlagSet { return new(transcribeFlags).flagSet("transcribe") },
+	"translate":  func() *flag.FlagSet { return new(transcribeFlags).flagSet("translate") },
+	"summarize":  func() *flag.FlagSet { return new(transcribeFlags).flagSet("summarize") },
+	"minutes":    func() *flag.FlagSet { return new(minutesFlags).flagSet() },
+	"align":      func() *flag.FlagSet { return new(alignFlags).flagSet() },
+	"eval":       func() *flag.FlagSet { return new(evalFlags).flagSet() },
+	"watch":      func() *flag.FlagSet { return new(watchFlags).flagSet() },
+	"feed":       func() *flag.FlagSet { return new(feedFlags).flagSet() },
+	"search":     func() *flag.FlagSet { return new(searchFlags).flagSet() },
+	"serve":      func() *flag.FlagSet { return new(serveFlags).flagSet() },
+	"models":     func() *flag.FlagSet { return new(modelsFlags).flagSet() },
+	"init":       initFlagSet,
+	"config":     configFlagSet,
+	"completion": completionFlagSet,
+}
+
+// commandFlags returns the flag set of the named command, or of transcribe
+// when name isn't a command: anything else in first place is an input to
+// the default command.
+func commandFlags(name string) *flag.FlagSet {
+	newFlagSet, ok := flagSets[name]
+	if !ok {
+		newFlagSet = flagSets["transcribe"]
+	}
+	return newFlagSet()
+}
+
+// runComplete implements the hidden __complete command.
+func runComplete(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	cur := args[len(args)-1]
+	if cur == `""` {
+		// PowerShell can't pass an empty argument to a native command
+		cur = ""
+	}
+	for _, c := range completions(args[:len(args)-1], cur) {
+		fmt.Println(c)
+	}
+}
+
+// completions returns the candidates for cur, the word being typed, after
+// the words before it (not counting the program name).
+func completions(before []string, cur string) []string {
+	command := "transcribe"
+	if len(before) > 0 && !strings.HasPrefix(before[0], "-") {
+		command = before[0]
+	}
+
+	var candidates []string
+	switch {
+	case len(before) == 0 && !strings.HasPrefix(cur, "-"):
+		candidates = commandNames
+	case command == "completion":
+		candidates = []string{"bash", "zsh", "fish", "powershell"}
+	case command == "config":
+		candidates = []string{"path", "show", "init", "set-key", "delete-key"}
+	default:
+		fs := commandFlags(command)
+		if len(before) > 0 {
+			if values, ok := flagValues(fs, command, before[len(before)-1]); ok {
+				candidates = values
+				break
+			}
+		}
+		if strings.HasPrefix(cur, "-") {
+			candidates = flagNames(fs)
+		}
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// flagNames lists fs's flags as they are typed: -x for single letters,
+// --name otherwise.
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			names = append(names, "-"+f.Name)
+		} else {
+			names = append(names, "--"+f.Name)
+		}
+	})
+	return names
+}
+
+// flagValues returns the values worth offering after word when it is a flag
+// of fs taking a value. ok is false when word isn't such a flag; an empty
+// list with ok set leaves the value to file completion.
+func flagValues(fs *flag.FlagSet, command, word string) (values []string, ok bool) {
+	name := strings.TrimLeft(word, "-")
+	if !strings.HasPrefix(word, "-") || strings.Contains(name, "=") {
+		return nil, false
+	}
+	f := fs.Lookup(name)
+	if f == nil {
+		return nil, false
+	}
+	if b, isBool := f.Value.(interface{ IsBoolFlag() bool }); isBool && b.IsBoolFlag() {
+		return nil, false
+	}
+
+	switch name {
+	case "m", "model":
+		return modelNames(), true
+	case "preset":
+		names := presetNames()
+		if cfg, err := loadConfig(); err == nil {
+			names = append(names, sortedKeys(cfg.Presets)...)
+		}
+		return names, true
+	case "profile":
+		if cfg, err := loadConfig(); err == nil {
+			return sortedKeys(cfg.Profiles), true
+		}
+	case "f", "format":
+		if command == "minutes" {
+			return []string{"markdown", "json"}, true
+		}
+		return []string{"text", "json", "srt", "vtt", "md", "whisper-json", "chapters"}, true
+	case "safety":
+		return transcribe.SafetyLevels, true
+	case "upload":
+		return []string{"auto", "always", "never"}, true
+	case "convert-to":
+		return sortedKeys(transcribe.Conversions), true
+	case "channel":
+		return []string{"mix", "left", "right"}, true
+	case "style":
+		return transcribe.SummaryStyleNames, true
+	}
+	return nil, true
+}
+
+// modelNames are the models with a known price plus those named in
+// profiles, without asking the API.
+func modelNames() []string {
+	seen := map[string]bool{}
+	for name := range transcribe.Prices {
+		seen[name] = true
+	}
+	if cfg, err := loadConfig(); err == nil {
+		for _, p := range cfg.Profiles {
+			for _, m := range splitList(p.Model) {
+				seen[m] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Completion scripts. Each asks __complete for candidates and falls back
+// to file names when there are none.
+const (
+	bashCompletion = `# bash completion for gemini-transcribe
+_gemini_transcribe() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    local IFS=$'\n'
+    local candidates=($(gemini-transcribe __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [ ${#candidates[@]} -eq 0 ]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+    else
+        COMPREPLY=("${candidates[@]}")
+    fi
+}
+complete -o filenames -F _gemini_transcribe gemini-transcribe
+`
+
+	zshCompletion = `#compdef gemini-transcribe
+_gemini_transcribe() {
+    local -a candidates
+    candidates=("${(@f)$(gemini-transcribe __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -z "${candidates[*]}" ]]; then
+        _files
+    else
+        compadd -a candidates
+    fi
+}
+compdef _gemini_transcribe gemini-transcribe
+`
+
+	fishCompletion = `# fish completion for gemini-transcribe
+function __gemini_transcribe_complete
+    set -l words (commandline -opc) (commandline -ct)
+    gemini-transcribe __complete $words[2..-1] 2>/dev/null
+end
+complete -c gemini-transcribe -a '(__gemini_transcribe_complete)'
+`
+
+	powershellCompletion = `# PowerShell completion for gemini-transcribe
+Register-ArgumentCompleter -Native -CommandName gemini-transcribe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    gemini-transcribe __complete @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
+)
+
+// completionFlagSet returns the completion command's flags.
+func completionFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe completion <bash|zsh|fish|powershell>\n\n")
+		fmt.Fprintf(os.Stderr, "Prints a shell completion script for commands, flags, presets, profiles and models.\n\n")
+		fmt.Fprintf(os.Stderr, "  bash:        source <(gemini-transcribe completion bash)\n")
+		fmt.Fprintf(os.Stderr, "  zsh:         gemini-transcribe completion zsh > \"${fpath[1]}/_gemini-transcribe\"\n")
+		fmt.Fprintf(os.Stderr, "  fish:        gemini-transcribe completion fish > ~/.config/fish/completions/gemini-transcribe.fish\n")
+		fmt.Fprintf(os.Stderr, "  PowerShell:  gemini-transcribe completion powershell | Out-String | Invoke-Expression\n")
+	}
+	return fs
+}
+
+// runCompletion implements the completion subcommand.
+func runCompletion(args []string) {
+	fs := completionFlagSet()
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	switch fs.Arg(0) {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	case "powershell":
+		fmt.Print(powershellCompletion)
+	default:
+		errorf("Error: unknown shell %q (want bash, zsh, fish or powershell)", fs.Arg(0))
+		os.Exit(1)
+	}
+}
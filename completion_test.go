@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFlagSetsCoverCommands(t *testing.T) {
+	for _, name := range commandNames {
+		newFlagSet, ok := flagSets[name]
+		if !ok {
+			t.Errorf("no flag set for %s", name)
+			continue
+		}
+		if fs := newFlagSet(); fs.Name() != name {
+			t.Errorf("flag set for %s is named %s", name, fs.Name())
+		}
+	}
+}
+
+func TestCompletions(t *testing.T) {
+	tests := []struct {
+		before []string
+		cur    string
+		want   []string
+	}{
+		{nil, "mi", []string{"minutes"}},
+		{nil, "--from-u", []string{"--from-url"}},
+		{[]string{"talk.mp3"}, "--from-u", []string{"--from-url"}},
+		{[]string{"watch"}, "--do", []string{"--done"}},
+		{[]string{"models"}, "--a", []string{"--all"}},
+		{[]string{"-f"}, "s", []string{"srt"}},
+		{[]string{"completion"}, "f", []string{"fish"}},
+		{[]string{"config"}, "-", nil},
+	}
+	for _, tt := range tests {
+		if got := completions(tt.before, tt.cur); !slices.Equal(got, tt.want) {
+			t.Errorf("completions(%q, %q) = %q, want %q", tt.before, tt.cur, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profile is a named set of defaults from the config file. Flags given on
+// the command line still take precedence.
+type profile struct {
+	Model      string `yaml:"model"`
+	BaseURL    string `yaml:"base_url"`
+	Prompt     string `yaml:"prompt"`
+	PromptFile string `yaml:"prompt_file"`
+	Preset     string `yaml:"preset"`
+	Format     string `yaml:"format"`
+
+	// The API key comes from the first of these that is set: the key
+	// itself, an environment variable name, or a file holding the key.
+	APIKey     string `yaml:"api_key"`
+	APIKeyEnv  string `yaml:"api_key_env"`
+	APIKeyFile string `yaml:"api_key_file"`
+}
+
+type config struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]profile `yaml:"profiles"`
+	Presets        map[string]string  `yaml:"presets"`
+
+	path   string
+	exists bool
+}
+
+// configPath returns ~/.config/gemini-transcribe/config.yaml.
+func configPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gemini-transcribe", "config.yaml"), nil
+}
+
+// loadConfig reads the config file. A missing file is an empty config.
+func loadConfig() (config, error) {
+	var cfg config
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("reading %s: %v", path, err)
+	}
+	cfg.exists = true
+	return cfg, nil
+}
+
+// profile returns the named profile, or default_profile when name is empty.
+// Without a config file or a default profile the zero profile is returned,
+// unless a name was asked for.
+func (cfg config) profile(name string) (profile, error) {
+	if !cfg.exists {
+		if name != "" {
+			return profile{}, fmt.Errorf("profile %q requested but %s does not exist", name, cfg.path)
+		}
+		return profile{}, nil
+	}
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		return profile{}, nil
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return profile{}, fmt.Errorf("unknown profile %q in %s (have: %s)", name, cfg.path, strings.Join(sortedKeys(cfg.Profiles), ", "))
+	}
+	return p, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apiKey resolves the profile's key reference. An empty result means the
+// profile doesn't set one.
+func (p profile) apiKey() (string, error) {
+	switch {
+	case p.APIKey != "":
+		return p.APIKey, nil
+	case p.APIKeyEnv != "":
+		key := os.Getenv(p.APIKeyEnv)
+		if key == "" {
+			return "", fmt.Errorf("profile api_key_env %s is not set", p.APIKeyEnv)
+		}
+		return key, nil
+	case p.APIKeyFile != "":
+		data, err := os.ReadFile(expandHome(p.APIKeyFile))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", nil
+}
+
+// expandHome replaces a leading ~/ with the user's home directory.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// dbSchema creates the --db tables: one row per recording, and an FTS5
+// index over its file name, transcript and summary kept in step by
+// triggers.
+const dbSchema = `
+CREATE TABLE IF NOT EXISTS transcripts (
+	id INTEGER PRIMARY KEY,
+	file TEXT NOT NULL,
+	sha256 TEXT,
+	transcribed_at TEXT NOT NULL,
+	model TEXT,
+	language TEXT,
+	duration REAL,
+	transcription TEXT NOT NULL,
+	summary TEXT,
+	version TEXT
+);
+CREATE INDEX IF NOT EXISTS transcripts_sha256 ON transcripts(sha256);
+CREATE VIRTUAL TABLE IF NOT EXISTS transcripts_fts USING fts5(
+	file, transcription, summary, content='transcripts', content_rowid='id'
+);
+CREATE TRIGGER IF NOT EXISTS transcripts_ai AFTER INSERT ON transcripts BEGIN
+	INSERT INTO transcripts_fts(rowid, file, transcription, summary) VALUES (new.id, new.file, new.transcription, new.summary);
+END;
+CREATE TRIGGER IF NOT EXISTS transcripts_ad AFTER DELETE ON transcripts BEGIN
+	INSERT INTO transcripts_fts(transcripts_fts, rowid, file, transcription, summary) VALUES ('delete', old.id, old.file, old.transcription, old.summary);
+END;
+`
+
+// transcriptDB is the SQLite database of --db. It's driven through the
+// sqlite3 command-line tool, as media goes through ffmpeg, so the binary
+// stays free of cgo. A nil *transcriptDB records nothing.
+type transcriptDB struct {
+	path string
+	mu   sync.Mutex // batch runs record from several workers
+}
+
+// openTranscriptDB opens the database at path, creating it and its tables
+// if needed.
+func openTranscriptDB(path string) (*transcriptDB, error) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, errors.New("--db needs the sqlite3 command-line tool; install it (e.g. apt install sqlite3, brew install sqlite) or add it to PATH")
+	}
+	db := &transcriptDB{path: path}
+	if _, err := db.exec(dbSchema); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// exec runs SQL statements and returns their rows as sqlite3's JSON output.
+func (db *transcriptDB) exec(sql string) ([]byte, error) {
+	// Wait for a while on a database another process is writing to
+	cmd := exec.Command("sqlite3", "-batch", "-bail", "-json", "-cmd", ".timeout 5000", db.path)
+	cmd.Stdin = strings.NewReader(sql)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			// "Runtime error near line 3: ..." points into SQL the user
+			// never saw
+			if _, after, ok := strings.Cut(msg, " error near line "); ok {
+				if _, m, ok := strings.Cut(after, ": "); ok {
+					msg = m
+				}
+			}
+			return nil, fmt.Errorf("%s: %s", db.path, msg)
+		}
+		return nil, fmt.Errorf("%s: sqlite3: %v", db.path, err)
+	}
+	return out, nil
+}
+
+// record stores a transcript, replacing the row an earlier run stored for
+// the same audio. Local files are stored by absolute path and identified
+// by their SHA-256; URLs and stdin by name alone.
+func (db *transcriptDB) record(result jsonResult) error {
+	if db == nil || result.Transcription == "" {
+		return nil
+	}
+	file, hash := result.File, ""
+	if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		var err error
+		if hash, err = fileSHA256(file); err != nil {
+			return err
+		}
+	}
+	match := "file = " + sqlText(file) + " AND sha256 IS NULL"
+	if hash != "" {
+		match = "sha256 = " + sqlText(hash)
+	}
+	sql := fmt.Sprintf(`BEGIN;
+DELETE FROM transcripts WHERE %s;
+INSERT INTO transcripts (file, sha256, transcribed_at, model, language, duration, transcription, summary, version)
+VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s);
+COMMIT;
+`, match, sqlText(file), sqlText(hash), sqlText(time.Now().UTC().Format(time.RFC3339)),
+		sqlText(result.Model), sqlText(result.Language), sqlReal(result.Duration),
+		sqlText(result.Transcription), sqlText(result.Summary), sqlText(result.Version))
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	_, err := db.exec(sql)
+	return err
+}
+
+// recordTranscript stores a finished transcript in --db, warning rather
+// than failing when it can't.
+func recordTranscript(opts options, result jsonResult) {
+	if err := opts.db.record(result); err != nil {
+		warnf("Warning: recording %s in the database: %v", result.File, err)
+	}
+}
+
+// searchHit is one recording matched by the search command.
+type searchHit struct {
+	File          string  `json:"file"`
+	SHA256        string  `json:"sha256,omitempty"`
+	TranscribedAt string  `json:"transcribed_at"`
+	Model         string  `json:"model,omitempty"`
+	Language      string  `json:"language,omitempty"`
+	Duration      float64 `json:"duration,omitempty"`
+	Snippet       string  `json:"snippet"`
+}
+
+// search returns the recordings matching an FTS5 query, best first, with
+// a snippet of the transcript around the match, marked between before
+// and after.
+func (db *transcriptDB) search(query string, limit int, before, after string) ([]searchHit, error) {
+	sql := fmt.Sprintf(`SELECT t.file, t.sha256, t.transcribed_at, t.model, t.language, t.duration,
+	snippet(transcripts_fts, 1, %s, %s, '…', 16) AS snippet
+FROM transcripts_fts JOIN transcripts t ON t.id = transcripts_fts.rowid
+WHERE transcripts_fts MATCH %s
+ORDER BY bm25(transcripts_fts)
+LIMIT %d;
+`, sqlText(before), sqlText(after), sqlText(query), limit)
+	out, err := db.exec(sql)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		File          string   `json:"file"`
+		SHA256        *string  `json:"sha256"`
+		TranscribedAt string   `json:"transcribed_at"`
+		Model         *string  `json:"model"`
+		Language      *string  `json:"language"`
+		Duration      *float64 `json:"duration"`
+		Snippet       *string  `json:"snippet"`
+	}
+	// sqlite3 prints nothing at all for no rows
+	if len(bytes.TrimSpace(out)) > 0 {
+		if err := json.Unmarshal(out, &rows); err != nil {
+			return nil, fmt.Errorf("reading sqlite3 output: %v", err)
+		}
+	}
+	hits := make([]searchHit, len(rows))
+	for i, r := range rows {
+		hits[i] = searchHit{File: r.File, TranscribedAt: r.TranscribedAt, SHA256: deref(r.SHA256),
+			Model: deref(r.Model), Language: deref(r.Language), Snippet: deref(r.Snippet)}
+		if r.Duration != nil {
+			hits[i].Duration = *r.Duration
+		}
+	}
+	return hits, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// sqlText quotes s as an SQL string literal, or NULL when it's empty.
+func sqlText(s string) string {
+	if s == "" {
+		return "NULL"
+	}
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlReal formats f as an SQL number, or NULL when it's zero or not
+// finite, which SQL has no literal for.
+func sqlReal(f float64) string {
+	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "NULL"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's contents.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// searchFlags are the search command's flag values.
+type searchFlags struct {
+	dbPath     string
+	limit      int
+	outputJSON bool
+}
+
+// flagSet returns the search command's flags, bound to v.
+func (v *searchFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs.StringVar(&v.dbPath, "db", "transcripts.db", "Transcript database written by --db")
+	fs.IntVar(&v.limit, "n", 20, "Show at most this many recordings")
+	fs.BoolVar(&v.outputJSON, "json", false, "Output as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe search [options] <query>\n\n")
+		fmt.Fprintf(os.Stderr, "Finds the recordings whose transcript mentions query, in a database written with --db.\n")
+		fmt.Fprintf(os.Stderr, "Words must all appear; \"quoted phrases\", OR, NOT and prefix* follow SQLite FTS5 syntax.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runSearch implements the search subcommand.
+func runSearch(args []string) {
+	var v searchFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if _, err := os.Stat(v.dbPath); err != nil {
+		errorf("Error: no transcript database at %s; transcribe with --db %s first", v.dbPath, v.dbPath)
+		os.Exit(1)
+	}
+	db, err := openTranscriptDB(v.dbPath)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+
+	before, after := "[", "]"
+	if isTerminal(os.Stdout) && !v.outputJSON {
+		before, after = "\x1b[1m", "\x1b[0m"
+	}
+	hits, err := db.search(strings.Join(fs.Args(), " "), v.limit, before, after)
+	if err != nil {
+		errorf("Error: %v", err)
+		if strings.Contains(err.Error(), "fts5: syntax error") {
+			errorf("Put words with punctuation in double quotes, e.g. '\"don't\"'")
+		}
+		os.Exit(1)
+	}
+	if v.outputJSON {
+		out, _ := json.MarshalIndent(hits, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if len(hits) == 0 {
+		infof("No matches")
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, h := range hits {
+		date := h.TranscribedAt
+		if t, err := time.Parse(time.RFC3339, date); err == nil {
+			date = t.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", h.File, date, h.Model)
+		fmt.Fprintf(tw, "  %s\n", strings.Join(strings.Fields(h.Snippet), " "))
+	}
+	tw.Flush()
+}
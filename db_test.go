@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "NULL"},
+		{"hello", "'hello'"},
+		{"it's", "'it''s'"},
+		{"''", "''''''"},
+		{"a\x00b", "'ab'"},
+		{"line one\nline two; DROP TABLE transcripts;--", "'line one\nline two; DROP TABLE transcripts;--'"},
+	}
+	for _, tt := range tests {
+		if got := sqlText(tt.in); got != tt.want {
+			t.Errorf("sqlText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSQLReal(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "NULL"},
+		{math.NaN(), "NULL"},
+		{math.Inf(1), "NULL"},
+		{math.Inf(-1), "NULL"},
+		{61.5, "61.5"},
+		{1e6, "1e+06"},
+	}
+	for _, tt := range tests {
+		if got := sqlReal(tt.in); got != tt.want {
+			t.Errorf("sqlReal(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSQLTextRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("no sqlite3")
+	}
+	db := &transcriptDB{path: filepath.Join(t.TempDir(), "t.db")}
+	for _, s := range []string{"it's", "O'Brien said \"hi\"", "naïve café 日本語", "x'); DROP TABLE t; --", "back\\slash\nnewline"} {
+		out, err := db.exec("SELECT " + sqlText(s) + " AS v;")
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		var rows []struct{ V string }
+		if err := json.Unmarshal(out, &rows); err != nil || len(rows) != 1 || rows[0].V != s {
+			t.Errorf("%q came back as %s (%v)", s, out, err)
+		}
+	}
+}
+
+func TestRecordSearch(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("no sqlite3")
+	}
+	db, err := openTranscriptDB(filepath.Join(t.TempDir(), "t.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []jsonResult{
+		{File: "https://example.com/it's.mp3", Model: "m", Duration: math.NaN(),
+			Transcription: "O'Brien said \"it's fine\" and left.\x00 Then came the rain."},
+		{File: "-", Duration: math.Inf(1), Transcription: "Nothing about that here; DROP TABLE transcripts; --"},
+		{File: "https://example.com/b.mp3", Duration: 12.5, Transcription: "A sunny day."},
+	}
+	for _, r := range results {
+		if err := db.record(r); err != nil {
+			t.Fatalf("record(%s): %v", r.File, err)
+		}
+	}
+	// Recording the same URL again replaces its row
+	if err := db.record(results[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		files []string
+		err   string
+	}{
+		{`"it's fine"`, []string{results[0].File}, ""},
+		{`brien AND rain`, []string{results[0].File}, ""},
+		{"ra\x00in", []string{results[0].File}, ""},
+		{`sun*`, []string{results[2].File}, ""},
+		{`drop NOT sunny`, []string{results[1].File}, ""},
+		{`nowhere`, nil, ""},
+		{`it's`, nil, "fts5: syntax error"},
+		{`x'); DELETE FROM transcripts; --`, nil, "fts5: syntax error"},
+		{`NEAR(`, nil, "fts5: syntax error"},
+	}
+	for _, tt := range tests {
+		hits, err := db.search(tt.query, 10, "['", "']")
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("search(%q) err = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("search(%q): %v", tt.query, err)
+			continue
+		}
+		var files []string
+		for _, h := range hits {
+			files = append(files, h.File)
+		}
+		if strings.Join(files, ",") != strings.Join(tt.files, ",") {
+			t.Errorf("search(%q) = %q, want %q", tt.query, files, tt.files)
+		}
+	}
+
+	hits, err := db.search("sunny OR brien", 10, "['", "']")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("search found %d recordings, want 2 after the repeat replaced its row", len(hits))
+	}
+	for _, h := range hits {
+		switch h.File {
+		case results[0].File:
+			if h.Duration != 0 || !strings.Contains(h.Snippet, "O'['Brien']") {
+				t.Errorf("hit = %+v, want no duration and a marked snippet", h)
+			}
+		case results[2].File:
+			if h.Duration != 12.5 {
+				t.Errorf("duration = %v, want 12.5", h.Duration)
+			}
+		}
+	}
+}
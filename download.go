@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// isURL reports whether an input names an http(s) URL rather than a file.
+func isURL(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// urlBaseName is the file name part of a URL's path, used to name outputs.
+func urlBaseName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			return base
+		}
+	}
+	return "download"
+}
+
+// downloadInput streams a URL into a temp file and returns its path. The
+// extension comes from the URL or, failing that, the Content-Type, so the
+// rest of the pipeline can pick the right MIME type. Progress goes to stderr
+// when it is a terminal. A nil httpClient means http.DefaultClient.
+func downloadInput(ctx context.Context, httpClient *http.Client, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+
+	ext := strings.ToLower(path.Ext(urlBaseName(rawURL)))
+	if !mediaExts[ext] {
+		ext = extForContentType(resp.Header.Get("Content-Type"))
+	}
+	tmp, err := os.CreateTemp("", "gemini-transcribe-download-*"+ext)
+	if err != nil {
+		return "", err
+	}
+
+	var src io.Reader = resp.Body
+	var p *downloadProgress
+	if showProgress() {
+		p = newDownloadProgress(urlBaseName(rawURL), resp.ContentLength)
+		src = io.TeeReader(resp.Body, p)
+	}
+	_, err = io.Copy(tmp, src)
+	if p != nil {
+		p.finish()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("downloading %s: %w", rawURL, err)
+	}
+	return tmp.Name(), nil
+}
+
+// mimeAliases maps other names for the supported media types to the ones
+// MimeType uses.
+var mimeAliases = map[string]string{
+	"audio/mp3":       "audio/mpeg",
+	"audio/wave":      "audio/wav",
+	"audio/x-wav":     "audio/wav",
+	"application/ogg": "audio/ogg",
+	"audio/x-flac":    "audio/flac",
+	"audio/x-m4a":     "audio/mp4",
+	"video/avi":       "video/x-msvideo",
+}
+
+// readStdin copies audio from stdin into a temp file and returns its path.
+// The extension comes from mimeType or, when that is empty, from sniffing
+// the first bytes, so the rest of the pipeline treats it like any file. A
+// format that can't be sniffed is left for ffmpeg to probe.
+func readStdin(mimeType, ffmpeg string) (string, error) {
+	in := bufio.NewReader(os.Stdin)
+	sniffed := mimeType == ""
+	if sniffed {
+		head, _ := in.Peek(512)
+		mimeType = transcribe.SniffMimeType(head)
+	}
+	if alias, ok := mimeAliases[mimeType]; ok {
+		mimeType = alias
+	}
+	ext := extForContentType(mimeType)
+	if ext == "" && !sniffed {
+		return "", fmt.Errorf("reading stdin: unsupported MIME type %q", mimeType)
+	}
+	if _, err := exec.LookPath(ffmpeg); ext == "" && err != nil {
+		return "", errors.New("reading stdin: unrecognised audio format (set --mime-type, e.g. audio/mpeg)")
+	}
+
+	tmp, err := os.CreateTemp("", "gemini-transcribe-stdin-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n == 0 {
+		err = errors.New("no audio data")
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("reading stdin: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// extForContentType maps a response Content-Type back to a media extension.
+func extForContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	exts := make([]string, 0, len(mediaExts))
+	for ext := range mediaExts {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		if transcribe.MimeType(ext) == mediaType {
+			return ext
+		}
+	}
+	return ""
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
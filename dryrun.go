@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// dryRunReport prints the requests --dry-run plans, the prompt once, and a
+// total of the estimated tokens and cost at the end.
+type dryRunReport struct {
+	mu          sync.Mutex
+	requests    int
+	cached      int
+	unknown     int
+	tokens      int
+	cost        float64
+	unpriced    []string
+	shownPrompt bool
+}
+
+// add prints one planned request for file.
+func (r *dryRunReport) add(file string, req transcribe.DryRunRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.shownPrompt {
+		r.shownPrompt = true
+		if req.SystemInstruction != "" {
+			fmt.Fprintf(os.Stderr, "System instruction:\n%s\n\n", indent(req.SystemInstruction))
+		}
+		fmt.Fprintf(os.Stderr, "Prompt:\n%s\n\n", indent(req.Prompt))
+	}
+
+	length := "unknown length"
+	if req.Duration > 0 {
+		length = time.Duration(req.Duration * float64(time.Second)).Round(time.Second).String()
+	}
+	how := "inline"
+	if req.Upload {
+		how = "via Files API"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s, %s of %s (%s %s, request %s)\n",
+		file, req.Model, length, req.MimeType, formatSize(int64(req.AudioBytes)), how, formatSize(req.RequestBytes))
+	if req.Cached {
+		fmt.Fprintln(os.Stderr, "  cached, no request needed")
+		r.cached++
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  POST %s\n", req.URL)
+
+	r.requests++
+	if req.Duration == 0 {
+		// Without the length there is no audio token estimate
+		r.unknown++
+		fmt.Fprintf(os.Stderr, "  about %d prompt tokens plus the audio\n", req.PromptTokens)
+		return
+	}
+	r.tokens += req.AudioTokens + req.PromptTokens
+	snap := transcribe.StatsSnapshot{PromptTokens: req.AudioTokens + req.PromptTokens, AudioTokens: req.AudioTokens}
+	cost, ok := transcribe.EstimateCost(req.Model, snap)
+	if !ok {
+		if !slices.Contains(r.unpriced, req.Model) {
+			r.unpriced = append(r.unpriced, req.Model)
+		}
+		fmt.Fprintf(os.Stderr, "  about %d audio + %d prompt tokens\n", req.AudioTokens, req.PromptTokens)
+		return
+	}
+	r.cost += cost
+	fmt.Fprintf(os.Stderr, "  about %d audio + %d prompt tokens, $%.4f input\n", req.AudioTokens, req.PromptTokens, cost)
+}
+
+// summary prints the totals.
+func (r *dryRunReport) summary() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\nDry run: %d request(s)", r.requests)
+	if r.cached > 0 {
+		fmt.Fprintf(os.Stderr, " and %d cached", r.cached)
+	}
+	fmt.Fprintf(os.Stderr, ", about %d input tokens, estimated input cost $%.4f", r.tokens, r.cost)
+	var notes []string
+	if r.unknown > 0 {
+		notes = append(notes, fmt.Sprintf("%d of unknown length not included", r.unknown))
+	}
+	if len(r.unpriced) > 0 {
+		notes = append(notes, "no price for "+strings.Join(r.unpriced, ", "))
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(os.Stderr, " (%s)", strings.Join(notes, "; "))
+	}
+	fmt.Fprintln(os.Stderr, ". Nothing was sent.")
+}
+
+// formatSize formats a byte count in KB or MB.
+func formatSize(n int64) string {
+	if n < 1e6 {
+		return fmt.Sprintf("%.0f KB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
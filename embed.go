@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// embedTrackTitle names the subtitle track --embed adds to MKV files, so
+// embedding again replaces it instead of adding another.
+const embedTrackTitle = "Transcript (gemini-transcribe)"
+
+// embedTranscript writes the transcript into the input file's own
+// metadata for --embed: an ID3 USLT (unsynchronised lyrics) frame in MP3s,
+// the ©lyr atom and chapters from the topics in MP4s, and a subtitle track
+// in MKVs. The file is rewritten through a temp file next to it, so it's
+// never left half written.
+func embedTranscript(ctx context.Context, client *transcribe.Client, result jsonResult) error {
+	path := result.File
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return errors.New("--embed only works with local files")
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".mp3":
+		return rewriteFile(path, func(tmp string) error {
+			return writeID3Lyrics(path, tmp, iso6392(result.Language), result.Transcription)
+		})
+	case ".mp4", ".m4a", ".m4v", ".m4b", ".mov":
+		return rewriteFile(path, func(tmp string) error {
+			return embedMP4(ctx, ffmpegBinary(client), path, tmp, result)
+		})
+	case ".mkv", ".mka":
+		if len(result.Segments) == 0 {
+			return errors.New("no timed segments to make a subtitle track from")
+		}
+		return rewriteFile(path, func(tmp string) error {
+			return embedMKV(ctx, ffmpegBinary(client), ffprobeBinary(client), path, tmp, result)
+		})
+	default:
+		return fmt.Errorf("--embed supports MP3, MP4 (M4A, M4V, MOV) and MKV files, not %s", ext)
+	}
+}
+
+// rewriteFile has write produce a new version of path in a temp file in
+// the same directory, then moves it over path with the same permissions.
+func rewriteFile(path string, write func(tmp string) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".gemini-transcribe-embed-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	if err := write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeID3Lyrics copies the MP3 at src to dst with text in a USLT frame,
+// replacing any lyrics the ID3v2 tag already had and keeping its other
+// frames. A file without a tag gets an ID3v2.4 one.
+func writeID3Lyrics(src, dst, lang, text string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	version := byte(4)
+	var frames []byte
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return err
+	}
+	if string(header[:3]) == "ID3" {
+		version = header[3]
+		flags := header[5]
+		if version < 3 || version > 4 {
+			return fmt.Errorf("ID3v2.%d tags aren't supported", version)
+		}
+		if flags&0xc0 != 0 {
+			return errors.New("ID3 tags with unsynchronisation or an extended header aren't supported")
+		}
+		tag := make([]byte, syncsafe(header[6:10]))
+		if _, err := io.ReadFull(in, tag); err != nil {
+			return fmt.Errorf("reading the ID3 tag: %v", err)
+		}
+		if flags&0x10 != 0 {
+			// Skip the footer; the new tag is written without one
+			if _, err := in.Seek(10, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		for pos := 0; pos+10 <= len(tag) && tag[pos] != 0; {
+			size := int(binary.BigEndian.Uint32(tag[pos+4 : pos+8]))
+			if version == 4 {
+				size = syncsafe(tag[pos+4 : pos+8])
+			}
+			end := pos + 10 + size
+			if end > len(tag) {
+				return errors.New("the ID3 tag is corrupt")
+			}
+			if string(tag[pos:pos+4]) != "USLT" {
+				frames = append(frames, tag[pos:end]...)
+			}
+			pos = end
+		}
+	} else if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	frames = append(frames, usltFrame(version, lang, text)...)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	tagHeader := []byte{'I', 'D', '3', version, 0, 0}
+	tagHeader = append(tagHeader, putSyncsafe(len(frames))...)
+	if _, err := out.Write(append(tagHeader, frames...)); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// usltFrame encodes an ID3v2 USLT frame with an empty description: UTF-8
+// for ID3v2.4, and UTF-16 for ID3v2.3, which has no UTF-8.
+func usltFrame(version byte, lang, text string) []byte {
+	var body []byte
+	if version == 4 {
+		body = append([]byte{3}, lang...)
+		body = append(body, 0)
+		body = append(body, text...)
+	} else {
+		body = append([]byte{1}, lang...)
+		body = append(body, 0xff, 0xfe, 0, 0, 0xff, 0xfe)
+		for _, u := range utf16.Encode([]rune(text)) {
+			body = binary.LittleEndian.AppendUint16(body, u)
+		}
+	}
+	frame := []byte("USLT")
+	if version == 4 {
+		frame = append(frame, putSyncsafe(len(body))...)
+	} else {
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	}
+	frame = append(frame, 0, 0)
+	return append(frame, body...)
+}
+
+// syncsafe decodes an ID3 syncsafe integer: 7 bits in each of 4 bytes.
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+func putSyncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// embedMP4 copies the MP4 at src to dst with the transcript as its
+// lyrics. When the segments have topics, they become the chapters;
+// otherwise the file keeps the chapters it had.
+func embedMP4(ctx context.Context, ffmpeg, src, dst string, result jsonResult) error {
+	return editMetadata(ctx, ffmpeg, src, dst, result.Transcription, transcriptChapters(result), transcriptEnd(result))
+}
+
+// editMetadata copies src to dst, streams untouched, with lyrics (when
+// not empty) and chapters (when there are any, the last lasting until
+// end) replacing the file's own. The existing metadata is read back as an
+// ffmetadata file and edited, since a long transcript doesn't fit in a
+// command-line argument.
+func editMetadata(ctx context.Context, ffmpeg, src, dst, lyrics string, chapters []chapter, end float64) error {
+	var stderr bytes.Buffer
+	cmd := proc.Command(ctx, ffmpeg, "-v", "error", "-i", src, "-f", "ffmetadata", "pipe:1")
+	cmd.Stderr = &stderr
+	dump, err := cmd.Output()
+	if err != nil {
+		return &transcribe.FFmpegError{Err: err, Stderr: stderr.String()}
+	}
+	global, oldChapters, _ := strings.Cut(string(dump), "\n[CHAPTER]\n")
+	if oldChapters != "" {
+		oldChapters = "[CHAPTER]\n" + oldChapters
+	}
+
+	var meta strings.Builder
+	skipping := false
+	for _, line := range strings.SplitAfter(global, "\n") {
+		// Drop the old lyrics, including the lines of a multi-line value
+		if lyrics != "" && strings.HasPrefix(line, "lyrics=") {
+			skipping = true
+		}
+		if !skipping {
+			meta.WriteString(line)
+		}
+		trimmed := strings.TrimSuffix(line, "\n")
+		escaped := len(trimmed) - len(strings.TrimRight(trimmed, "\\"))
+		skipping = skipping && escaped%2 == 1
+	}
+	if !strings.HasSuffix(meta.String(), "\n") {
+		meta.WriteString("\n")
+	}
+	if lyrics != "" {
+		fmt.Fprintf(&meta, "lyrics=%s\n", escapeFFMetadata(lyrics))
+	}
+	if len(chapters) == 0 {
+		meta.WriteString(oldChapters)
+	}
+	for i, c := range chapters {
+		until := end
+		if i+1 < len(chapters) {
+			until = chapters[i+1].Start
+		}
+		fmt.Fprintf(&meta, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(c.Start*1000), int64(max(until, c.Start)*1000), escapeFFMetadata(c.Title))
+	}
+
+	metaFile, err := os.CreateTemp("", "gemini-transcribe-meta-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(metaFile.Name())
+	if _, err := metaFile.WriteString(meta.String()); err != nil {
+		metaFile.Close()
+		return err
+	}
+	metaFile.Close()
+
+	return runFFmpeg(ctx, ffmpeg, "-v", "error", "-y", "-i", src, "-f", "ffmetadata", "-i", metaFile.Name(),
+		"-map", "0", "-map_metadata", "1", "-map_chapters", "1", "-c", "copy", dst)
+}
+
+// escapeFFMetadata escapes a value for an ffmetadata file.
+func escapeFFMetadata(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("=;#\\\n", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// embedMKV copies the Matroska file at src to dst with the segments as an
+// SRT subtitle track, replacing the track an earlier --embed added.
+func embedMKV(ctx context.Context, ffmpeg, ffprobe, src, dst string, result jsonResult) error {
+	out, err := proc.Command(ctx, ffprobe, "-v", "error", "-show_entries", "stream=codec_type:stream_tags=title", "-of", "json", src).Output()
+	if err != nil {
+		return fmt.Errorf("ffprobe: %v", err)
+	}
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			Tags      struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return fmt.Errorf("reading ffprobe output: %v", err)
+	}
+	// The new track comes after every stream that's kept
+	kept := 0
+	for _, s := range probe.Streams {
+		if s.CodecType != "subtitle" || s.Tags.Title != embedTrackTitle {
+			kept++
+		}
+	}
+
+	srt, err := os.CreateTemp("", "gemini-transcribe-embed-*.srt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(srt.Name())
+	if _, err := srt.WriteString(transcribe.FormatSRT(result.Segments)); err != nil {
+		srt.Close()
+		return err
+	}
+	srt.Close()
+
+	track := "-metadata:s:" + strconv.Itoa(kept)
+	return runFFmpeg(ctx, ffmpeg, "-v", "error", "-y", "-i", src, "-i", srt.Name(),
+		"-map", "0", "-map", "-0:s:m:title:"+embedTrackTitle, "-map", "1", "-c", "copy",
+		track, "title="+embedTrackTitle, track, "language="+iso6392(result.Language), dst)
+}
+
+func runFFmpeg(ctx context.Context, ffmpeg string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := proc.Command(ctx, ffmpeg, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &transcribe.FFmpegError{Err: err, Stderr: stderr.String()}
+	}
+	return nil
+}
+
+// iso6392 turns an ISO 639-1 code, as Gemini reports languages, into the
+// three-letter ISO 639-2 code ID3 and Matroska want; "und" when unknown.
+func iso6392(code string) string {
+	code = strings.ToLower(code)
+	if len(code) == 3 {
+		return code
+	}
+	if c, ok := iso6392Codes[code]; ok {
+		return c
+	}
+	return "und"
+}
+
+var iso6392Codes = map[string]string{
+	"ar": "ara", "bn": "ben", "cs": "cze", "da": "dan", "de": "ger", "el": "gre",
+	"en": "eng", "es": "spa", "fa": "per", "fi": "fin", "fr": "fre", "he": "heb",
+	"hi": "hin", "hu": "hun", "id": "ind", "it": "ita", "ja": "jpn", "ko": "kor",
+	"ml": "mal", "ms": "may", "nl": "dut", "no": "nor", "pl": "pol", "pt": "por",
+	"ro": "rum", "ru": "rus", "sv": "swe", "ta": "tam", "th": "tha", "tr": "tur",
+	"uk": "ukr", "ur": "urd", "vi": "vie", "zh": "chi",
+}
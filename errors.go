@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// Exit codes by failure class, so scripts can branch on them. Invalid flags
+// exit with 2 (the flag package's choice) and anything unclassified with 1.
+const (
+	exitError    = 1
+	exitNotFound = 3
+	exitAuth     = 4
+	exitQuota    = 5
+	exitNetwork  = 6
+	exitFFmpeg   = 7
+	exitEmpty    = 8
+	exitBlocked  = 9
+	exitTooLong  = 10
+
+	// exitInterrupted is the shell's code for a process stopped by SIGINT.
+	exitInterrupted = 130
+)
+
+var (
+	errNoAPIKey    = errors.New("API key required. Use -k flag, set GEMINI_API_KEY, run gemini-transcribe config set-key, or store in ~/.config/gemini/api_key")
+	errInterrupted = errors.New("interrupted")
+)
+
+// errorClass returns the --error-json class name and exit code for err.
+func errorClass(err error) (string, int) {
+	var ffmpegErr *transcribe.FFmpegError
+	var blockedErr *transcribe.BlockedError
+	var tooLongErr *transcribe.TooLongError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errInterrupted), errors.Is(err, context.Canceled):
+		return "interrupted", exitInterrupted
+	case errors.Is(err, transcribe.ErrAuth), errors.Is(err, errNoAPIKey):
+		return "auth", exitAuth
+	case errors.Is(err, transcribe.ErrQuota):
+		return "quota", exitQuota
+	case errors.As(err, &blockedErr):
+		return "blocked", exitBlocked
+	case errors.As(err, &tooLongErr):
+		return "too_long", exitTooLong
+	case errors.As(err, &ffmpegErr):
+		return "ffmpeg", exitFFmpeg
+	case errors.Is(err, fs.ErrNotExist):
+		return "not_found", exitNotFound
+	case errors.As(err, &netErr):
+		return "network", exitNetwork
+	case errors.Is(err, transcribe.ErrNoSpeech), errors.Is(err, transcribe.ErrNoTranscription):
+		return "empty", exitEmpty
+	}
+	return "error", exitError
+}
+
+// errorJSON is the object --error-json prints to stderr.
+type errorJSON struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+
+	// Blocked carries the block reason and safety ratings when the API
+	// refused the audio.
+	Blocked *transcribe.BlockedError `json:"blocked,omitempty"`
+}
+
+// reportError logs message at error level or, with --error-json, prints
+// err to stderr as a single-line JSON object.
+func reportError(opts options, file string, err error, message string) {
+	var blocked *transcribe.BlockedError
+	errors.As(err, &blocked)
+	if !opts.errorJSON {
+		var tooLong *transcribe.TooLongError
+		switch {
+		case blocked != nil:
+			message += " (--safety none relaxes the filters)"
+		case errors.As(err, &tooLong):
+			message += " (shorten --chunk-duration to split it, or --force to send it anyway)"
+		}
+		class, code := errorClass(err)
+		slog.Error(message, "error", class, "exit_code", code, "file", file)
+		return
+	}
+	class, code := errorClass(err)
+	out, _ := json.Marshal(errorJSON{Error: class, ExitCode: code, Message: err.Error(), File: file, Blocked: blocked})
+	fmt.Fprintln(os.Stderr, string(out))
+}
+
+// fail reports err and exits with the code for its class.
+func fail(opts options, file string, err error, message string) {
+	reportError(opts, file, err, message)
+	_, code := errorClass(err)
+	os.Exit(code)
+}
+
+// notFoundError is a missing input described in words; it matches
+// fs.ErrNotExist.
+type notFoundError string
+
+func (e notFoundError) Error() string        { return string(e) }
+func (e notFoundError) Is(target error) bool { return target == fs.ErrNotExist }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// evalJSON is the eval subcommand's JSON output.
+type evalJSON struct {
+	File           string  `json:"file"`
+	Reference      string  `json:"reference"`
+	Model          string  `json:"model,omitempty"`
+	WER            float64 `json:"wer"`
+	ReferenceWords int     `json:"reference_words"`
+	Hits           int     `json:"hits"`
+	Substitutions  int     `json:"substitutions"`
+	Deletions      int     `json:"deletions"`
+	Insertions     int     `json:"insertions"`
+}
+
+// Edit operations of a word alignment.
+const (
+	opHit byte = iota
+	opSub
+	opDel // a reference word missing from the transcript
+	opIns // a transcript word not in the reference
+)
+
+// wordEdit is one step of the alignment between the reference and the
+// transcript, holding the words as written.
+type wordEdit struct {
+	op       byte
+	ref, hyp string
+}
+
+// evalWord is a word as written and as compared.
+type evalWord struct {
+	text, norm string
+}
+
+// evalWords splits text into words. Unless strict, they're compared in
+// lower case without punctuation, and words of punctuation alone (a dash)
+// are dropped.
+func evalWords(text string, strict bool) []evalWord {
+	var words []evalWord
+	for _, f := range strings.Fields(text) {
+		norm := f
+		if !strict {
+			norm = strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsNumber(r) {
+					return unicode.ToLower(r)
+				}
+				return -1
+			}, f)
+			if norm == "" {
+				continue
+			}
+		}
+		words = append(words, evalWord{f, norm})
+	}
+	return words
+}
+
+// alignWords finds the fewest substitutions, deletions and insertions
+// turning ref into hyp. Only two rows of costs are kept, but the choices
+// take a byte per word pair.
+func alignWords(ref, hyp []evalWord) []wordEdit {
+	n, m := len(ref), len(hyp)
+	ops := make([][]byte, n+1)
+	prev, cur := make([]int, m+1), make([]int, m+1)
+	ops[0] = make([]byte, m+1)
+	for j := 1; j <= m; j++ {
+		prev[j], ops[0][j] = j, opIns
+	}
+	for i := 1; i <= n; i++ {
+		ops[i] = make([]byte, m+1)
+		cur[0], ops[i][0] = i, opDel
+		for j := 1; j <= m; j++ {
+			op, cost := opSub, prev[j-1]+1
+			if ref[i-1].norm == hyp[j-1].norm {
+				op, cost = opHit, prev[j-1]
+			}
+			if c := prev[j] + 1; c < cost {
+				op, cost = opDel, c
+			}
+			if c := cur[j-1] + 1; c < cost {
+				op, cost = opIns, c
+			}
+			cur[j], ops[i][j] = cost, op
+		}
+		prev, cur = cur, prev
+	}
+
+	var edits []wordEdit
+	for i, j := n, m; i > 0 || j > 0; {
+		switch op := ops[i][j]; op {
+		case opHit, opSub:
+			edits = append(edits, wordEdit{op, ref[i-1].text, hyp[j-1].text})
+			i, j = i-1, j-1
+		case opDel:
+			edits = append(edits, wordEdit{op, ref[i-1].text, ""})
+			i--
+		default:
+			edits = append(edits, wordEdit{op, "", hyp[j-1].text})
+			j--
+		}
+	}
+	for l, r := 0, len(edits)-1; l < r; l, r = l+1, r-1 {
+		edits[l], edits[r] = edits[r], edits[l]
+	}
+	return edits
+}
+
+// renderWordDiff shows the alignment as running text, with reference
+// words the transcript got wrong or missed marked [-like this-] and the
+// transcript's words in their place {+like this+}, or in red and green
+// when color is set.
+func renderWordDiff(edits []wordEdit, color bool) string {
+	del, ins := func(s string) string { return "[-" + s + "-]" }, func(s string) string { return "{+" + s + "+}" }
+	if color {
+		del = func(s string) string { return "\x1b[31;9m" + s + "\x1b[0m" }
+		ins = func(s string) string { return "\x1b[32m" + s + "\x1b[0m" }
+	}
+	words := make([]string, 0, len(edits))
+	for _, e := range edits {
+		switch e.op {
+		case opHit:
+			words = append(words, e.hyp)
+		case opSub:
+			words = append(words, del(e.ref)+ins(e.hyp))
+		case opDel:
+			words = append(words, del(e.ref))
+		case opIns:
+			words = append(words, ins(e.hyp))
+		}
+	}
+	return strings.Join(words, " ") + "\n"
+}
+
+// readTranscript reads a transcript from a text or Markdown file, JSON
+// output (its transcription or text) or subtitles (their cue text), reporting
+// false for anything else, such as audio.
+func readTranscript(path string) (string, bool, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".txt", ".md", ".json", ".srt", ".vtt":
+	default:
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", true, err
+	}
+	switch ext {
+	case ".json":
+		// Our JSON output has the transcription, the whisper-json
+		// format its text
+		var result struct {
+			Transcription *string `json:"transcription"`
+			Text          *string `json:"text"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return "", true, fmt.Errorf("%s: %v", path, err)
+		}
+		switch {
+		case result.Transcription != nil:
+			return *result.Transcription, true, nil
+		case result.Text != nil:
+			return *result.Text, true, nil
+		}
+		return "", true, fmt.Errorf("%s: no \"transcription\" or \"text\" in the JSON", path)
+	case ".srt", ".vtt":
+		return subtitleText(string(data)), true, nil
+	}
+	return string(data), true, nil
+}
+
+// readExitCode is the exit code for a transcript that can't be read.
+func readExitCode(err error) int {
+	if os.IsNotExist(err) {
+		return exitNotFound
+	}
+	return 1
+}
+
+// tagRe matches the markup tags of subtitle cues, such as <i> and <v Anna>.
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// subtitleText returns the cue text of SRT or WebVTT subtitles, without
+// cue numbers, timings, headers or tags.
+func subtitleText(subs string) string {
+	var lines []string
+	inCue := false
+	for _, line := range strings.Split(strings.ReplaceAll(subs, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			inCue = false
+		case strings.Contains(line, "-->"):
+			inCue = true
+		case inCue:
+			line = strings.TrimPrefix(line, "- ")
+			lines = append(lines, tagRe.ReplaceAllString(line, ""))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// evalFlags are the eval command's flag values.
+type evalFlags struct {
+	outputJSON bool
+	noDiff     bool
+	strict     bool
+	noCache    bool
+	opts       options
+	g          globalFlags
+}
+
+// flagSet returns the eval command's flags, bound to v.
+func (v *evalFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Prompt, "p", "", "Custom prompt")
+	fs.StringVar(&v.opts.Prompt, "prompt", "", "Custom prompt")
+	fs.StringVar(&v.opts.Language, "language", "", "Spoken language of the audio, as a hint (e.g. German, pt)")
+	fs.BoolVar(&v.outputJSON, "json", false, "Output the scores as JSON")
+	fs.BoolVar(&v.noDiff, "no-diff", false, "Only print the scores, not the word diff")
+	fs.BoolVar(&v.strict, "strict", false, "Count differences in case and punctuation as errors")
+	fs.BoolVar(&v.noCache, "no-cache", false, "Always call the API instead of reusing a cached transcript of the same audio")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe eval [options] <file> <reference>\n\n")
+		fmt.Fprintf(os.Stderr, "Scores a transcript against a reference transcript by word error rate and shows\n")
+		fmt.Fprintf(os.Stderr, "where they differ. file is transcribed first unless it is already a transcript\n")
+		fmt.Fprintf(os.Stderr, "(.txt, .md, .json, .srt or .vtt); reference is one of those.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runEval implements the eval subcommand.
+func runEval(args []string) {
+	var v evalFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	opts, g := v.opts, v.g
+	g.initLogging(false)
+	opts.verbose = g.verbose
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	input, refFile := fs.Arg(0), fs.Arg(1)
+
+	reference, ok, err := readTranscript(refFile)
+	switch {
+	case err != nil:
+		errorf("Error: %v", err)
+		os.Exit(readExitCode(err))
+	case !ok:
+		errorf("Error: the reference must be a .txt, .md, .json, .srt or .vtt transcript")
+		os.Exit(1)
+	}
+	refWords := evalWords(reference, v.strict)
+	if len(refWords) == 0 {
+		errorf("Error: %s has no words", refFile)
+		os.Exit(1)
+	}
+
+	hypothesis, ok, err := readTranscript(input)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(readExitCode(err))
+	}
+	var model string
+	if !ok {
+		if _, err := os.Stat(input); err != nil && !isURL(input) && !isObject(input) {
+			errorf("Error: File not found: %s", input)
+			os.Exit(exitNotFound)
+		}
+		opts.Upload = "auto"
+		opts.ChunkDuration = transcribe.DefaultChunkDuration
+		opts.ChunkOverlap = transcribe.DefaultChunkOverlap
+		opts.MaxContinuations = transcribe.DefaultMaxContinuations
+
+		client := g.newClient()
+		if !v.noCache {
+			client.Cache = newCache()
+		}
+		if opts.verbose {
+			client.Logf = debugf
+		}
+		ctx, stop := interruptContext()
+		defer stop()
+		result, err := transcribeFile(ctx, client, input, opts)
+		if err != nil {
+			_, code := errorClass(err)
+			errorf("Error: %v", err)
+			os.Exit(code)
+		}
+		debugf("%s", client.Stats.Snapshot().Summary())
+		hypothesis, model = result.Transcription, result.Model
+	}
+
+	edits := alignWords(refWords, evalWords(hypothesis, v.strict))
+	score := evalJSON{File: input, Reference: refFile, Model: model, ReferenceWords: len(refWords)}
+	for _, e := range edits {
+		switch e.op {
+		case opHit:
+			score.Hits++
+		case opSub:
+			score.Substitutions++
+		case opDel:
+			score.Deletions++
+		case opIns:
+			score.Insertions++
+		}
+	}
+	score.WER = float64(score.Substitutions+score.Deletions+score.Insertions) / float64(score.ReferenceWords)
+
+	if v.outputJSON {
+		out, _ := json.MarshalIndent(score, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if !v.noDiff {
+		fmt.Println(renderWordDiff(edits, isTerminal(os.Stdout)))
+	}
+	fmt.Printf("WER %.2f%% over %d reference words: %d substitutions, %d deletions, %d insertions\n",
+		score.WER*100, score.ReferenceWords, score.Substitutions, score.Deletions, score.Insertions)
+}
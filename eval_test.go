@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEvalWords(t *testing.T) {
+	tests := []struct {
+		text   string
+		strict bool
+		want   []evalWord
+	}{
+		{"Hello, World!", false, []evalWord{{"Hello,", "hello"}, {"World!", "world"}}},
+		{"Hello, World!", true, []evalWord{{"Hello,", "Hello,"}, {"World!", "World!"}}},
+		{"well - it's 42", false, []evalWord{{"well", "well"}, {"it's", "its"}, {"42", "42"}}},
+		{"  \n ", false, nil},
+	}
+	for _, tt := range tests {
+		if got := evalWords(tt.text, tt.strict); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("evalWords(%q, %v) = %v, want %v", tt.text, tt.strict, got, tt.want)
+		}
+	}
+}
+
+func TestAlignWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		ref, hyp string
+		want     []wordEdit
+	}{
+		{"identical", "the cat sat", "The cat sat.", []wordEdit{{opHit, "the", "The"}, {opHit, "cat", "cat"}, {opHit, "sat", "sat."}}},
+		{"substitution", "the cat sat", "the hat sat", []wordEdit{{opHit, "the", "the"}, {opSub, "cat", "hat"}, {opHit, "sat", "sat"}}},
+		{"deletion", "the big cat", "the cat", []wordEdit{{opHit, "the", "the"}, {opDel, "big", ""}, {opHit, "cat", "cat"}}},
+		{"insertion", "the cat", "the fat cat", []wordEdit{{opHit, "the", "the"}, {opIns, "", "fat"}, {opHit, "cat", "cat"}}},
+		{"empty transcript", "a b", "", []wordEdit{{opDel, "a", ""}, {opDel, "b", ""}}},
+		{"empty reference", "", "a", []wordEdit{{opIns, "", "a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alignWords(evalWords(tt.ref, false), evalWords(tt.hyp, false))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alignWords(%q, %q) = %v, want %v", tt.ref, tt.hyp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWordDiff(t *testing.T) {
+	edits := []wordEdit{{opHit, "the", "the"}, {opSub, "cat", "hat"}, {opDel, "sat", ""}, {opIns, "", "down"}}
+	if got, want := renderWordDiff(edits, false), "the [-cat-]{+hat+} [-sat-] {+down+}\n"; got != want {
+		t.Errorf("renderWordDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestSubtitleText(t *testing.T) {
+	tests := []struct {
+		name, subs, want string
+	}{
+		{
+			"srt",
+			"1\r\n00:00:00,000 --> 00:00:01,000\r\n<i>Hello</i> there.\r\n\r\n2\r\n00:00:01,000 --> 00:00:02,000\r\n- Two\r\n- lines\r\n",
+			"Hello there.\nTwo\nlines",
+		},
+		{
+			"vtt",
+			"WEBVTT\n\nNOTE a comment\n\n1\n00:00:00.000 --> 00:00:01.000 line:90%\n<v Anna>Hi, <00:00:00.500>all.\n",
+			"Hi, all.",
+		},
+		{"no cues", "WEBVTT\n", ""},
+	}
+	for _, tt := range tests {
+		if got := subtitleText(tt.subs); got != tt.want {
+			t.Errorf("%s: subtitleText() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadTranscript(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, data string
+		want       string
+		ok         bool
+		err        bool
+	}{
+		{"t.txt", "plain text\n", "plain text\n", true, false},
+		{"t.json", `{"transcription": "from json", "text": "ignored"}`, "from json", true, false},
+		{"w.json", `{"text": "whisper"}`, "whisper", true, false},
+		{"e.json", `{"transcription": ""}`, "", true, false},
+		{"n.json", `{"segments": []}`, "", true, true},
+		{"bad.json", `{`, "", true, true},
+		{"t.srt", "1\n00:00:00,000 --> 00:00:01,000\nsubtitle\n", "subtitle", true, false},
+		{"a.mp3", "ID3", "", false, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, ok, err := readTranscript(path)
+		if got != tt.want || ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("readTranscript(%s) = %q, %v, %v; want %q, %v, error %v", tt.name, got, ok, err, tt.want, tt.ok, tt.err)
+		}
+	}
+	if _, ok, err := readTranscript(filepath.Join(dir, "missing.txt")); !ok || !os.IsNotExist(err) {
+		t.Errorf("missing file: ok %v, err %v", ok, err)
+	}
+}
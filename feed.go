@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// feedStateFile records which episodes of a feed have been transcribed. It
+// lives in the output directory, so each directory follows its feed on its
+// own.
+const feedStateFile = "gemini-transcribe-feed.json"
+
+// episode is one item of a podcast feed with an audio enclosure.
+type episode struct {
+	GUID      string
+	Title     string
+	Published time.Time
+	URL       string
+}
+
+// feedState maps the GUIDs of transcribed episodes to what was written.
+type feedState struct {
+	Feed     string                    `json:"feed"`
+	Episodes map[string]feedStateEntry `json:"episodes"`
+}
+
+type feedStateEntry struct {
+	Title  string `json:"title"`
+	Output string `json:"output"`
+}
+
+// feedFlags are the feed command's flag values.
+type feedFlags struct {
+	promptFile string
+	vocabFile  string
+	rulesFile  string
+	list       bool
+	pick       string
+	latest     int
+	noCache    bool
+	opts       options
+	g          globalFlags
+}
+
+// flagSet returns the feed command's flags, bound to v.
+func (v *feedFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("feed", flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Prompt, "p", "", "Custom prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.opts.Prompt, "prompt", "", "Custom prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.promptFile, "prompt-file", "", "Read the prompt from a file (or set GEMINI_PROMPT_FILE)")
+	fs.StringVar(&v.opts.Language, "language", "", "Spoken language of the episodes, as a hint (e.g. German, pt)")
+	fs.StringVar(&v.rulesFile, "rules", "", "YAML file of find/replace rules (plain or regex) applied to the transcript")
+	fs.StringVar(&v.vocabFile, "vocab", "", "File of names and terms, one per line, to spell exactly (added to the prompt and corrected afterwards)")
+	fs.StringVar(&v.opts.format, "f", "text", "Output format: text, json, srt, vtt, md, whisper-json, chapters")
+	fs.StringVar(&v.opts.format, "format", "text", "Output format: text, json, srt, vtt, md, whisper-json, chapters")
+	fs.StringVar(&v.opts.output, "o", ".", "Directory for the transcripts and the feed's state file")
+	fs.StringVar(&v.opts.output, "output", ".", "Directory for the transcripts and the feed's state file")
+	fs.BoolVar(&v.list, "list", false, "List the feed's episodes, newest first, and exit")
+	fs.StringVar(&v.pick, "episodes", "", "Transcribe these episodes by their --list number, e.g. 1,3-5, even if done before")
+	fs.IntVar(&v.latest, "latest", 0, "Only consider the N newest episodes (0 for all)")
+	fs.BoolVar(&v.noCache, "no-cache", false, "Always call the API instead of reusing a cached transcript of the same audio")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe feed [options] <rss-url>\n\n")
+		fmt.Fprintf(os.Stderr, "Downloads and transcribes the episodes of a podcast feed that haven't been\n")
+		fmt.Fprintf(os.Stderr, "transcribed yet, remembering them in %s in the output directory.\n\n", feedStateFile)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runFeed implements the feed subcommand.
+func runFeed(args []string) {
+	var v feedFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	opts, g := v.opts, v.g
+	g.initLogging(false)
+	opts.verbose = g.verbose
+	if fs.NArg() != 1 || !isURL(fs.Arg(0)) {
+		fs.Usage()
+		os.Exit(1)
+	}
+	feedURL := fs.Arg(0)
+	switch opts.format {
+	case "text", "json", "srt", "vtt", "md", "whisper-json", "chapters":
+	default:
+		errorf("Error: Unknown format %q (want text, json, srt, vtt, md, whisper-json or chapters)", opts.format)
+		os.Exit(1)
+	}
+	if v.latest < 0 {
+		errorf("Error: --latest can't be negative")
+		os.Exit(1)
+	}
+
+	httpClient, err := newHTTPClient(g.proxy)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	episodes, err := fetchFeed(ctx, httpClient, feedURL)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	if v.latest > 0 && len(episodes) > v.latest {
+		episodes = episodes[:v.latest]
+	}
+	statePath := filepath.Join(opts.output, feedStateFile)
+	state, err := loadFeedState(statePath, feedURL)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	if v.list {
+		printEpisodes(episodes, state)
+		return
+	}
+
+	var todo []episode
+	if v.pick != "" {
+		if todo, err = pickEpisodes(episodes, v.pick); err != nil {
+			errorf("Error: --episodes: %v", err)
+			os.Exit(1)
+		}
+	} else {
+		for _, e := range episodes {
+			if _, done := state.Episodes[e.GUID]; !done {
+				todo = append(todo, e)
+			}
+		}
+	}
+	if len(todo) == 0 {
+		infof("No new episodes in %s", feedURL)
+		return
+	}
+
+	if opts.Prompt == "" {
+		if opts.Prompt, err = resolvePrompt(v.promptFile); err != nil {
+			errorf("Error reading prompt: %v", err)
+			os.Exit(1)
+		}
+	}
+	if v.vocabFile != "" {
+		if opts.Vocabulary, err = readVocabulary(v.vocabFile); err != nil {
+			errorf("Error reading vocabulary: %v", err)
+			os.Exit(1)
+		}
+	}
+	if v.rulesFile != "" {
+		if opts.Rules, err = loadRules(v.rulesFile); err != nil {
+			errorf("Error reading rules: %v", err)
+			os.Exit(1)
+		}
+	}
+	if err := os.MkdirAll(opts.output, 0755); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	opts.Timestamps = opts.format != "text"
+	opts.DetectLanguage = hasLanguage(opts.format)
+	opts.Topics = opts.format == "md" || opts.format == "chapters"
+	opts.Upload = "auto"
+	opts.ChunkDuration = transcribe.DefaultChunkDuration
+	opts.ChunkOverlap = transcribe.DefaultChunkOverlap
+	opts.MaxContinuations = transcribe.DefaultMaxContinuations
+
+	client := g.newClient()
+	if !v.noCache {
+		client.Cache = newCache()
+	}
+	if opts.verbose {
+		client.Logf = debugf
+	}
+
+	var failed []string
+	succeeded, exitCode := 0, 0
+	for i, e := range todo {
+		if ctx.Err() != nil {
+			warnf("Interrupted with %d episodes left", len(todo)-i)
+			exitCode = exitInterrupted
+			break
+		}
+		outPath, err := transcribeEpisode(ctx, client, e, opts)
+		if ctx.Err() != nil {
+			warnf("Interrupted with %d episodes left", len(todo)-i)
+			exitCode = exitInterrupted
+			break
+		}
+		if err != nil {
+			errorf("[%d/%d] %s: Error %v", i+1, len(todo), e.Title, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", e.Title, err))
+			if _, code := errorClass(err); exitCode == 0 || exitCode == code {
+				exitCode = code
+			} else {
+				exitCode = exitError
+			}
+			continue
+		}
+		infof("[%d/%d] %s -> %s", i+1, len(todo), e.Title, outPath)
+		succeeded++
+		state.Episodes[e.GUID] = feedStateEntry{Title: e.Title, Output: outPath}
+		if err := saveFeedState(statePath, state); err != nil {
+			warnf("Warning: can't save feed state: %v", err)
+		}
+	}
+
+	infof("\nTranscribed %d of %d episodes", succeeded, len(todo))
+	if len(failed) > 0 {
+		errorf("Failed (%d):\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	debugf("%s", client.Stats.Snapshot().Summary())
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// transcribeEpisode downloads and transcribes one episode and writes its
+// transcript to the output directory, named after its date and title.
+func transcribeEpisode(ctx context.Context, client *transcribe.Client, e episode, opts options) (string, error) {
+	result, err := transcribeFile(ctx, client, e.URL, opts)
+	if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+		return "", err
+	}
+	result.Title = e.Title
+	outPath := filepath.Join(opts.output, episodeFileName(e)+outputExt(opts.format))
+	if err := writeResult(result, outPath, opts); err != nil {
+		return "", fmt.Errorf("writing output: %v", err)
+	}
+	return outPath, nil
+}
+
+// rssFeed is the part of an RSS 2.0 document the feed command reads.
+type rssFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title     string `xml:"title"`
+			GUID      string `xml:"guid"`
+			PubDate   string `xml:"pubDate"`
+			Enclosure struct {
+				URL  string `xml:"url,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// fetchFeed downloads an RSS feed and returns its episodes with audio or
+// video enclosures, newest first. Episodes without a GUID are identified by
+// their enclosure URL.
+func fetchFeed(ctx context.Context, httpClient *http.Client, feedURL string) ([]episode, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: HTTP %d", feedURL, resp.StatusCode)
+	}
+	var feed rssFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", feedURL, err)
+	}
+
+	var episodes []episode
+	for _, item := range feed.Channel.Items {
+		url := strings.TrimSpace(item.Enclosure.URL)
+		if url == "" || item.Enclosure.Type != "" && !strings.HasPrefix(item.Enclosure.Type, "audio/") && !strings.HasPrefix(item.Enclosure.Type, "video/") {
+			continue
+		}
+		e := episode{
+			GUID:      strings.TrimSpace(item.GUID),
+			Title:     strings.TrimSpace(item.Title),
+			Published: parsePubDate(item.PubDate),
+			URL:       url,
+		}
+		if e.GUID == "" {
+			e.GUID = url
+		}
+		if e.Title == "" {
+			e.Title = urlBaseName(url)
+		}
+		episodes = append(episodes, e)
+	}
+	if len(episodes) == 0 {
+		return nil, fmt.Errorf("%s has no episodes with audio", feedURL)
+	}
+	sort.SliceStable(episodes, func(i, j int) bool { return episodes[i].Published.After(episodes[j].Published) })
+	return episodes, nil
+}
+
+// pubDateLayouts are the date formats seen in RSS pubDate elements: RFC
+// 822 with and without the weekday, with numeric or named zones.
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+}
+
+// parsePubDate parses an RSS pubDate, returning the zero time for a date
+// it doesn't recognise.
+func parsePubDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// printEpisodes lists episodes with the numbers --episodes takes, marking
+// the ones already transcribed.
+func printEpisodes(episodes []episode, state feedState) {
+	for i, e := range episodes {
+		date := "          "
+		if !e.Published.IsZero() {
+			date = e.Published.Format("2006-01-02")
+		}
+		mark := ""
+		if _, done := state.Episodes[e.GUID]; done {
+			mark = "  (done)"
+		}
+		fmt.Printf("%4d  %s  %s%s\n", i+1, date, e.Title, mark)
+	}
+}
+
+// pickEpisodes selects episodes by their 1-based numbers, given as a comma
+// list of numbers and ranges such as 1,3-5.
+func pickEpisodes(episodes []episode, spec string) ([]episode, error) {
+	var picked []episode
+	seen := map[int]bool{}
+	for _, part := range splitList(spec) {
+		from, to, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid episode number %q", part)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid episode number %q", part)
+			}
+		}
+		if lo < 1 || hi > len(episodes) || lo > hi {
+			return nil, fmt.Errorf("%s is out of range (the feed has %d episodes)", part, len(episodes))
+		}
+		for n := lo; n <= hi; n++ {
+			if !seen[n] {
+				seen[n] = true
+				picked = append(picked, episodes[n-1])
+			}
+		}
+	}
+	return picked, nil
+}
+
+// episodeFileName names an episode's transcript after its date and title,
+// without the characters file systems reject: 2026-10-01 Episode 12.
+func episodeFileName(e episode) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '-'
+		}
+		return r
+	}, strings.ReplaceAll(e.Title, ": ", " - "))
+	name = strings.Trim(name, ". ")
+	if r := []rune(name); len(r) > 120 {
+		name = strings.TrimSpace(string(r[:120]))
+	}
+	if !e.Published.IsZero() {
+		name = e.Published.Format("2006-01-02") + " " + name
+	}
+	return name
+}
+
+// loadFeedState reads the state file at path. A missing file is an empty
+// state; one written for a different feed is an error, so two feeds don't
+// share a directory by accident.
+func loadFeedState(path, feedURL string) (feedState, error) {
+	state := feedState{Feed: feedURL, Episodes: map[string]feedStateEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("reading %s: %v", path, err)
+	}
+	if state.Feed != feedURL {
+		return state, fmt.Errorf("%s belongs to %s; use another --output directory for this feed", path, state.Feed)
+	}
+	if state.Episodes == nil {
+		state.Episodes = map[string]feedStateEntry{}
+	}
+	return state, nil
+}
+
+// saveFeedState writes the state file, after each episode so an
+// interrupted run keeps what it finished.
+func saveFeedState(path string, state feedState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// ffmpegBinary is the ffmpeg the client runs, for the CLI's own ffmpeg
+// calls.
+func ffmpegBinary(client *transcribe.Client) string {
+	if client.FFmpegPath != "" {
+		return client.FFmpegPath
+	}
+	return "ffmpeg"
+}
+
+// ffprobeBinary returns the ffprobe binary the client runs.
+func ffprobeBinary(client *transcribe.Client) string {
+	if client.FFprobePath != "" {
+		return client.FFprobePath
+	}
+	return "ffprobe"
+}
+
+// ffprobeNextTo returns the ffprobe beside an --ffmpeg-path binary, or ""
+// to keep using the one on the PATH.
+func ffprobeNextTo(ffmpegPath string) string {
+	dir, name := filepath.Split(ffmpegPath)
+	if dir == "" {
+		return ""
+	}
+	probe := filepath.Join(dir, strings.Replace(name, "ffmpeg", "ffprobe", 1))
+	if _, err := os.Stat(probe); err != nil {
+		return ""
+	}
+	return probe
+}
+
+// splitArgs splits an --ffmpeg-args value into arguments the way a shell
+// would: on whitespace, with single and double quotes grouping and a
+// backslash escaping the next character.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
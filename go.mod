@@ -1,3 +1,10 @@
-module gemini-transcribe
+module github.com/mukhtharcm/gemini-transcribe
 
 go 1.25.5
+
+require (
+	github.com/fsnotify/fsnotify v1.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.13.0 // indirect
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/protowire"
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// grpcTranscribePath is the Transcriber.Transcribe method from
+// proto/transcriber.proto, the only one the gRPC server has.
+const grpcTranscribePath = "/geminitranscribe.v1.Transcriber/Transcribe"
+
+// gRPC status codes the Transcriber service answers with.
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+// grpcStatusError is an RPC failure with its gRPC status code.
+type grpcStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcStatusError) Error() string { return e.msg }
+
+// serveGRPC serves the Transcriber service on addr over cleartext HTTP/2,
+// which is how gRPC clients connect without TLS.
+func (s *server) serveGRPC(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+grpcTranscribePath, s.handleGRPCTranscribe)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		writeGRPCStatus(w, grpcUnimplemented, "unknown method "+r.URL.Path)
+	})
+	srv := &http.Server{Addr: addr, Handler: withVersion(s.withAuth(mux)), Protocols: new(http.Protocols)}
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return srv.ListenAndServe()
+}
+
+// handleGRPCTranscribe reads the audio from the client's stream of
+// TranscribeRequests and, once it's complete, streams back a Segment per
+// transcript segment and a final Result. The config in the first message
+// overrides the server's defaults as the /transcribe fields do.
+func (s *server) handleGRPCTranscribe(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if ct := r.Header.Get("Content-Type"); ct != "application/grpc" && ct != "application/grpc+proto" {
+		http.Error(w, "unsupported Content-Type "+ct, http.StatusUnsupportedMediaType)
+		return
+	}
+	// gRPC reports failures in the trailers, so the headers always say 200
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	if enc := r.Header.Get("Grpc-Encoding"); enc != "" && enc != "identity" {
+		writeGRPCStatus(w, grpcUnimplemented, fmt.Sprintf("compression %q is not supported", enc))
+		return
+	}
+	if v := r.Header.Get("Grpc-Timeout"); v != "" {
+		timeout, err := parseGRPCTimeout(v)
+		if err != nil {
+			writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	opts := s.defaults
+	opts.Timestamps = true
+	opts.DetectLanguage = true
+	tmpPath, name, size, err := s.receiveGRPCAudio(r.Body, &opts)
+	if err != nil {
+		errorf("gRPC Transcribe: %v", err)
+		writeGRPCError(w, err)
+		return
+	}
+	defer os.Remove(tmpPath)
+
+	result, snap, err := s.transcribeUpload(r.Context(), "grpc", tmpPath, name, opts)
+	noSpeech := errors.Is(err, transcribe.ErrNoSpeech)
+	if err != nil && !noSpeech {
+		errorf("gRPC Transcribe %s: %v", name, err)
+		writeGRPCError(w, err)
+		return
+	}
+	infof("gRPC Transcribe %s (%d bytes) in %s", name, size, time.Since(start).Round(time.Millisecond))
+
+	rc := http.NewResponseController(w)
+	for _, seg := range result.Segments {
+		if err := writeGRPCMessage(w, protowire.AppendMessage(nil, 1, encodeSegment(seg))); err != nil {
+			return
+		}
+		rc.Flush()
+	}
+	res := protowire.AppendString(nil, 1, result.Transcription)
+	res = protowire.AppendString(res, 2, result.Model)
+	res = protowire.AppendString(res, 3, result.Language)
+	res = protowire.AppendDouble(res, 4, result.Duration)
+	res = protowire.AppendMessage(res, 5, encodeUsage(usageFor(result.Model, snap, true)))
+	res = protowire.AppendBool(res, 6, noSpeech)
+	if err := writeGRPCMessage(w, protowire.AppendMessage(nil, 2, res)); err != nil {
+		return
+	}
+	writeGRPCStatus(w, grpcOK, "")
+}
+
+// receiveGRPCAudio reads TranscribeRequests until the client closes its
+// stream, applying the first one's config to opts and saving the audio to
+// a temp file. It returns the file, the upload's name and its size.
+func (s *server) receiveGRPCAudio(body io.Reader, opts *options) (string, string, int64, error) {
+	var (
+		tmp  *os.File
+		name string
+		size int64
+	)
+	fail := func(err error) (string, string, int64, error) {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		return "", "", 0, err
+	}
+	for first := true; ; first = false {
+		msg, err := readGRPCMessage(body, s.maxUpload)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+		fields, err := protowire.Parse(msg)
+		if err != nil {
+			return fail(&grpcStatusError{grpcInvalidArgument, err.Error()})
+		}
+		if first {
+			for _, f := range fields {
+				if f.Num == 1 && f.Type == protowire.BytesType {
+					if name, err = applyGRPCConfig(f.Bytes, opts); err != nil {
+						return fail(err)
+					}
+				}
+			}
+			// Keep the extension for MIME detection, as saveUpload does
+			tmp, err = os.CreateTemp("", "gemini-transcribe-upload-*"+strings.ToLower(filepath.Ext(name)))
+			if err != nil {
+				return fail(err)
+			}
+		}
+		for _, f := range fields {
+			if f.Num != 2 || f.Type != protowire.BytesType {
+				continue
+			}
+			if size += int64(len(f.Bytes)); size > s.maxUpload {
+				return fail(&grpcStatusError{grpcResourceExhausted, fmt.Sprintf("audio is larger than %d MB", s.maxUpload>>20)})
+			}
+			if _, err := tmp.Write(f.Bytes); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if tmp == nil || size == 0 {
+		return fail(&grpcStatusError{grpcInvalidArgument, "no audio received"})
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", 0, err
+	}
+	if name == "" {
+		name = "audio"
+	}
+	return tmp.Name(), name, size, nil
+}
+
+// applyGRPCConfig sets the Config message's non-empty fields on opts and
+// returns its filename.
+func applyGRPCConfig(msg []byte, opts *options) (string, error) {
+	fields, err := protowire.Parse(msg)
+	if err != nil {
+		return "", &grpcStatusError{grpcInvalidArgument, "config: " + err.Error()}
+	}
+	var name string
+	for _, f := range fields {
+		switch f.Num {
+		case 1:
+			name = string(f.Bytes)
+		case 2:
+			opts.Model = string(f.Bytes)
+		case 3:
+			opts.Prompt = string(f.Bytes)
+		case 4:
+			opts.Language = string(f.Bytes)
+		case 5:
+			opts.TranslateTo = string(f.Bytes)
+		case 6:
+			opts.Words = f.Varint != 0
+		}
+	}
+	return name, nil
+}
+
+// readGRPCMessage reads one length-prefixed message from a gRPC stream,
+// returning io.EOF at the end of the stream.
+func readGRPCMessage(r io.Reader, limit int64) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, &grpcStatusError{grpcInvalidArgument, "truncated message"}
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcStatusError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if int64(n) > limit {
+		return nil, &grpcStatusError{grpcResourceExhausted, fmt.Sprintf("message of %d bytes is too large", n)}
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &grpcStatusError{grpcInvalidArgument, "truncated message"}
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w io.Writer, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// writeGRPCStatus ends the call with the grpc-status and grpc-message
+// trailers.
+func writeGRPCStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeGRPCMessage(msg))
+	}
+}
+
+// writeGRPCError ends the call with the status code for err.
+func writeGRPCError(w http.ResponseWriter, err error) {
+	var statusErr *grpcStatusError
+	if errors.As(err, &statusErr) {
+		writeGRPCStatus(w, statusErr.code, statusErr.msg)
+		return
+	}
+	writeGRPCStatus(w, grpcCode(err), err.Error())
+}
+
+// grpcCode maps a transcription error to the closest gRPC status code, by
+// the same classes as the CLI's exit codes.
+func grpcCode(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return grpcDeadlineExceeded
+	}
+	switch class, _ := errorClass(err); class {
+	case "interrupted":
+		return grpcCanceled
+	case "quota":
+		return grpcResourceExhausted
+	case "blocked":
+		return grpcFailedPrecondition
+	case "too_long", "ffmpeg":
+		return grpcInvalidArgument
+	case "network":
+		return grpcUnavailable
+	}
+	return grpcInternal
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message value, which must be
+// printable ASCII.
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseGRPCTimeout parses a grpc-timeout header: up to 8 digits and a unit.
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	unit, ok := units[v[len(v)-1]]
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func encodeSegment(seg transcribe.Segment) []byte {
+	b := protowire.AppendDouble(nil, 1, seg.Start)
+	b = protowire.AppendDouble(b, 2, seg.End)
+	b = protowire.AppendString(b, 3, seg.Text)
+	b = protowire.AppendString(b, 4, seg.Speaker)
+	for _, w := range seg.Words {
+		word := protowire.AppendString(nil, 1, w.Word)
+		word = protowire.AppendDouble(word, 2, w.Start)
+		word = protowire.AppendDouble(word, 3, w.End)
+		b = protowire.AppendMessage(b, 5, word)
+	}
+	return b
+}
+
+func encodeUsage(u *usageJSON) []byte {
+	b := protowire.AppendInt(nil, 1, int64(u.PromptTokens))
+	b = protowire.AppendInt(b, 2, int64(u.OutputTokens))
+	b = protowire.AppendInt(b, 3, int64(u.TotalTokens))
+	if u.CostUSD != nil {
+		b = protowire.AppendDouble(b, 4, *u.CostUSD)
+	}
+	return b
+}
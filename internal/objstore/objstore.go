@@ -0,0 +1,186 @@
+// Package objstore reads, writes and lists objects in Amazon S3 (s3://) and
+// Google Cloud Storage (gs://) over their HTTP APIs, so cloud inputs and
+// outputs need no SDK or CLI installed.
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned for objects and buckets that don't exist.
+var ErrNotFound = errors.New("object not found")
+
+// TokenSource supplies OAuth access tokens for Cloud Storage.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Store talks to S3 and Cloud Storage. The zero value works: credentials
+// are looked up on first use.
+type Store struct {
+	// HTTPClient sends the requests; nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// GCSTokens authorizes Cloud Storage requests. When nil, GCSTokens
+	// is set up by NewGCSTokens on the first gs:// request.
+	GCSTokens TokenSource
+	// NewGCSTokens returns the Cloud Storage token source on first use.
+	NewGCSTokens func() (TokenSource, error)
+
+	once   sync.Once
+	s3     *s3Config
+	s3Err  error
+	gcsMu  sync.Mutex
+	gcsErr error
+}
+
+// IsURI reports whether s names an object: s3://bucket/key or
+// gs://bucket/key.
+func IsURI(s string) bool {
+	return strings.HasPrefix(s, "s3://") || strings.HasPrefix(s, "gs://")
+}
+
+// Location is a parsed object URI.
+type Location struct {
+	Scheme string // "s3" or "gs"
+	Bucket string
+	Key    string
+}
+
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Key
+}
+
+// Parse splits an s3:// or gs:// URI into its bucket and key.
+func Parse(uri string) (Location, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok || scheme != "s3" && scheme != "gs" {
+		return Location{}, fmt.Errorf("%s is not an s3:// or gs:// URI", uri)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return Location{}, fmt.Errorf("%s has no bucket", uri)
+	}
+	return Location{Scheme: scheme, Bucket: bucket, Key: key}, nil
+}
+
+// Get opens the object at uri for reading, along with its size (-1 when
+// unknown). The caller closes the reader.
+func (s *Store) Get(ctx context.Context, uri string) (io.ReadCloser, int64, error) {
+	loc, err := Parse(uri)
+	if err != nil {
+		return nil, 0, err
+	}
+	var resp *http.Response
+	if loc.Scheme == "s3" {
+		resp, err = s.s3Do(ctx, "GET", loc, nil, nil, "")
+	} else {
+		resp, err = s.gcsGet(ctx, loc, true)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := checkResponse(resp, uri); err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Put writes data to the object at uri, replacing any existing one.
+func (s *Store) Put(ctx context.Context, uri string, data []byte, contentType string) error {
+	loc, err := Parse(uri)
+	if err != nil {
+		return err
+	}
+	var resp *http.Response
+	if loc.Scheme == "s3" {
+		resp, err = s.s3Do(ctx, "PUT", loc, nil, data, contentType)
+	} else {
+		resp, err = s.gcsPut(ctx, loc, data, contentType)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp, uri)
+}
+
+// Exists reports whether the object at uri exists.
+func (s *Store) Exists(ctx context.Context, uri string) (bool, error) {
+	loc, err := Parse(uri)
+	if err != nil {
+		return false, err
+	}
+	var resp *http.Response
+	if loc.Scheme == "s3" {
+		resp, err = s.s3Do(ctx, "HEAD", loc, nil, nil, "")
+	} else {
+		resp, err = s.gcsGet(ctx, loc, false)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, uri)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List returns the URIs of the objects directly under the prefix uri (a
+// "directory" ending in /), not those in nested prefixes.
+func (s *Store) List(ctx context.Context, uri string) ([]string, error) {
+	loc, err := Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if loc.Scheme == "s3" {
+		keys, err = s.s3List(ctx, loc)
+	} else {
+		keys, err = s.gcsList(ctx, loc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	uris := make([]string, len(keys))
+	for i, key := range keys {
+		uris[i] = Location{Scheme: loc.Scheme, Bucket: loc.Bucket, Key: key}.String()
+	}
+	return uris, nil
+}
+
+func (s *Store) httpClient() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// checkResponse turns an error status into an error, closing the body.
+func checkResponse(resp *http.Response, uri string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: %w", uri, ErrNotFound)
+	case http.StatusMovedPermanently:
+		if region := resp.Header.Get("X-Amz-Bucket-Region"); region != "" {
+			return fmt.Errorf("%s: the bucket is in %s (set AWS_REGION=%s)", uri, region, region)
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return fmt.Errorf("%s: HTTP %d: %s", uri, resp.StatusCode, msg)
+}
@@ -0,0 +1,277 @@
+package objstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Config is what S3 requests are signed and sent with, from the same
+// environment variables and ~/.aws files as the AWS CLI.
+type s3Config struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	// endpoint replaces AWS for S3-compatible stores (MinIO, R2), which
+	// are addressed path-style
+	endpoint string
+}
+
+// loadS3Config reads credentials from AWS_ACCESS_KEY_ID and friends or
+// the shared credentials file, for AWS_PROFILE (default "default").
+func loadS3Config() (*s3Config, error) {
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	c := &s3Config{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       os.Getenv("AWS_REGION"),
+		endpoint:     os.Getenv("AWS_ENDPOINT_URL_S3"),
+	}
+	if c.accessKey == "" {
+		creds := readAWSFile("AWS_SHARED_CREDENTIALS_FILE", "credentials", profile)
+		c.accessKey = creds["aws_access_key_id"]
+		c.secretKey = creds["aws_secret_access_key"]
+		c.sessionToken = creds["aws_session_token"]
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, errors.New("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or add them to ~/.aws/credentials")
+	}
+	if c.region == "" {
+		c.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	section := "profile " + profile
+	if profile == "default" {
+		section = "default"
+	}
+	config := readAWSFile("AWS_CONFIG_FILE", "config", section)
+	if c.region == "" {
+		c.region = config["region"]
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.endpoint == "" {
+		c.endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if c.endpoint == "" {
+		c.endpoint = config["endpoint_url"]
+	}
+	c.endpoint = strings.TrimSuffix(c.endpoint, "/")
+	return c, nil
+}
+
+// readAWSFile returns the keys of one [section] of an AWS INI file, at
+// the path in env or ~/.aws/name.
+func readAWSFile(env, name, section string) map[string]string {
+	path := os.Getenv(env)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".aws", name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	values := map[string]string{}
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			current = strings.TrimSpace(strings.Trim(line, "[]"))
+		case current == section:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return values
+}
+
+func (s *Store) s3Config() (*s3Config, error) {
+	s.once.Do(func() { s.s3, s.s3Err = loadS3Config() })
+	return s.s3, s.s3Err
+}
+
+// s3Do sends a request for loc signed with Signature Version 4.
+func (s *Store) s3Do(ctx context.Context, method string, loc Location, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	c, err := s.s3Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// S3-compatible endpoints and dotted bucket names (which don't match
+	// the wildcard certificate) are addressed path-style
+	scheme, host, path := "https://", loc.Bucket+".s3."+c.region+".amazonaws.com", "/"+loc.Key
+	if c.endpoint != "" || strings.Contains(loc.Bucket, ".") {
+		host, path = "s3."+c.region+".amazonaws.com", ""
+		if c.endpoint != "" {
+			u, err := url.Parse(c.endpoint)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("invalid S3 endpoint %q", c.endpoint)
+			}
+			scheme, host, path = u.Scheme+"://", u.Host, strings.TrimSuffix(u.Path, "/")
+		}
+		path += "/" + loc.Bucket
+		if loc.Key != "" {
+			path += "/" + loc.Key
+		}
+	}
+
+	canonicalPath := uriEncode(path, false)
+	canonicalQuery := canonicalQueryString(query)
+	rawURL := scheme + host + canonicalPath
+	if canonicalQuery != "" {
+		rawURL += "?" + canonicalQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	c.sign(req, canonicalPath, sha256Hex(body), time.Now())
+	return s.httpClient().Do(req)
+}
+
+// sign adds the Signature Version 4 headers to req, whose path is
+// canonicalPath and whose query is already canonical, as of now.
+func (c *s3Config) sign(req *http.Request, canonicalPath, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headers := "host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n"
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+		signed = append(signed, "x-amz-security-token")
+		headers += "x-amz-security-token:" + c.sessionToken + "\n"
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonicalRequest := strings.Join([]string{req.Method, canonicalPath, req.URL.RawQuery, headers, signedHeaders, payloadHash}, "\n")
+
+	scope := now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{now.Format("20060102"), c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.accessKey, scope, signedHeaders, signature))
+}
+
+// s3List lists the keys directly under loc.Key with ListObjectsV2, a page
+// at a time.
+func (s *Store) s3List(ctx context.Context, loc Location) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {loc.Key}, "delimiter": {"/"}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.s3Do(ctx, "GET", Location{Scheme: "s3", Bucket: loc.Bucket}, q, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse(resp, loc.String()); err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %v", loc, err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// canonicalQueryString sorts and encodes query parameters as SigV4 wants
+// them, which is also how they are sent.
+func canonicalQueryString(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and /
+// too when encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,25 @@
+// Package proc runs the external tools (ffmpeg, ffprobe, yt-dlp) so that
+// cancelling stops them and everything they started.
+package proc
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// waitDelay is how long a cancelled command gets to exit after being asked
+// to before it is killed.
+const waitDelay = 5 * time.Second
+
+// Command is exec.CommandContext, except that the command runs in its own
+// process group and cancelling ctx asks the whole group to terminate (yt-dlp,
+// for one, runs ffmpeg itself), killing it if it hasn't exited after a few
+// seconds.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setGroup(cmd)
+	cmd.Cancel = func() error { return terminate(cmd) }
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
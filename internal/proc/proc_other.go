@@ -0,0 +1,13 @@
+//go:build !unix
+
+package proc
+
+import "os/exec"
+
+func setGroup(cmd *exec.Cmd) {}
+
+// terminate kills the command; without process groups its children are
+// left to exit on their own.
+func terminate(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,126 @@
+// Package protowire encodes and decodes the Protocol Buffers wire format,
+// enough for the gRPC service's few hand-written messages.
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Type is a field's wire type.
+type Type int
+
+const (
+	VarintType  Type = 0
+	Fixed64Type Type = 1
+	BytesType   Type = 2
+	Fixed32Type Type = 5
+)
+
+var errTruncated = errors.New("protowire: truncated message")
+
+// AppendTag appends a field's key.
+func AppendTag(b []byte, num int, t Type) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(t))
+}
+
+// AppendString appends a string field, leaving out the empty string as
+// proto3 does for defaults.
+func AppendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = AppendTag(b, num, BytesType)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// AppendMessage appends an embedded message, or a bytes field, even when
+// empty, so a oneof or optional message is still present.
+func AppendMessage(b []byte, num int, msg []byte) []byte {
+	b = AppendTag(b, num, BytesType)
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// AppendInt appends an integer field, leaving out zero.
+func AppendInt(b []byte, num int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, VarintType)
+	return binary.AppendUvarint(b, uint64(v))
+}
+
+// AppendBool appends a bool field, leaving out false.
+func AppendBool(b []byte, num int, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = AppendTag(b, num, VarintType)
+	return append(b, 1)
+}
+
+// AppendDouble appends a double field, leaving out zero.
+func AppendDouble(b []byte, num int, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, Fixed64Type)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+// Field is one field of a decoded message. Varint holds varint and fixed
+// values, Bytes length-delimited ones.
+type Field struct {
+	Num    int
+	Type   Type
+	Varint uint64
+	Bytes  []byte
+}
+
+// Double returns a fixed64 field as a double.
+func (f Field) Double() float64 {
+	return math.Float64frombits(f.Varint)
+}
+
+// Parse splits a message into its fields, in order. Bytes alias b.
+func Parse(b []byte) ([]Field, error) {
+	var fields []Field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		b = b[n:]
+		f := Field{Num: int(key >> 3), Type: Type(key & 7)}
+		switch f.Type {
+		case VarintType:
+			if f.Varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, errTruncated
+			}
+			b = b[n:]
+		case Fixed64Type:
+			if len(b) < 8 {
+				return nil, errTruncated
+			}
+			f.Varint, b = binary.LittleEndian.Uint64(b), b[8:]
+		case Fixed32Type:
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+			f.Varint, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case BytesType:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return nil, errTruncated
+			}
+			f.Bytes, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			return nil, errors.New("protowire: unsupported wire type")
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
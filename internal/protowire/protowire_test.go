@@ -0,0 +1,89 @@
+package protowire
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"int", AppendInt(nil, 1, 150), []byte{0x08, 0x96, 0x01}},
+		{"zero int", AppendInt(nil, 1, 0), nil},
+		{"negative int", AppendInt(nil, 1, -1), []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"string", AppendString(nil, 2, "testing"), []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}},
+		{"empty string", AppendString(nil, 2, ""), nil},
+		{"empty message", AppendMessage(nil, 3, nil), []byte{0x1a, 0x00}},
+		{"bool", AppendBool(nil, 4, true), []byte{0x20, 0x01}},
+		{"false", AppendBool(nil, 4, false), nil},
+		{"double", AppendDouble(nil, 5, 1), []byte{0x29, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+		{"large field number", AppendTag(nil, 16, VarintType), []byte{0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s: % x, want % x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inner := AppendString(nil, 1, "nested")
+	var b []byte
+	b = AppendInt(b, 1, 42)
+	b = AppendString(b, 2, "hello")
+	b = AppendMessage(b, 3, inner)
+	b = AppendBool(b, 4, true)
+	b = AppendDouble(b, 5, math.Pi)
+	b = AppendTag(b, 6, Fixed32Type)
+	b = append(b, 1, 0, 0, 0)
+
+	fields, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Field{
+		{Num: 1, Type: VarintType, Varint: 42},
+		{Num: 2, Type: BytesType, Bytes: []byte("hello")},
+		{Num: 3, Type: BytesType, Bytes: inner},
+		{Num: 4, Type: VarintType, Varint: 1},
+		{Num: 5, Type: Fixed64Type, Varint: math.Float64bits(math.Pi)},
+		{Num: 6, Type: Fixed32Type, Varint: 1},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("Parse() = %+v, want %+v", fields, want)
+	}
+	if d := fields[4].Double(); d != math.Pi {
+		t.Errorf("Double() = %v, want %v", d, math.Pi)
+	}
+	if f, err := Parse(fields[2].Bytes); err != nil || len(f) != 1 || string(f[0].Bytes) != "nested" {
+		t.Errorf("nested message = %+v, %v", f, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"truncated key", []byte{0x80}},
+		{"truncated varint", []byte{0x08, 0x96}},
+		{"truncated fixed64", []byte{0x29, 0, 0, 0}},
+		{"truncated fixed32", []byte{0x35, 0, 0}},
+		{"truncated bytes", []byte{0x12, 0x07, 't', 'e'}},
+		{"huge length", []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"group", []byte{0x0b}},
+	}
+	for _, tt := range tests {
+		if fields, err := Parse(tt.b); err == nil {
+			t.Errorf("%s: Parse(% x) = %+v, want an error", tt.name, tt.b, fields)
+		}
+	}
+	if fields, err := Parse(nil); err != nil || fields != nil {
+		t.Errorf("Parse(nil) = %+v, %v", fields, err)
+	}
+}
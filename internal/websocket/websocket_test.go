@@ -0,0 +1,239 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// frame is a frame as the client sends it or the server sent it.
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+	// raw, when set, is sent in place of the encoded frame
+	raw []byte
+}
+
+// encode returns f as a client frame, masked as clients must.
+func (f frame) encode() []byte {
+	if f.raw != nil {
+		return f.raw
+	}
+	b := []byte{byte(f.opcode)}
+	if f.fin {
+		b[0] |= 0x80
+	}
+	switch n := len(f.payload); {
+	case n <= 125:
+		b = append(b, 0x80|byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0x80|126), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint64(append(b, 0x80|127), uint64(n))
+	}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	b = append(b, mask...)
+	for i, c := range f.payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+// readFrames decodes the unmasked frames the server wrote.
+func readFrames(t *testing.T, data []byte) []frame {
+	t.Helper()
+	var frames []frame
+	for len(data) > 0 {
+		if len(data) < 2 || data[1]&0x80 != 0 {
+			t.Fatalf("bad server frame % x", data)
+		}
+		f := frame{fin: data[0]&0x80 != 0, opcode: int(data[0] & 0x0f)}
+		size := uint64(data[1])
+		data = data[2:]
+		switch size {
+		case 126:
+			size, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+		case 127:
+			size, data = binary.BigEndian.Uint64(data), data[8:]
+		}
+		f.payload = data[:size]
+		frames = append(frames, f)
+		data = data[size:]
+	}
+	return frames
+}
+
+// pipe returns a Conn with frames queued for it to read, and a function
+// returning the frames it wrote once it's closed.
+func pipe(t *testing.T, frames []frame) (*Conn, func() []frame) {
+	server, client := net.Pipe()
+	go func() {
+		for _, f := range frames {
+			if _, err := client.Write(f.encode()); err != nil {
+				return
+			}
+		}
+	}()
+	written := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(client)
+		written <- data
+	}()
+	c := &Conn{conn: server, br: bufio.NewReader(server)}
+	return c, func() []frame {
+		c.Close(CloseNormal, "")
+		return readFrames(t, <-written)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 70000)
+	tests := []struct {
+		name     string
+		frames   []frame
+		limit    int64
+		wantType int
+		want     []byte
+		wantErr  string
+	}{
+		{"text", []frame{{fin: true, opcode: TextMessage, payload: []byte("hello")}}, 0, TextMessage, []byte("hello"), ""},
+		{"16-bit length", []frame{{fin: true, opcode: BinaryMessage, payload: big[:300]}}, 0, BinaryMessage, big[:300], ""},
+		{"64-bit length", []frame{{fin: true, opcode: BinaryMessage, payload: big}}, 0, BinaryMessage, big, ""},
+		{
+			"fragmented with a ping between",
+			[]frame{
+				{opcode: BinaryMessage, payload: []byte("ab")},
+				{fin: true, opcode: pingMessage, payload: []byte("p")},
+				{fin: true, opcode: 0, payload: []byte("cd")},
+			},
+			0, BinaryMessage, []byte("abcd"), "",
+		},
+		{"unmasked", []frame{{raw: []byte{0x81, 0x01, 'x'}}}, 0, 0, nil, "unmasked client frame"},
+		{"reserved bits", []frame{{raw: []byte{0xc1, 0x80, 0, 0, 0, 0}}}, 0, 0, nil, "reserved bits set"},
+		{"continuation first", []frame{{fin: true, opcode: 0, payload: []byte("x")}}, 0, 0, nil, "unexpected continuation frame"},
+		{
+			"message inside a message",
+			[]frame{{opcode: TextMessage, payload: []byte("a")}, {fin: true, opcode: TextMessage, payload: []byte("b")}},
+			0, 0, nil, "new message inside a fragmented one",
+		},
+		{"fragmented ping", []frame{{opcode: pingMessage}}, 0, 0, nil, "invalid control frame"},
+		{"long ping", []frame{{fin: true, opcode: pingMessage, payload: big[:126]}}, 0, 0, nil, "invalid control frame"},
+		{"unknown opcode", []frame{{fin: true, opcode: 3}}, 0, 0, nil, "unknown opcode 3"},
+		{"frame over the limit", []frame{{fin: true, opcode: TextMessage, payload: big[:11]}}, 10, 0, nil, "too big"},
+		{
+			"fragments over the limit",
+			[]frame{{opcode: TextMessage, payload: big[:6]}, {fin: true, opcode: 0, payload: big[:6]}},
+			10, 0, nil, "too big",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, written := pipe(t, tt.frames)
+			c.ReadLimit = tt.limit
+			msgType, msg, err := c.ReadMessage()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ReadMessage() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || msgType != tt.wantType || !bytes.Equal(msg, tt.want) {
+				t.Errorf("ReadMessage() = %d, %d bytes, %v; want %d, %d bytes", msgType, len(msg), err, tt.wantType, len(tt.want))
+			}
+			frames := written()
+			for _, f := range tt.frames {
+				if f.opcode == pingMessage && tt.wantErr == "" && (len(frames) == 0 || frames[0].opcode != pongMessage || !bytes.Equal(frames[0].payload, f.payload)) {
+					t.Errorf("ping answered with %+v, want a pong", frames)
+				}
+			}
+			if last := frames[len(frames)-1]; last.opcode != closeMessage {
+				t.Errorf("last frame written is %+v, want a close", last)
+			}
+		})
+	}
+}
+
+func TestReadClose(t *testing.T) {
+	payload := append(binary.BigEndian.AppendUint16(nil, 4000), "bye"...)
+	c, written := pipe(t, []frame{{fin: true, opcode: closeMessage, payload: payload}})
+	_, _, err := c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != 4000 || ce.Reason != "bye" {
+		t.Errorf("ReadMessage() error = %v, want close 4000 bye", err)
+	}
+	frames := written()
+	if len(frames) != 1 || frames[0].opcode != closeMessage || binary.BigEndian.Uint16(frames[0].payload) != CloseNormal {
+		t.Errorf("wrote %+v, want one normal close", frames)
+	}
+	if err := c.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("WriteMessage after close = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000} {
+		c, written := pipe(t, nil)
+		msg := bytes.Repeat([]byte("y"), n)
+		if err := c.WriteMessage(BinaryMessage, msg); err != nil {
+			t.Fatal(err)
+		}
+		frames := written()
+		if len(frames) != 2 || !frames[0].fin || frames[0].opcode != BinaryMessage || !bytes.Equal(frames[0].payload, msg) {
+			t.Errorf("%d bytes: wrote %d frames, first %v %d", n, len(frames), frames[0].fin, frames[0].opcode)
+		}
+	}
+}
+
+func TestAccept(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Accept(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		msgType, msg, err := c.ReadMessage()
+		if err == nil {
+			c.WriteMessage(msgType, bytes.ToUpper(msg))
+		}
+		c.Close(CloseNormal, "")
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET answered %s, want 400", resp.Status)
+	}
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The example handshake of RFC 6455, section 1.3
+	io.WriteString(conn, "GET /stream HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake answered %s %v", resp.Status, resp.Header)
+	}
+	conn.Write(frame{fin: true, opcode: TextMessage, payload: []byte("hi")}.encode())
+	data, _ := io.ReadAll(br)
+	frames := readFrames(t, data)
+	if len(frames) == 0 || frames[0].opcode != TextMessage || string(frames[0].payload) != "HI" {
+		t.Errorf("echo = %+v, want HI", frames)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// jobRetention is how long finished jobs are kept; older ones are removed
+// when the server starts.
+const jobRetention = 7 * 24 * time.Hour
+
+// jobSuffix ends a job file's name. Uploads keep their own extension, so
+// one that is itself JSON isn't read back as a job.
+const jobSuffix = ".job.json"
+
+// job is one upload queued with POST /jobs. It's saved as <id>.job.json in
+// the jobs directory, with the audio next to it until it has been
+// transcribed, so jobs survive a restart. Status is "queued", "running", "done" or
+// "failed".
+type job struct {
+	ID       string            `json:"id"`
+	Status   string            `json:"status"`
+	File     string            `json:"file"`
+	Options  map[string]string `json:"options,omitempty"`
+	Created  time.Time         `json:"created_at"`
+	Started  *time.Time        `json:"started_at,omitempty"`
+	Finished *time.Time        `json:"finished_at,omitempty"`
+	Result   *jsonResult       `json:"result,omitempty"`
+	Error    string            `json:"error,omitempty"`
+
+	// Client is who submitted the job, with --tokens; only they can see
+	// it
+	Client string `json:"client,omitempty"`
+
+	// Audio is the saved upload's file name in the jobs directory
+	Audio string `json:"audio,omitempty"`
+
+	// OwnKey marks a job paid for with the caller's Gemini key, which is
+	// only kept in memory
+	OwnKey bool `json:"own_key,omitempty"`
+	apiKey string
+}
+
+// jobQueue holds the jobs in a directory and hands queued ones to the
+// workers in the order they were submitted.
+type jobQueue struct {
+	dir string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    map[string]*job
+	pending []string
+}
+
+// openJobQueue loads the jobs saved in dir. Jobs a restart interrupted
+// are queued again, and finished ones past jobRetention removed.
+func openJobQueue(dir string) (*jobQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &jobQueue{dir: dir, jobs: map[string]*job{}}
+	q.cond = sync.NewCond(&q.mu)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+jobSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var queued []*job
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		j := &job{}
+		if err := json.Unmarshal(data, j); err != nil || j.ID == "" {
+			warnf("Warning: skipping unreadable job %s", path)
+			continue
+		}
+		switch j.Status {
+		case "done", "failed":
+			if j.Finished != nil && time.Since(*j.Finished) > jobRetention {
+				q.remove(j)
+				continue
+			}
+		default:
+			j.Status, j.Started = "queued", nil
+			queued = append(queued, j)
+		}
+		q.jobs[j.ID] = j
+	}
+	slices.SortFunc(queued, func(a, b *job) int { return a.Created.Compare(b.Created) })
+	for _, j := range queued {
+		q.pending = append(q.pending, j.ID)
+	}
+	if len(queued) > 0 {
+		infof("Resuming %d queued job(s) from %s", len(queued), dir)
+	}
+	return q, nil
+}
+
+// add saves a new job and queues it.
+func (q *jobQueue) add(j *job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.save(j); err != nil {
+		return err
+	}
+	q.jobs[j.ID] = j
+	q.pending = append(q.pending, j.ID)
+	q.cond.Signal()
+	return nil
+}
+
+// get returns a copy of a job.
+func (q *jobQueue) get(id string) (job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// depth is the number of jobs waiting for a worker.
+func (q *jobQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// next waits for a queued job, marks it running and returns a copy.
+func (q *jobQueue) next() job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) == 0 {
+		q.cond.Wait()
+	}
+	j := q.jobs[q.pending[0]]
+	q.pending = q.pending[1:]
+	now := time.Now()
+	j.Status, j.Started = "running", &now
+	if err := q.save(j); err != nil {
+		warnf("Warning: saving job %s: %v", j.ID, err)
+	}
+	return *j
+}
+
+// finish records a job's result, or its error, and deletes its audio.
+func (q *jobQueue) finish(id string, result jsonResult, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j := q.jobs[id]
+	now := time.Now()
+	j.Finished = &now
+	if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+		j.Status, j.Error = "failed", err.Error()
+	} else {
+		j.Status, j.Result = "done", &result
+	}
+	if j.Audio != "" {
+		os.Remove(filepath.Join(q.dir, j.Audio))
+		j.Audio = ""
+	}
+	if err := q.save(j); err != nil {
+		warnf("Warning: saving job %s: %v", j.ID, err)
+	}
+}
+
+// save writes a job's file, through a temp file so a crash can't leave
+// it half written. The caller holds q.mu.
+func (q *jobQueue) save(j *job) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.dir, j.ID+jobSuffix)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// remove deletes a job's files.
+func (q *jobQueue) remove(j *job) {
+	if j.Audio != "" {
+		os.Remove(filepath.Join(q.dir, j.Audio))
+	}
+	os.Remove(filepath.Join(q.dir, j.ID+jobSuffix))
+}
+
+// newJobID returns a random ID for a job.
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// runJobWorkers starts n workers that transcribe queued jobs one at a
+// time each.
+func (s *server) runJobWorkers(n int) {
+	for range n {
+		go func() {
+			for {
+				j := s.jobs.next()
+				s.runJob(j)
+			}
+		}()
+	}
+}
+
+func (s *server) runJob(j job) {
+	start := time.Now()
+	if j.OwnKey && j.apiKey == "" {
+		s.jobs.finish(j.ID, jsonResult{}, errors.New("the server restarted and the caller's Gemini key isn't kept; submit the job again"))
+		return
+	}
+	opts, err := s.uploadOptions(func(k string) string { return j.Options[k] })
+	if err != nil {
+		s.jobs.finish(j.ID, jsonResult{}, err)
+		return
+	}
+	ctx := withCallerKey(context.Background(), j.apiKey)
+	result, snap, err := s.transcribeUpload(ctx, "job", filepath.Join(s.jobs.dir, j.Audio), j.File, opts)
+	result.Usage = usageFor(result.Model, snap, true)
+	s.jobs.finish(j.ID, result, err)
+	if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+		errorf("Job %s (%s): %v", j.ID, j.File, err)
+		return
+	}
+	infof("Job %s (%s) done in %s", j.ID, j.File, time.Since(start).Round(time.Millisecond))
+}
+
+// handleSubmitJob queues a multipart upload with the same fields as
+// /transcribe and answers 202 Accepted with the job, whose status and
+// result are at GET /jobs/{id}.
+func (s *server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("missing audio in multipart field \"file\": %v", err))
+		return
+	}
+	defer file.Close()
+	if _, err := s.uploadOptions(r.FormValue); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	j := &job{ID: newJobID(), Status: "queued", File: header.Filename, Created: time.Now(), Client: principal(r.Context())}
+	if j.apiKey = callerKey(r.Context()); j.apiKey != "" {
+		j.OwnKey = true
+	}
+	for _, k := range []string{"model", "prompt", "format", "translate", "language"} {
+		if v := r.FormValue(k); v != "" {
+			if j.Options == nil {
+				j.Options = map[string]string{}
+			}
+			j.Options[k] = v
+		}
+	}
+	audio, err := saveUpload(s.jobs.dir, file, header.Filename)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	j.Audio = filepath.Base(audio)
+	queued := *j
+	if err := s.jobs.add(j); err != nil {
+		os.Remove(audio)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	infof("POST /jobs %s (%d bytes) queued as %s", header.Filename, header.Size, j.ID)
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJob(w, http.StatusAccepted, queued)
+}
+
+// handleGetJob answers with a job's status, and its result once done.
+func (s *server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok || j.Client != principal(r.Context()) {
+		writeJSONError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJob(w, http.StatusOK, j)
+}
+
+// handleGetJobResult answers with a finished job's transcript in the
+// format it was submitted with, as /transcribe would have, or in the
+// ?format= asked for. Jobs submitted as text have no timestamps for srt
+// or vtt.
+func (s *server) handleGetJobResult(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	switch {
+	case !ok || j.Client != principal(r.Context()):
+		writeJSONError(w, http.StatusNotFound, "no such job")
+		return
+	case j.Status == "failed":
+		writeJSONError(w, http.StatusBadGateway, j.Error)
+		return
+	case j.Status != "done":
+		writeJSONError(w, http.StatusConflict, "job is "+j.Status)
+		return
+	}
+	opts, err := s.uploadOptions(func(k string) string { return j.Options[k] })
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch {
+		case format != "json" && format != "text" && format != "srt" && format != "vtt":
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want json, text, srt or vtt)", format))
+			return
+		case (format == "srt" || format == "vtt") && !opts.Timestamps:
+			writeJSONError(w, http.StatusBadRequest, "job was submitted as text, without timestamps")
+			return
+		}
+		opts.format = format
+	}
+	writeRendered(w, *j.Result, opts)
+}
+
+func writeJob(w http.ResponseWriter, status int, j job) {
+	j.Audio = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(j)
+}
+
+// defaultJobsDir is where jobs are kept without --jobs-dir:
+// ~/.cache/gemini-transcribe/jobs on Linux.
+func defaultJobsDir() string {
+	dir, err := transcribe.DefaultCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "gemini-transcribe-jobs")
+	}
+	return filepath.Join(dir, "jobs")
+}
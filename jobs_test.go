@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobQueueRestart(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openJobQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An upload that is itself a job's JSON stays an upload
+	upload, err := saveUpload(dir, strings.NewReader(`{"id": "fake", "status": "queued"}`), "foo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &job{ID: "first", Status: "queued", File: "foo.json", Audio: filepath.Base(upload), Created: time.Now().Add(-time.Minute)}
+	second := &job{ID: "second", Status: "queued", File: "b.mp3", Created: time.Now()}
+	for _, j := range []*job{second, first} {
+		if err := q.add(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "first"+jobSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("job file mode = %v, want 0600", mode)
+	}
+	if j := q.next(); j.ID != "second" || j.Status != "running" {
+		t.Errorf("next() = %s %s, want second running", j.ID, j.Status)
+	}
+
+	// An old finished job is removed on restart
+	old := &job{ID: "old", Status: "failed", Created: time.Now().Add(-30 * 24 * time.Hour)}
+	if err := q.add(old); err != nil {
+		t.Fatal(err)
+	}
+	q.finish("old", jsonResult{}, errors.New("boom"))
+	q.mu.Lock()
+	finished := time.Now().Add(-jobRetention - time.Hour)
+	q.jobs["old"].Finished = &finished
+	q.save(q.jobs["old"])
+	q.mu.Unlock()
+
+	q, err = openJobQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.get("fake"); ok {
+		t.Error("an uploaded JSON file was read as a job")
+	}
+	if _, ok := q.get("old"); ok {
+		t.Error("expired job kept")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old"+jobSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expired job's file left behind: %v", err)
+	}
+	// Queued and interrupted jobs come back in the order they were submitted
+	if q.depth() != 2 {
+		t.Fatalf("depth() = %d, want 2", q.depth())
+	}
+	for _, want := range []string{"first", "second"} {
+		if j := q.next(); j.ID != want {
+			t.Errorf("next() = %s, want %s", j.ID, want)
+		}
+	}
+
+	q.finish("first", jsonResult{Transcription: "hi"}, nil)
+	if j, _ := q.get("first"); j.Status != "done" || j.Result.Transcription != "hi" || j.Audio != "" {
+		t.Errorf("finished job = %+v", j)
+	}
+	if _, err := os.Stat(upload); !os.IsNotExist(err) {
+		t.Errorf("finished job's audio left behind: %v", err)
+	}
+}
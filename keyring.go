@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// The API key stored by config set-key lives in the OS keychain under this
+// service and account: the macOS Keychain, the Windows Credential Manager
+// or the Secret Service (GNOME Keyring, KWallet) elsewhere.
+const (
+	keyringService = "gemini-transcribe"
+	keyringAccount = "api_key"
+)
+
+var (
+	errKeyringNotFound    = errors.New("no API key in the keyring")
+	errKeyringUnsupported = errors.New("no OS keyring available on this system")
+)
+
+// keyringAPIKey returns the key stored by config set-key, or "" when there
+// is none or no keyring to ask.
+func keyringAPIKey() string {
+	key, err := keyringGet()
+	if err != nil {
+		if !errors.Is(err, errKeyringNotFound) && !errors.Is(err, errKeyringUnsupported) {
+			debugf("Reading the keyring: %v", err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// validKeyringKey reports whether key can be stored: one line with no
+// quotes, which every Gemini key (or comma-separated list of keys) is.
+func validKeyringKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "\"'\\\r\n")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// On macOS the key is a generic password in the login Keychain, managed
+// with security(1).
+
+func keyringGet() (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+		// errSecItemNotFound
+		return "", errKeyringNotFound
+	} else if errors.Is(err, exec.ErrNotFound) {
+		return "", errKeyringUnsupported
+	} else if err != nil {
+		return "", fmt.Errorf("security: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keyringSet(key string) error {
+	// The key goes in on stdin (security -i) rather than as an argument,
+	// where other users could read it from the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -l \"Gemini API key\" -w \"%s\"\n", keyringService, keyringAccount, key))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// security -i carries on after a failed command, so errors only show
+	// on stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("security: %v", err)
+	} else if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("security: %s", msg)
+	}
+	return nil
+}
+
+func keyringDelete() error {
+	err := exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", keyringAccount).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+		return errKeyringNotFound
+	} else if err != nil {
+		return fmt.Errorf("security: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !darwin && !windows && !linux && !freebsd && !openbsd && !netbsd
+
+package main
+
+func keyringGet() (string, error) { return "", errKeyringUnsupported }
+func keyringSet(string) error     { return errKeyringUnsupported }
+func keyringDelete() error        { return errKeyringUnsupported }
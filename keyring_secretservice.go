@@ -0,0 +1,60 @@
+//go:build linux || freebsd || openbsd || netbsd
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Elsewhere the key goes to the Secret Service (GNOME Keyring, KWallet,
+// KeePassXC) through secret-tool(1), from libsecret.
+
+func keyringGet() (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", errKeyringUnsupported
+	} else if err != nil {
+		if stderr.Len() == 0 {
+			// A lookup that finds nothing fails silently
+			return "", errKeyringNotFound
+		}
+		return "", fmt.Errorf("secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(out) == 0 {
+		return "", errKeyringNotFound
+	}
+	return string(out), nil
+}
+
+func keyringSet(key string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store", "--label=Gemini API key", "service", keyringService, "account", keyringAccount)
+	cmd.Stdin = strings.NewReader(key)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%w (install secret-tool, from libsecret)", errKeyringUnsupported)
+	} else if err != nil {
+		return fmt.Errorf("secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func keyringDelete() error {
+	if _, err := keyringGet(); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "clear", "service", keyringService, "account", keyringAccount)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// On Windows the key is a generic credential in the Credential Manager.
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential is the Win32 CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keyringTarget is the credential's name, as shown in Credential Manager.
+func keyringTarget() *uint16 {
+	target, _ := syscall.UTF16PtrFromString(keyringService + ":" + keyringAccount)
+	return target
+}
+
+func keyringGet() (string, error) {
+	if err := procCredReadW.Find(); err != nil {
+		return "", errKeyringUnsupported
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(keyringTarget())), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", errKeyringNotFound
+		}
+		return "", fmt.Errorf("reading the credential: %v", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func keyringSet(key string) error {
+	if err := procCredWriteW.Find(); err != nil {
+		return errKeyringUnsupported
+	}
+	blob := []byte(key)
+	user, _ := syscall.UTF16PtrFromString(keyringAccount)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         keyringTarget(),
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return fmt.Errorf("writing the credential: %v", err)
+	}
+	return nil
+}
+
+func keyringDelete() error {
+	if err := procCredDelete.Find(); err != nil {
+		return errKeyringUnsupported
+	}
+	if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(keyringTarget())), credTypeGeneric, 0); r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return errKeyringNotFound
+		}
+		return fmt.Errorf("deleting the credential: %v", err)
+	}
+	return nil
+}
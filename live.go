@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/websocket"
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// defaultLiveSegment is how much audio /stream transcribes at a time
+// unless the client asks for another length.
+const defaultLiveSegment = 10 * time.Second
+
+// liveMessage is a JSON message /stream sends: a transcript "segment"
+// (with the Segment's fields), an "error" for a piece of audio that
+// couldn't be transcribed, or "done" once all the audio has been.
+type liveMessage struct {
+	Type string `json:"type"`
+	*transcribe.Segment
+	Error string `json:"error,omitempty"`
+}
+
+// handleLiveStream serves GET /stream, a WebSocket for live captioning.
+// The client sends the audio as binary messages, in any container ffmpeg
+// can read from a pipe (a MediaRecorder's WebM/Opus, Ogg, MP3, WAV), and
+// the text message "end" when it's finished. The audio is cut into
+// rolling segments, as with --mic, and each one's transcript is sent as
+// soon as it's ready, with times from the start of the stream. Query
+// parameters model, prompt, language, translate and segment (seconds)
+// override the defaults.
+func (s *server) handleLiveStream(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsUpgrade(r) {
+		writeJSONError(w, http.StatusUpgradeRequired, "/stream is a WebSocket endpoint")
+		return
+	}
+	opts := s.defaults
+	q := r.URL.Query()
+	if v := q.Get("model"); v != "" {
+		opts.Model = v
+	}
+	if v := q.Get("prompt"); v != "" {
+		opts.Prompt = v
+	}
+	if v := q.Get("language"); v != "" {
+		opts.Language = v
+	}
+	if v := q.Get("translate"); v != "" {
+		opts.TranslateTo = v
+	}
+	opts.Timestamps = true
+	opts.DetectLanguage = false
+	segment := defaultLiveSegment
+	if v := q.Get("segment"); v != "" {
+		secs, err := strconv.ParseFloat(v, 64)
+		if err != nil || secs < 2 || secs > 300 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("segment must be between 2 and 300 seconds, got %q", v))
+			return
+		}
+		segment = time.Duration(secs * float64(time.Second))
+	}
+	if _, err := exec.LookPath(ffmpegBinary(s.client)); err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "/stream requires ffmpeg")
+		return
+	}
+	dir, err := os.MkdirTemp("", "gemini-transcribe-live-*")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	conn, err := websocket.Accept(w, r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+	conn.ReadLimit = s.maxUpload
+	start := time.Now()
+	send := func(m liveMessage) {
+		data, _ := json.Marshal(m)
+		conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	// The connection outlives the request's context once taken over;
+	// cancelling stops ffmpeg and any transcription in flight
+	ctx, cancel := context.WithCancel(withCallerKey(context.Background(), callerKey(r.Context())))
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ffmpegBinary(s.client),
+		"-hide_banner", "-loglevel", "error", "-i", "pipe:0",
+		"-ac", "1", "-ar", "16000", "-c:a", "libmp3lame", "-b:a", "64k",
+		"-f", "segment", "-segment_time", fmt.Sprintf("%.3f", segment.Seconds()),
+		"-reset_timestamps", "1",
+		filepath.Join(dir, "seg%05d.mp3"))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		send(liveMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		send(liveMessage{Type: "error", Error: fmt.Sprintf("starting ffmpeg: %v", err)})
+		return
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	// Feed ffmpeg until "end". A client that closes or drops the
+	// connection first doesn't want the rest transcribed.
+	var received atomic.Int64
+	go func() {
+		defer stdin.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				cancel()
+				return
+			}
+			if msgType == websocket.TextMessage {
+				if strings.TrimSpace(string(msg)) == "end" {
+					return
+				}
+				continue
+			}
+			if received.Add(int64(len(msg))) > s.maxUpload {
+				send(liveMessage{Type: "error", Error: fmt.Sprintf("stream is larger than %d MB", s.maxUpload>>20)})
+				cancel()
+				return
+			}
+			if _, err := stdin.Write(msg); err != nil {
+				// ffmpeg has exited; followSegments reports why
+				return
+			}
+		}
+	}()
+
+	sent := 0
+	err = followSegments(dir, done, func(n int, path string) {
+		if ctx.Err() != nil {
+			return
+		}
+		offset := float64(n) * segment.Seconds()
+		t0 := time.Now()
+		client := s.clientFor(ctx)
+		res, err := client.TranscribeFile(ctx, path, opts.Options)
+		snap := client.Stats.Snapshot()
+		s.client.Stats.Merge(snap)
+		s.metrics.observe("stream", opts.Model, res.Duration, snap, time.Since(t0), err)
+		if errors.Is(err, transcribe.ErrNoSpeech) {
+			return
+		} else if err != nil {
+			if ctx.Err() == nil {
+				send(liveMessage{Type: "error", Error: fmt.Sprintf("at %s: %v", transcribe.FormatTimecode(offset, "."), err)})
+			}
+			return
+		}
+		segs := res.Segments
+		if len(segs) == 0 && strings.TrimSpace(res.Text) != "" {
+			segs = []transcribe.Segment{{End: min(res.Duration, segment.Seconds()), Text: strings.TrimSpace(res.Text)}}
+		}
+		for _, seg := range segs {
+			seg.Start += offset
+			seg.End += offset
+			for i, w := range seg.Words {
+				seg.Words[i] = transcribe.Word{Word: w.Word, Start: w.Start + offset, End: w.End + offset}
+			}
+			send(liveMessage{Type: "segment", Segment: &seg})
+			sent++
+		}
+	})
+	if ctx.Err() != nil {
+		infof("WS /stream closed by the client after %s", time.Since(start).Round(time.Millisecond))
+		return
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		errorf("WS /stream: ffmpeg: %s", msg)
+		send(liveMessage{Type: "error", Error: "ffmpeg: " + msg})
+		conn.Close(websocket.CloseInternalError, "ffmpeg failed")
+		return
+	}
+	send(liveMessage{Type: "done"})
+	infof("WS /stream %d bytes, %d segments in %s", received.Load(), sent, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Diagnostics (progress, warnings and errors) go to stderr through
+// log/slog, so --log-level, --quiet and --log-json apply to all of them.
+// stdout only ever carries the transcript or a command's output.
+
+// logJSON is set when diagnostics are written as JSON lines, which rules
+// out progress bars.
+var logJSON bool
+
+// consoleHandler writes just the message, the way the tool always printed
+// to stderr, optionally after a timestamp. Attributes are only for the
+// JSON output.
+type consoleHandler struct {
+	mu         *sync.Mutex
+	w          io.Writer
+	level      slog.Level
+	timestamps bool
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	line := r.Message + "\n"
+	if h.timestamps {
+		line = r.Time.Format("2006/01/02 15:04:05 ") + line
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *consoleHandler) WithGroup(string) slog.Handler      { return h }
+
+// setupLogging installs the default logger writing to stderr at level, as
+// JSON lines or as plain messages, with timestamps for long-running
+// commands such as serve and watch.
+func setupLogging(level slog.Level, asJSON, timestamps bool) {
+	logJSON = asJSON
+	var h slog.Handler = &consoleHandler{mu: new(sync.Mutex), w: os.Stderr, level: level, timestamps: timestamps}
+	if asJSON {
+		h = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.MessageKey {
+					a.Value = slog.StringValue(strings.TrimSpace(a.Value.String()))
+				}
+				return a
+			},
+		})
+	}
+	slog.SetDefault(slog.New(h))
+}
+
+// parseLogLevel parses a --log-level value.
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// logEnabled reports whether messages at level are shown.
+func logEnabled(level slog.Level) bool {
+	return slog.Default().Enabled(context.Background(), level)
+}
+
+// logf logs a printf-style message at level. A trailing newline, left over
+// from the messages' fmt.Fprintf days, is dropped.
+func logf(level slog.Level, format string, args ...any) {
+	if !logEnabled(level) {
+		return
+	}
+	slog.Log(context.Background(), level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+func debugf(format string, args ...any) { logf(slog.LevelDebug, format, args...) }
+func infof(format string, args ...any)  { logf(slog.LevelInfo, format, args...) }
+func warnf(format string, args ...any)  { logf(slog.LevelWarn, format, args...) }
+func errorf(format string, args ...any) { logf(slog.LevelError, format, args...) }
+
+// showProgress reports whether progress bars can be drawn on stderr.
+func showProgress() bool {
+	return !logJSON && logEnabled(slog.LevelInfo) && isTerminal(os.Stderr)
+}
@@ -1,314 +1,1474 @@
 package main
 
 import (
-	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
 )
 
 const (
-	defaultModel   = "gemini-2.5-flash"
-	defaultBaseURL = "https://generativelanguage.googleapis.com"
-	apiURLTemplate = "%s/v1beta/models/%s:generateContent?key=%s"
+	defaultModel   = transcribe.DefaultModel
+	defaultBaseURL = transcribe.DefaultBaseURL
+	defaultPrompt  = transcribe.DefaultPrompt
 )
 
-type GeminiRequest struct {
-	Contents []Content `json:"contents"`
+type jsonResult struct {
+	File          string                    `json:"file"`
+	Model         string                    `json:"model"`
+	Version       string                    `json:"version,omitempty"`
+	Language      string                    `json:"language,omitempty"`
+	Duration      float64                   `json:"duration,omitempty"`
+	Transcription string                    `json:"transcription"`
+	TranslatedTo  string                    `json:"translated_to,omitempty"`
+	Source        string                    `json:"source_transcription,omitempty"`
+	Summary       string                    `json:"summary,omitempty"`
+	Confidence    string                    `json:"confidence,omitempty"` // "low" when the transcript looks made up
+	Warnings      []string                  `json:"warnings,omitempty"`
+	Segments      []transcribe.Segment      `json:"segments,omitempty"`
+	Usage         *usageJSON                `json:"usage,omitempty"`
+	Meta          *transcribe.StatsSnapshot `json:"meta,omitempty"`
+
+	// Title and Chapters come from the input's metadata, for Markdown
+	Title    string    `json:"-"`
+	Chapters []chapter `json:"-"`
 }
 
-type Content struct {
-	Parts []Part `json:"parts"`
+// usageJSON is the token usage reported in JSON output, with the estimated
+// cost when --show-cost is given.
+type usageJSON struct {
+	PromptTokens int      `json:"prompt_tokens"`
+	OutputTokens int      `json:"output_tokens"`
+	TotalTokens  int      `json:"total_tokens"`
+	CostUSD      *float64 `json:"estimated_cost_usd,omitempty"`
 }
 
-type Part struct {
-	Text       string    `json:"text,omitempty"`
-	InlineData *BlobData `json:"inline_data,omitempty"`
+// options holds the resolved settings applied to every input: the library's
+// per-transcription options plus what the CLI does with the result.
+type options struct {
+	transcribe.Options
+	format      string
+	formats     []string // every format written with --formats; format is the first
+	template    *template.Template
+	output      string
+	sidecar     bool
+	embed       bool
+	chapterMeta bool
+	summaryOnly bool
+	cueSettings string
+	cues        transcribe.CueOptions
+	karaoke     bool
+	verbose     bool
+	verboseJSON bool
+	showCost    bool
+	errorJSON   bool
+	stream      bool
+	ytdlp       bool
+	keepAudio   bool
+	stdinType   string
+	proxy       string
+	dryRun      *dryRunReport
+	jobs        int
+	overwrite   bool
+	manifest    *manifest // --manifest rows, for per-input prompts, languages and outputs
+	webhook     string
+	db          *transcriptDB // --db, where each transcript is also stored
+	timeout     time.Duration
 }
 
-type BlobData struct {
-	MimeType string `json:"mime_type"`
-	Data     string `json:"data"`
+func main() {
+	setupLogging(slog.LevelInfo, false, false)
+	if len(os.Args) > 1 && runCommand(os.Args[1], os.Args[2:]) {
+		return
+	}
+	// Without a command, the flags are transcribe's
+	runTranscribe("transcribe", os.Args[1:])
 }
 
-type GeminiResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
-	Error *struct {
-		Message string `json:"message"`
-		Code    int    `json:"code"`
-	} `json:"error,omitempty"`
+// runCommand runs the named subcommand with args, reporting false when
+// there is no such command.
+func runCommand(name string, args []string) bool {
+	switch name {
+	case "transcribe", "translate", "summarize":
+		runTranscribe(name, args)
+	case "search":
+		runSearch(args)
+	case "serve":
+		runServe(args)
+	case "models":
+		runModels(args)
+	case "watch":
+		runWatch(args)
+	case "minutes":
+		runMinutes(args)
+	case "align":
+		runAlign(args)
+	case "eval":
+		runEval(args)
+	case "feed":
+		runFeed(args)
+	case "init":
+		runInit(args)
+	case "config":
+		runConfig(args)
+	case "completion":
+		runCompletion(args)
+	case completeCommand:
+		runComplete(args)
+	default:
+		return false
+	}
+	return true
 }
 
-func main() {
-	var (
-		inputFile  string
-		apiKey     string
-		model      string
-		baseURL    string
-		prompt     string
-		outputJSON bool
-		verbose    bool
-	)
+// transcribeFlags are the transcribe command's flag values.
+type transcribeFlags struct {
+	inputFile   string
+	keysFile    string
+	promptFile  string
+	vocabFile   string
+	formatList  string
+	tmplFile    string
+	rulesFile   string
+	ffmpegPath  string
+	ffmpegArgs  string
+	ffmpegIn    string
+	audioTrack  int
+	presetName  string
+	safety      string
+	timeRange   string
+	fromURL     string
+	profileName string
+	vertex      bool
+	project     string
+	location    string
+	credentials string
+	outputJSON  bool
+	chaptersOut bool
+	failOnEmpty bool
+	noCache     bool
+	dryRun      bool
+	recordDir   string
+	replayDir   string
+	resume      bool
+	manifestCSV string
+	dbPath      string
+	burnIn      string
+	compare     string
+	profanity   bool
+	profanityAs string
+	clean       bool
+	verbatim    bool
+	mic         bool
+	showVersion bool
+	micDevice   string
+	micSegment  time.Duration
+	maxRetries  int
+	retryDelay  time.Duration
+	rpm         int
+	concurrent  int
+	genConfig   transcribe.GenerationConfig
+	opts        options
+	g           globalFlags
+}
+
+// flagSet returns the transcribe command's flags, bound to v.
+func (v *transcribeFlags) flagSet(command string) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.inputFile, "i", "", "Input audio/video file, URL, s3:// or gs:// object or prefix, directory or glob (required)")
+	fs.StringVar(&v.inputFile, "input", "", "Input audio/video file, URL, s3:// or gs:// object or prefix, directory or glob (required)")
+	fs.StringVar(&v.opts.stdinType, "mime-type", "", "With -i -: MIME type of the audio on stdin (e.g. audio/mpeg; sniffed when omitted)")
+	fs.StringVar(&v.fromURL, "from-url", "", "Fetch audio from a video page (YouTube, Vimeo, ...) with yt-dlp and transcribe it")
+	fs.BoolVar(&v.opts.keepAudio, "keep-audio", false, "With --from-url: keep the downloaded audio in the current directory")
+	fs.StringVar(&v.keysFile, "keys-file", "", "File of Gemini API keys, one per line, to rotate between")
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Gemini model to use, or several separated by commas to fall back on in order")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Gemini model to use, or several separated by commas to fall back on in order")
+	fs.StringVar(&v.compare, "compare", "", "Transcribe with each of these comma-separated models at once and compare the transcripts, time and cost")
+	fs.BoolVar(&v.vertex, "vertex", false, "Use Vertex AI with Google Cloud credentials instead of an API key")
+	fs.StringVar(&v.project, "project", "", "Google Cloud project for --vertex (or set GOOGLE_CLOUD_PROJECT)")
+	fs.StringVar(&v.location, "location", "", "Vertex AI region for --vertex (or set GOOGLE_CLOUD_LOCATION, default us-central1)")
+	fs.StringVar(&v.credentials, "credentials", "", "Service account JSON key for --vertex (default: Application Default Credentials)")
+	fs.StringVar(&v.opts.Prompt, "p", "", "Custom prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.opts.Prompt, "prompt", "", "Custom prompt (or set GEMINI_PROMPT)")
+	fs.BoolVar(&v.opts.PlainTimestamps, "no-schema", false, "Ask for timed output as text lines instead of schema-constrained JSON (for models or proxies without responseSchema)")
+	fs.StringVar(&v.opts.SystemInstruction, "system", "", "System instruction sent apart from the prompt, e.g. formatting rules the model must follow")
+	fs.StringVar(&v.presetName, "preset", "", "Use a prompt preset: "+strings.Join(presetNames(), ", ")+" or one from the config file")
+	fs.StringVar(&v.promptFile, "prompt-file", "", "Read the prompt from a file (or set GEMINI_PROMPT_FILE)")
+	fs.StringVar(&v.profileName, "profile", "", "Use a profile from ~/.config/gemini-transcribe/config.yaml")
+	fs.BoolVar(&v.outputJSON, "json", false, "Output as JSON (same as --format json)")
+	fs.BoolVar(&v.chaptersOut, "chapters", false, "Output a YouTube-style chapter list made from the topics (same as --format chapters)")
+	fs.BoolVar(&v.opts.chapterMeta, "write-chapters", false, "Also write the chapters into each MP4 or MKV input's chapter metadata")
+	fs.StringVar(&v.opts.format, "f", "text", "Output format: text, json, srt, vtt, md, whisper-json, chapters")
+	fs.StringVar(&v.opts.format, "format", "text", "Output format: text, json, srt, vtt, md, whisper-json, chapters")
+	fs.StringVar(&v.opts.output, "o", "", "Write output to this file or directory (or s3:// or gs:// URI) instead of stdout")
+	fs.StringVar(&v.opts.output, "output", "", "Write output to this file or directory (or s3:// or gs:// URI) instead of stdout")
+	fs.BoolVar(&v.opts.sidecar, "sidecar", false, "Write the output next to each input with the same basename, plus a plain .txt transcript")
+	fs.StringVar(&v.burnIn, "burn-in", "", "Also write a copy of the input video to this file with the subtitles drawn on (needs ffmpeg with libass)")
+	fs.BoolVar(&v.opts.embed, "embed", false, "Also write the transcript into each input's metadata: lyrics in MP3 and MP4 (with chapters), a subtitle track in MKV")
+	fs.BoolVar(&v.opts.Words, "words", false, "Request word-level timestamps (JSON segments with {word, start, end} entries)")
+	fs.StringVar(&v.opts.Language, "language", "", "Spoken language of the audio, as a hint (e.g. German, pt)")
+	fs.StringVar(&v.vocabFile, "vocab", "", "File of names and terms, one per line, to spell exactly (added to the prompt and corrected afterwards)")
+	fs.StringVar(&v.formatList, "formats", "", "Write several formats from one transcription, e.g. txt,srt,vtt,json (files named after the input)")
+	fs.StringVar(&v.tmplFile, "template", "", "Render the output with this Go text/template file instead of --format")
+	fs.StringVar(&v.rulesFile, "rules", "", "YAML file of find/replace rules (plain or regex) applied to the transcript")
+	fs.BoolVar(&v.opts.DetectLanguage, "detect-language", false, "Report the detected spoken language (always included in JSON output)")
+	fs.StringVar(&v.opts.TranslateTo, "translate", "", "Translate the transcript into this language (e.g. English, fr)")
+	fs.BoolVar(&v.clean, "clean", false, "Leave out filler words (um, uh), false starts and repeated words")
+	fs.BoolVar(&v.verbatim, "verbatim", false, "Keep every filler word, false start and repetition, and mark sounds like [laughter]")
+	fs.Func("redact", "Replace personal information with placeholders: "+strings.Join(transcribe.RedactCategories, ",")+" (any of them, comma-separated)", func(s string) error {
+		v.opts.Redact = splitList(s)
+		return transcribe.CheckRedact(v.opts.Redact)
+	})
+	fs.Var(optionalFlag{&v.profanity, &v.profanityAs}, "filter-profanity", "Mask swear words as f***; --filter-profanity=remove leaves them out")
+	fs.BoolVar(&v.opts.Refine, "refine", false, "Send the audio again with the draft transcript for the model to correct (a second request)")
+	fs.BoolVar(&v.opts.TwoPassTranslate, "two-pass", false, "With --translate: transcribe first, then translate in a second request")
+	fs.Var(optionalFlag{&v.opts.Summarize, &v.opts.SummaryStyle}, "summarize", "Add a summary; --summarize=STYLE picks "+strings.Join(transcribe.SummaryStyleNames, ", ")+" or takes an instruction")
+	fs.BoolVar(&v.opts.summaryOnly, "summary-only", false, "Output only the summary instead of the transcript (implies --summarize)")
+	fs.Float64Var(&v.opts.cues.FPS, "fps", 0, "Snap SRT/VTT cue times to the frames of this frame rate (e.g. 25, 29.97)")
+	fs.DurationVar(&v.opts.cues.MinDuration, "min-cue-duration", 0, "Merge SRT/VTT cues shorter than this with the next one, or show them longer (e.g. 1s)")
+	fs.DurationVar(&v.opts.cues.MaxDuration, "max-cue-duration", 0, "Split SRT/VTT cues longer than this between words (e.g. 7s)")
+	fs.BoolVar(&v.opts.karaoke, "word-timestamps-to-srt", false, "Highlight each word of SRT/VTT cues as it's spoken, from word-level timestamps (implies --words)")
+	fs.BoolVar(&v.opts.karaoke, "karaoke", false, "Same as --word-timestamps-to-srt")
+	fs.StringVar(&v.opts.cueSettings, "cue-settings", "", "WebVTT cue settings added to every cue (e.g. \"line:90% align:center\")")
+	fs.BoolVar(&v.opts.stream, "stream", false, "Print text output as it arrives (single file, text format)")
+	fs.BoolVar(&v.opts.verboseJSON, "verbose-json", false, "Include API call statistics as \"meta\" in JSON output")
+	fs.BoolVar(&v.noCache, "no-cache", false, "Always call the API instead of reusing a cached transcript of the same audio")
+	fs.BoolVar(&v.opts.errorJSON, "error-json", false, "Print errors to stderr as JSON objects with a class and exit code")
+	fs.BoolVar(&v.opts.showCost, "show-cost", false, "Print the estimated cost of the run and add it to JSON output")
+	fs.StringVar(&v.opts.Upload, "upload", "auto", "Send audio via the Files API: auto (over 20MB), always, never")
+	fs.Func("start", "Transcribe from this position (e.g. 1:30:00, 90m)", timeFlag(&v.opts.Start))
+	fs.Func("end", "Transcribe up to this position (e.g. 1:45:00, 105m)", timeFlag(&v.opts.End))
+	fs.StringVar(&v.timeRange, "range", "", "Transcribe a slice given as START-END (e.g. 00:10:00-00:25:00)")
+	fs.DurationVar(&v.opts.ChunkDuration, "chunk-duration", transcribe.DefaultChunkDuration, "Split recordings longer than this into chunks (0 disables, needs ffmpeg)")
+	fs.DurationVar(&v.opts.ChunkOverlap, "chunk-overlap", transcribe.DefaultChunkOverlap, "Overlap between consecutive chunks")
+	fs.BoolVar(&v.opts.Force, "force", false, "Send audio longer than Gemini's per-request limit instead of refusing it")
+	fs.IntVar(&v.audioTrack, "audio-track", -1, "Audio track to transcribe in a multi-track file, from 0 (ffmpeg's -map 0:a:N)")
+	fs.StringVar(&v.opts.Channel, "channel", "mix", "Audio channel to transcribe: left, right, mix or a channel number")
+	fs.BoolVar(&v.opts.Normalize, "normalize", false, "Normalize loudness with ffmpeg before transcribing (helps quiet recordings)")
+	fs.BoolVar(&v.opts.Denoise, "denoise", false, "Reduce background noise with ffmpeg before transcribing")
+	fs.StringVar(&v.opts.DenoiseModel, "denoise-model", "", "RNNoise model file for --denoise (uses ffmpeg's arnndn instead of afftdn)")
+	fs.StringVar(&v.opts.ConvertTo, "convert-to", "mp3", "Codec files are converted to before sending: mp3, opus (smallest), flac or wav (lossless)")
+	fs.StringVar(&v.ffmpegPath, "ffmpeg-path", "", "ffmpeg binary to use (ffprobe is looked for beside it)")
+	fs.StringVar(&v.ffmpegArgs, "ffmpeg-args", "", "Replace ffmpeg's output options for the conversion (e.g. \"-vn -af highpass=f=200 -ac 1 -c:a libmp3lame\")")
+	fs.StringVar(&v.ffmpegIn, "ffmpeg-input-args", "", "ffmpeg options placed before -i (e.g. \"-hwaccel cuda\")")
+	fs.Func("temperature", "Sampling temperature, 0-2 (default: the model's)", floatFlag(&v.genConfig.Temperature, 0, 2))
+	fs.Func("top-p", "Nucleus sampling threshold, 0-1 (default: the model's)", floatFlag(&v.genConfig.TopP, 0, 1))
+	fs.IntVar(&v.genConfig.MaxOutputTokens, "max-output-tokens", 0, "Maximum tokens in the response (0 means the model's limit)")
+	fs.StringVar(&v.safety, "safety", "default", "Safety filter level: "+strings.Join(transcribe.SafetyLevels, ", "))
+	fs.IntVar(&v.opts.MaxContinuations, "max-continuations", transcribe.DefaultMaxContinuations, "Follow-up requests for a response cut off at the token limit (0 disables)")
+	fs.IntVar(&v.opts.jobs, "j", 1, "Number of files to transcribe in parallel in batch mode")
+	fs.IntVar(&v.opts.jobs, "jobs", 1, "Number of files to transcribe in parallel in batch mode")
+	fs.BoolVar(&v.opts.overwrite, "overwrite", false, "In batch mode, transcribe inputs again even when their output file already exists")
+	fs.StringVar(&v.opts.webhook, "webhook", "", "POST a JSON notification to this URL as each file completes or fails")
+	fs.StringVar(&v.dbPath, "db", "", "Also store each transcript in this SQLite database, for the search command (needs sqlite3)")
+	fs.StringVar(&v.manifestCSV, "manifest", "", "CSV of inputs to transcribe, with optional prompt, language and output columns; results go to <name>.results.csv")
+	fs.BoolVar(&v.resume, "resume", false, "Continue the interrupted batch run whose outputs are in -o (or the current directory)")
+	fs.DurationVar(&v.opts.timeout, "timeout", 0, "Give up on a file after this long, e.g. 10m (0 means no limit)")
+	fs.IntVar(&v.maxRetries, "retries", transcribe.DefaultMaxRetries, "Retries on rate limits (429) and server errors (5xx)")
+	fs.DurationVar(&v.retryDelay, "retry-delay", transcribe.DefaultRetryDelay, "Initial retry backoff, doubled on each attempt")
+	fs.IntVar(&v.rpm, "rpm", 0, "Send at most this many API requests a minute (0 means no limit)")
+	fs.IntVar(&v.concurrent, "concurrent", 0, "Keep at most this many API requests in flight (0 means no limit)")
+	fs.BoolVar(&v.mic, "mic", false, "Transcribe live from the microphone until Ctrl-C (needs ffmpeg)")
+	fs.StringVar(&v.micDevice, "mic-device", "", "Audio input device for --mic (default: the system default)")
+	fs.DurationVar(&v.micSegment, "mic-segment", defaultMicSegment, "Length of each recorded segment sent with --mic")
+	fs.BoolVar(&v.dryRun, "dry-run", false, "Convert the audio and show the requests that would be sent, with estimated tokens and cost, without calling the API")
+	fs.StringVar(&v.recordDir, "record", "", "Save every API response to this directory for --replay")
+	fs.StringVar(&v.replayDir, "replay", "", "Answer API requests from the responses saved by --record in this directory, without network or API key")
+	fs.BoolVar(&v.failOnEmpty, "fail-on-empty", false, "Exit with an error when no speech is detected")
+	fs.BoolVar(&v.showVersion, "version", false, "Print the version, commit and build date and exit")
+
+	switch command {
+	case "translate":
+		fs.StringVar(&v.opts.TranslateTo, "to", "", "Language to translate the transcript into (e.g. English, fr)")
+	case "summarize":
+		fs.StringVar(&v.opts.SummaryStyle, "style", "", "Summary style: "+strings.Join(transcribe.SummaryStyleNames, ", ")+", or an instruction")
+	}
 
-	flag.StringVar(&inputFile, "i", "", "Input audio/video file (required)")
-	flag.StringVar(&inputFile, "input", "", "Input audio/video file (required)")
-	flag.StringVar(&apiKey, "k", "", "Gemini API key (or set GEMINI_API_KEY)")
-	flag.StringVar(&apiKey, "key", "", "Gemini API key (or set GEMINI_API_KEY)")
-	flag.StringVar(&model, "m", defaultModel, "Gemini model to use")
-	flag.StringVar(&model, "model", defaultModel, "Gemini model to use")
-	flag.StringVar(&baseURL, "base-url", "", "Custom API base URL (or set GEMINI_BASE_URL)")
-	flag.StringVar(&baseURL, "b", "", "Custom API base URL (or set GEMINI_BASE_URL)")
-	flag.StringVar(&prompt, "p", "Transcribe this audio accurately. Output only the transcription, no extra commentary.", "Custom prompt")
-	flag.StringVar(&prompt, "prompt", "Transcribe this audio accurately. Output only the transcription, no extra commentary.", "Custom prompt")
-	flag.BoolVar(&outputJSON, "json", false, "Output as JSON")
-	flag.BoolVar(&verbose, "v", false, "Verbose output")
-	flag.BoolVar(&verbose, "verbose", false, "Verbose output")
-
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "gemini-transcribe - Transcribe audio/video using Gemini API\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe -i <file> [options]\n\n")
+	fs.Usage = func() {
+		switch command {
+		case "translate":
+			fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe translate --to <language> -i <file|dir|glob> [options] [more files...]\n\n")
+			fmt.Fprintf(os.Stderr, "Transcribes the input and translates the transcript (same as transcribe --translate).\n\n")
+		case "summarize":
+			fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe summarize [--style <style>] -i <file|dir|glob> [options] [more files...]\n\n")
+			fmt.Fprintf(os.Stderr, "Transcribes the input and outputs only a summary (same as transcribe --summary-only).\n\n")
+		default:
+			fmt.Fprintf(os.Stderr, "gemini-transcribe - Transcribe audio/video using Gemini API\n\n")
+			fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe [transcribe] -i <file|dir|glob> [options] [more files...]\n")
+			fmt.Fprintf(os.Stderr, "       gemini-transcribe <command> [options]\n\n")
+			printCommands()
+		}
 		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
+		fs.PrintDefaults()
+		if command != "transcribe" {
+			return
+		}
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i audio.mp3\n")
 		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i video.mp4 -m gemini-2.5-flash\n")
 		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i recording.wav --json\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i talk.mp4 --format srt > talk.srt\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i talk.mp4 --format srt -o subtitles/\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i './recordings/*.mp3' --format srt -j 4\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe translate --to English -i interview.mp3 --json\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe summarize --style bullets -i meeting.m4a\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe --from-url https://www.youtube.com/watch?v=... --format srt\n")
+		fmt.Fprintf(os.Stderr, "  gemini-transcribe --mic\n")
 		fmt.Fprintf(os.Stderr, "  gemini-transcribe -i audio.ogg -b https://gemini-proxy.example.workers.dev\n")
 		fmt.Fprintf(os.Stderr, "\nSupported formats: mp3, wav, ogg, flac, m4a, mp4, webm, mov, avi, mkv\n")
 	}
+	return fs
+}
 
-	flag.Parse()
+// runTranscribe implements the transcribe command and its translate and
+// summarize forms, which are transcribe with --translate (given as --to)
+// and --summary-only (with the style as --style).
+func runTranscribe(command string, args []string) {
+	var (
+		v       transcribeFlags
+		apiKey  string
+		apiKeys []string
+		baseURL string
+	)
+	fs := v.flagSet(command)
+	fs.Parse(args)
 
-	// Get API key
-	if apiKey == "" {
-		apiKey = os.Getenv("GEMINI_API_KEY")
+	// Collect extra inputs, allowing flags after them (e.g. a shell-expanded
+	// "-i recordings/*.mp3" followed by more options)
+	var extraInputs []string
+	for args := fs.Args(); len(args) > 0; args = fs.Args() {
+		extraInputs = append(extraInputs, args[0])
+		fs.Parse(args[1:])
 	}
-	if apiKey == "" {
-		// Try config file
-		if home, err := os.UserHomeDir(); err == nil {
-			keyFile := filepath.Join(home, ".config", "gemini", "api_key")
-			if data, err := os.ReadFile(keyFile); err == nil {
-				apiKey = strings.TrimSpace(string(data))
+	opts, g := v.opts, v.g
+	if v.showVersion {
+		fmt.Println(currentBuild())
+		return
+	}
+	g.initLogging(false)
+	apiKey, baseURL, opts.proxy, opts.verbose = g.apiKey, g.baseURL, g.proxy, g.verbose
+	switch command {
+	case "translate":
+		if opts.TranslateTo == "" {
+			errorf("Error: translate needs --to <language>")
+			os.Exit(1)
+		}
+	case "summarize":
+		opts.summaryOnly = true
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["k"] && !set["key"] && !v.vertex && v.keysFile == "" && v.replayDir == "" && firstRun() {
+		offerSetup()
+	}
+
+	// Profile settings fill in whatever wasn't given as a flag
+	cfg, err := loadConfig()
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	prof, err := cfg.profile(v.profileName)
+	if err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	if !set["k"] && !set["key"] {
+		if apiKey, err = prof.apiKey(); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+	if !set["m"] && !set["model"] && prof.Model != "" {
+		opts.Model = prof.Model
+	}
+	// A comma-separated -m is a fallback chain, tried in order
+	if models := splitList(opts.Model); len(models) > 0 {
+		opts.Model, opts.FallbackModels = models[0], models[1:]
+	}
+	if !set["b"] && !set["base-url"] && prof.BaseURL != "" {
+		baseURL = prof.BaseURL
+	}
+	explicitPrompt := set["p"] || set["prompt"] || set["prompt-file"]
+	if explicitPrompt && v.presetName != "" {
+		errorf("Error: --preset can't be combined with -p or --prompt-file")
+		os.Exit(1)
+	}
+	if !explicitPrompt {
+		if opts.Prompt, v.promptFile, err = profilePrompt(cfg, prof, v.presetName); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+	if !set["f"] && !set["format"] && prof.Format != "" {
+		opts.format = prof.Format
+	}
+
+	if v.keysFile != "" && (v.vertex || set["k"] || set["key"]) {
+		errorf("Error: --keys-file can't be combined with -k or --vertex")
+		os.Exit(1)
+	}
+	if v.recordDir != "" && v.replayDir != "" {
+		errorf("Error: --record and --replay can't be combined")
+		os.Exit(1)
+	}
+	if !v.vertex {
+		if v.replayDir != "" && v.keysFile == "" && apiKey == "" {
+			// Replayed requests never reach the API, so any key will do
+			if apiKey, err = resolveAPIKey(""); err != nil {
+				apiKey = "replay"
+			}
+		}
+		if v.keysFile != "" {
+			if apiKeys, err = readAPIKeys(v.keysFile); err != nil {
+				errorf("Error: %v", err)
+				os.Exit(1)
+			}
+		} else {
+			if apiKey, err = resolveAPIKey(apiKey); err != nil {
+				fail(opts, "", err, "Error: "+err.Error())
+			}
+			if apiKeys = splitList(apiKey); len(apiKeys) == 0 {
+				fail(opts, "", errNoAPIKey, "Error: "+errNoAPIKey.Error())
 			}
 		}
+		baseURL = resolveBaseURL(baseURL)
 	}
-	if apiKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: API key required. Use -k flag, set GEMINI_API_KEY, or store in ~/.config/gemini/api_key")
+
+	// Get prompt
+	if opts.Prompt == "" {
+		opts.Prompt, err = resolvePrompt(v.promptFile)
+		if err != nil {
+			errorf("Error reading prompt: %v", err)
+			os.Exit(1)
+		}
+	}
+	if v.vocabFile != "" {
+		if opts.Vocabulary, err = readVocabulary(v.vocabFile); err != nil {
+			errorf("Error reading vocabulary: %v", err)
+			os.Exit(1)
+		}
+	}
+	if set["audio-track"] {
+		if v.audioTrack < 0 {
+			errorf("Error: --audio-track must be 0 or more")
+			os.Exit(1)
+		}
+		opts.AudioTrack = v.audioTrack + 1
+	}
+	if _, ok := transcribe.Conversions[opts.ConvertTo]; !ok {
+		errorf("Error: Unknown --convert-to %q (want mp3, opus, flac or wav)", opts.ConvertTo)
 		os.Exit(1)
 	}
+	if set["convert-to"] && v.ffmpegArgs != "" {
+		errorf("Error: --convert-to can't be combined with --ffmpeg-args")
+		os.Exit(1)
+	}
+	if err := transcribe.CheckChannel(opts.Channel); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	if opts.FFmpegArgs, err = splitArgs(v.ffmpegArgs); err != nil {
+		errorf("Error: Invalid --ffmpeg-args: %v", err)
+		os.Exit(1)
+	}
+	if opts.FFmpegInputArgs, err = splitArgs(v.ffmpegIn); err != nil {
+		errorf("Error: Invalid --ffmpeg-input-args: %v", err)
+		os.Exit(1)
+	}
+	if v.rulesFile != "" {
+		if opts.Rules, err = loadRules(v.rulesFile); err != nil {
+			errorf("Error reading rules: %v", err)
+			os.Exit(1)
+		}
+	}
 
-	// Get base URL
-	if baseURL == "" {
-		baseURL = os.Getenv("GEMINI_BASE_URL")
+	// Get output format
+	if v.outputJSON && v.chaptersOut {
+		errorf("Error: --json and --chapters can't be combined; use --formats json,chapters")
+		os.Exit(1)
 	}
-	if baseURL == "" {
-		baseURL = defaultBaseURL
+	if v.chaptersOut {
+		opts.format = "chapters"
+	}
+	if v.outputJSON || (opts.Words && opts.format == "text") {
+		opts.format = "json"
+	}
+	if v.formatList != "" {
+		if set["f"] || set["format"] || v.outputJSON || v.chaptersOut || v.tmplFile != "" {
+			errorf("Error: --formats can't be combined with --format, --json, --chapters or --template")
+			os.Exit(1)
+		}
+		for _, f := range splitList(v.formatList) {
+			if f == "txt" {
+				f = "text"
+			}
+			if !slices.Contains(opts.formats, f) {
+				opts.formats = append(opts.formats, f)
+			}
+		}
+		if len(opts.formats) == 0 {
+			errorf("Error: --formats needs at least one format")
+			os.Exit(1)
+		}
+		opts.format = opts.formats[0]
+	}
+	for _, f := range opts.formatList() {
+		switch f {
+		case "text", "json":
+		case "srt", "vtt", "md", "whisper-json", "chapters":
+		default:
+			errorf("Error: Unknown format %q (want text, json, srt, vtt, md, whisper-json or chapters)", f)
+			os.Exit(1)
+		}
+	}
+	if v.tmplFile != "" {
+		if set["f"] || set["format"] || v.outputJSON || v.chaptersOut {
+			errorf("Error: --template can't be combined with --format, --json or --chapters")
+			os.Exit(1)
+		}
+		if opts.template, err = loadTemplate(v.tmplFile); err != nil {
+			errorf("Error in --template: %v", err)
+			os.Exit(1)
+		}
+		opts.format = "template"
 	}
-	// Remove trailing slash if present
-	baseURL = strings.TrimSuffix(baseURL, "/")
 
-	// Validate input
-	if inputFile == "" {
-		fmt.Fprintln(os.Stderr, "Error: Input file required. Use -i flag")
-		flag.Usage()
+	if opts.karaoke {
+		if !slices.ContainsFunc(opts.formatList(), func(f string) bool { return f == "srt" || f == "vtt" }) {
+			errorf("Error: --word-timestamps-to-srt only works with SRT and WebVTT output")
+			os.Exit(1)
+		}
+		opts.Words = true
+	}
+
+	if v.vertex && opts.Upload == "always" {
+		errorf("Error: --upload always is not available with --vertex (Vertex AI has no Files API)")
+		os.Exit(1)
+	}
+	switch opts.Upload {
+	case "auto", "always", "never":
+	default:
+		errorf("Error: Unknown upload mode %q (want auto, always or never)", opts.Upload)
 		os.Exit(1)
 	}
 
-	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: File not found: %s\n", inputFile)
+	if v.timeRange != "" {
+		start, end, ok := strings.Cut(v.timeRange, "-")
+		if !ok || timeFlag(&opts.Start)(start) != nil || timeFlag(&opts.End)(end) != nil {
+			errorf("Error: Invalid --range %q (want START-END, e.g. 00:10:00-00:25:00)", v.timeRange)
+			os.Exit(1)
+		}
+	}
+	if opts.End > 0 && opts.End <= opts.Start {
+		errorf("Error: --end must be after --start")
 		os.Exit(1)
 	}
 
-	// Convert to audio if needed
-	audioData, mimeType, err := prepareAudio(inputFile, verbose)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error preparing audio: %v\n", err)
+	if opts.ChunkDuration < 0 || opts.ChunkOverlap < 0 {
+		errorf("Error: --chunk-duration and --chunk-overlap must not be negative")
+		os.Exit(1)
+	}
+	if opts.ChunkDuration > 0 && opts.ChunkOverlap >= opts.ChunkDuration {
+		errorf("Error: --chunk-overlap must be shorter than --chunk-duration")
 		os.Exit(1)
 	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Audio size: %d bytes, MIME: %s\n", len(audioData), mimeType)
-		fmt.Fprintf(os.Stderr, "Sending to Gemini (%s)...\n", model)
+	if v.genConfig.MaxOutputTokens < 0 {
+		errorf("Error: --max-output-tokens must not be negative")
+		os.Exit(1)
+	}
+	if opts.MaxContinuations < 0 {
+		errorf("Error: --max-continuations must not be negative")
+		os.Exit(1)
+	}
+	if v.rpm < 0 || v.concurrent < 0 {
+		errorf("Error: --rpm and --concurrent must not be negative")
+		os.Exit(1)
+	}
+	if opts.SafetySettings, err = transcribe.SafetyPreset(v.safety); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	if v.genConfig != (transcribe.GenerationConfig{}) {
+		opts.GenerationConfig = &v.genConfig
 	}
 
-	// Call Gemini API
-	transcription, err := transcribe(apiKey, model, baseURL, audioData, mimeType, prompt)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error transcribing: %v\n", err)
+	// One request serves every format, so it asks for whatever any of
+	// them needs. JSON carries segments, duration and the detected language,
+	// and Markdown puts a heading over each topic.
+	opts.Summarize = opts.Summarize || opts.summaryOnly
+	for _, f := range opts.formatList() {
+		opts.Timestamps = opts.Timestamps || f != "text"
+		opts.DetectLanguage = opts.DetectLanguage || hasLanguage(f)
+		opts.Topics = opts.Topics || f == "md" || f == "template" || f == "chapters"
+		if opts.Summarize && f != "text" && f != "json" && f != "md" && f != "template" {
+			errorf("Error: --summarize only works with text, JSON, Markdown and --template output")
+			os.Exit(1)
+		}
+	}
+
+	// --embed puts the language in the tags, topics in MP4 chapters and
+	// segments in MKV subtitles
+	if opts.embed {
+		opts.Timestamps, opts.Topics, opts.DetectLanguage = true, true, true
+	}
+	if opts.chapterMeta {
+		opts.Timestamps, opts.Topics = true, true
+	}
+	opts.Timestamps = opts.Timestamps || v.burnIn != ""
+
+	if opts.cues.FPS < 0 || opts.cues.MinDuration < 0 || opts.cues.MaxDuration < 0 {
+		errorf("Error: --fps, --min-cue-duration and --max-cue-duration must not be negative")
+		os.Exit(1)
+	}
+	if opts.cues.MaxDuration > 0 && opts.cues.MinDuration > opts.cues.MaxDuration {
+		errorf("Error: --min-cue-duration must not be longer than --max-cue-duration")
 		os.Exit(1)
 	}
 
-	// Output
-	if outputJSON {
-		result := map[string]string{
-			"transcription": transcription,
-			"model":         model,
-			"file":          inputFile,
+	var compareModels []string
+	if v.compare != "" {
+		if compareModels = splitList(v.compare); len(compareModels) < 2 {
+			errorf("Error: --compare needs at least two models, separated by commas")
+			os.Exit(1)
+		}
+		if set["m"] || set["model"] {
+			errorf("Error: --compare picks the models and can't be combined with -m")
+			os.Exit(1)
+		}
+		if opts.format != "text" && opts.format != "json" || len(opts.formats) > 1 || opts.output != "" || opts.sidecar ||
+			opts.stream || opts.embed || opts.chapterMeta || v.burnIn != "" || v.dbPath != "" || v.mic {
+			errorf("Error: --compare prints the comparison and only works with text or JSON output to stdout")
+			os.Exit(1)
+		}
+	}
+
+	switch {
+	case v.clean && v.verbatim:
+		errorf("Error: --clean and --verbatim can't be combined")
+		os.Exit(1)
+	case v.clean:
+		opts.Style = "clean"
+	case v.verbatim:
+		opts.Style = "verbatim"
+	}
+	if v.profanity {
+		if v.profanityAs == "" {
+			v.profanityAs = "mask"
+		}
+		if err := transcribe.CheckProfanity(v.profanityAs); err != nil {
+			errorf("Error: --filter-profanity: %v", err)
+			os.Exit(1)
+		}
+		opts.Profanity = v.profanityAs
+	}
+	if opts.stream && (len(opts.Redact) > 0 || opts.Profanity != "") {
+		errorf("Error: --redact and --filter-profanity can't be combined with --stream, which prints the text before it's filtered")
+		os.Exit(1)
+	}
+	if opts.stream && (opts.format != "text" || len(opts.formats) > 1 || opts.TwoPassTranslate || opts.DetectLanguage || opts.Summarize) {
+		errorf("Error: --stream only works with text output for a single file")
+		os.Exit(1)
+	}
+
+	var client *transcribe.Client
+	if v.vertex {
+		client, err = newVertexClient(v.project, v.location, v.credentials)
+		if err != nil {
+			fail(opts, "", err, "Error: "+err.Error())
+		}
+		if baseURL != "" {
+			client.BaseURL = strings.TrimSuffix(baseURL, "/")
 		}
-		out, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(out))
 	} else {
-		fmt.Println(transcription)
+		client = transcribe.NewClient(apiKeys[0])
+		client.BaseURL = baseURL
+		if len(apiKeys) > 1 {
+			client.Keys = transcribe.NewKeyPool(apiKeys)
+		}
+	}
+	if client.HTTPClient, err = newHTTPClient(opts.proxy); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	// Buckets go through the proxy too, but aren't recorded or replayed
+	objectStore.HTTPClient, _ = newHTTPClient(opts.proxy)
+	switch {
+	case v.recordDir != "":
+		client.HTTPClient.Transport = &transcribe.RecordTransport{Dir: v.recordDir, Next: client.HTTPClient.Transport}
+	case v.replayDir != "":
+		client.HTTPClient.Transport = &transcribe.ReplayTransport{Dir: v.replayDir}
+	}
+	client.MaxRetries = v.maxRetries
+	client.RetryDelay = v.retryDelay
+	if v.rpm > 0 || v.concurrent > 0 {
+		client.Limiter = transcribe.NewRateLimiter(v.rpm, v.concurrent)
+	}
+	if v.ffmpegPath != "" {
+		client.FFmpegPath = v.ffmpegPath
+		client.FFprobePath = ffprobeNextTo(v.ffmpegPath)
+	}
+	// Cached results would bypass the recordings
+	if !v.noCache && v.recordDir == "" && v.replayDir == "" {
+		client.Cache = newCache()
+	}
+	if opts.verbose {
+		client.Logf = debugf
 	}
-}
 
-func prepareAudio(inputFile string, verbose bool) ([]byte, string, error) {
-	ext := strings.ToLower(filepath.Ext(inputFile))
+	if v.dryRun {
+		if v.mic {
+			errorf("Error: --dry-run can't be combined with --mic")
+			os.Exit(1)
+		}
+		opts.dryRun = &dryRunReport{}
+	}
 
-	// Check if ffmpeg is available
-	if _, err := exec.LookPath("ffmpeg"); err != nil {
-		// No ffmpeg, try to read file directly
-		if verbose {
-			fmt.Fprintln(os.Stderr, "ffmpeg not found, reading file directly...")
+	if v.mic {
+		if opts.format != "text" || len(opts.formats) > 1 || v.inputFile != "" {
+			errorf("Error: --mic takes no input file and only works with text output")
+			os.Exit(1)
 		}
-		data, err := os.ReadFile(inputFile)
-		if err != nil {
-			return nil, "", err
+		if v.micSegment <= 0 {
+			errorf("Error: --mic-segment must be positive")
+			os.Exit(1)
+		}
+		if err := runMic(client, opts, v.micDevice, v.micSegment); err != nil {
+			fail(opts, "", err, "Error: "+err.Error())
+		}
+		return
+	}
+
+	if v.dbPath != "" && !v.dryRun {
+		if opts.db, err = openTranscriptDB(v.dbPath); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if v.fromURL != "" {
+		if v.inputFile != "" || len(extraInputs) > 0 {
+			errorf("Error: --from-url can't be combined with -i")
+			os.Exit(1)
+		}
+		if !isURL(v.fromURL) {
+			errorf("Error: --from-url needs an http(s) URL, got %q", v.fromURL)
+			os.Exit(1)
+		}
+		v.inputFile = v.fromURL
+		opts.ytdlp = true
+	}
+	if (opts.embed || opts.chapterMeta) && (opts.ytdlp || isURL(v.inputFile) || isObject(v.inputFile) || v.inputFile == "-") {
+		errorf("Error: --embed and --write-chapters write into the input files, so they only work with local files")
+		os.Exit(1)
+	}
+	if v.burnIn != "" && (opts.ytdlp || isURL(v.inputFile) || isObject(v.inputFile) || v.inputFile == "-") {
+		errorf("Error: --burn-in re-encodes the input video, so it only works with a local file")
+		os.Exit(1)
+	}
+
+	// --resume takes its inputs from the progress saved by an earlier
+	// batch run, skipping the files it finished
+	var inputs []string
+	var done map[string]string
+	if v.resume {
+		if v.inputFile != "" || len(extraInputs) > 0 {
+			errorf("Error: --resume continues the saved batch and can't be combined with -i or --from-url")
+			os.Exit(1)
+		}
+		var err error
+		if inputs, done, err = loadBatchState(opts.output); err != nil {
+			fail(opts, "", err, "Error: "+err.Error())
+		}
+	}
+	// --manifest lists the inputs, each with its own prompt, language and
+	// output name
+	if v.manifestCSV != "" {
+		if v.inputFile != "" || len(extraInputs) > 0 || v.resume {
+			errorf("Error: --manifest lists the inputs and can't be combined with -i, --from-url or --resume")
+			os.Exit(1)
+		}
+		var err error
+		if opts.manifest, err = loadManifest(v.manifestCSV); err != nil {
+			fail(opts, "", err, "Error: "+err.Error())
 		}
-		mimeType := getMimeType(ext)
-		return data, mimeType, nil
+		inputs = opts.manifest.inputs()
 	}
 
-	// Audio formats that Gemini accepts well
-	audioExts := map[string]bool{
-		".mp3": true, ".wav": true, ".ogg": true,
-		".flac": true, ".m4a": true, ".aac": true,
+	// Validate input
+	if v.inputFile == "" && !v.resume && opts.manifest == nil {
+		errorf("Error: Input file required. Use -i flag")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if opts.sidecar && opts.output != "" {
+		errorf("Error: --sidecar can't be combined with -o")
+		os.Exit(1)
 	}
 
-	// If already a good audio format and small enough, use directly
-	if audioExts[ext] {
-		info, err := os.Stat(inputFile)
-		if err == nil && info.Size() < 20*1024*1024 { // Under 20MB
-			data, err := os.ReadFile(inputFile)
-			if err != nil {
-				return nil, "", err
+	// Ctrl-C cancels the in-flight request and ffmpeg, and lets the
+	// pipeline remove its temp files before exiting
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if opts.stdinType != "" && v.inputFile != "-" {
+		errorf("Error: --mime-type only applies to -i - (audio on stdin)")
+		os.Exit(1)
+	}
+	if v.inputFile == "-" {
+		if len(extraInputs) > 0 {
+			errorf("Error: -i - reads a single input from stdin and can't be combined with other inputs")
+			os.Exit(1)
+		}
+		if opts.sidecar || len(opts.formats) > 1 && opts.output == "" {
+			errorf("Error: --sidecar and --formats need local files; use -o with -i -")
+			os.Exit(1)
+		}
+		if isTerminal(os.Stdin) {
+			errorf("Error: -i - reads audio from stdin, but stdin is a terminal")
+			os.Exit(1)
+		}
+	}
+
+	// Directories, globs and extra arguments switch to batch mode
+	if v.resume || opts.manifest != nil || isBatchInput(v.inputFile, extraInputs) {
+		if opts.stream {
+			errorf("Error: --stream only works with text output for a single file")
+			os.Exit(1)
+		}
+		if v.burnIn != "" {
+			errorf("Error: --burn-in writes one video, so it only works with a single input file")
+			os.Exit(1)
+		}
+		if compareModels != nil {
+			errorf("Error: --compare only works with a single input file")
+			os.Exit(1)
+		}
+		if !v.resume && opts.manifest == nil {
+			var err error
+			if inputs, err = expandInputs(append([]string{v.inputFile}, extraInputs...)); err != nil {
+				fail(opts, "", err, "Error: "+err.Error())
 			}
-			return data, getMimeType(ext), nil
 		}
+		if opts.output != "" {
+			if err := makeOutputDir(opts.output); err != nil {
+				errorf("Error: %v", err)
+				os.Exit(1)
+			}
+		}
+		if err := checkSidecarInputs(inputs, opts); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+		if err := checkOutputCollisions(inputs, opts); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+		if code := runBatch(ctx, client, inputs, opts, v.failOnEmpty, done); code != 0 {
+			os.Exit(code)
+		}
+		return
 	}
 
-	// Convert to mp3 using ffmpeg
-	if verbose {
-		fmt.Fprintln(os.Stderr, "Converting to mp3 with ffmpeg...")
+	if _, err := os.Stat(v.inputFile); os.IsNotExist(err) && !isURL(v.inputFile) && !isObject(v.inputFile) && v.inputFile != "-" {
+		fail(opts, v.inputFile, err, "Error: File not found: "+v.inputFile)
+	}
+	if err := checkSidecarInputs([]string{v.inputFile}, opts); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	if v.burnIn != "" {
+		if sameFile(v.inputFile, v.burnIn) {
+			errorf("Error: --burn-in needs a new file to write the video to, not the input")
+			os.Exit(1)
+		}
+		if !hasVideo(ctx, ffprobeBinary(client), v.inputFile) {
+			errorf("Error: --burn-in needs a video, and %s has no video stream", v.inputFile)
+			os.Exit(1)
+		}
 	}
 
-	tmpFile, err := os.CreateTemp("", "gemini-transcribe-*.mp3")
-	if err != nil {
-		return nil, "", err
-	}
-	tmpPath := tmpFile.Name()
-	tmpFile.Close()
-	defer os.Remove(tmpPath)
-
-	// ffmpeg command: extract audio, convert to mp3, mono, 16kHz for speech
-	cmd := exec.Command("ffmpeg",
-		"-i", inputFile,
-		"-vn",              // No video
-		"-acodec", "libmp3lame",
-		"-ar", "16000",     // 16kHz sample rate (good for speech)
-		"-ac", "1",         // Mono
-		"-b:a", "64k",      // 64kbps (sufficient for speech)
-		"-y",               // Overwrite
-		tmpPath,
-	)
+	if compareModels != nil {
+		os.Exit(runCompare(ctx, client, v.inputFile, compareModels, opts))
+	}
+	if opts.stream {
+		opts.OnText = func(text string) { fmt.Print(text) }
+	}
+	// Batch runs convert and upload several files at once, so only a
+	// single file gets a progress bar
+	if showProgress() {
+		client.OnProgress = (&progressBar{}).update
+	}
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	start := time.Now()
+	result, err := transcribeFile(ctx, client, v.inputFile, opts)
+	if errors.Is(err, transcribe.ErrDryRun) {
+		opts.dryRun.summary()
+		return
+	}
+	if errors.Is(err, transcribe.ErrNoSpeech) {
+		if v.failOnEmpty {
+			sendWebhook(opts, newWebhookPayload(result, "", client.Stats.Snapshot(), time.Since(start), err))
+			fail(opts, v.inputFile, err, describeSilence(v.inputFile))
+		}
+		infof("%s", describeSilence(v.inputFile))
+	} else if err != nil {
+		sendWebhook(opts, newWebhookPayload(result, "", client.Stats.Snapshot(), time.Since(start), err))
+		fail(opts, v.inputFile, err, "Error "+err.Error())
+	}
 
-	if err := cmd.Run(); err != nil {
-		return nil, "", fmt.Errorf("ffmpeg failed: %v\n%s", err, stderr.String())
+	outPath, werr := finish(result, client.Stats, opts)
+	var eerr error
+	if werr != nil {
+		err = fmt.Errorf("writing output: %v", werr)
+	} else if eerr = updateInput(ctx, client, result, opts); eerr != nil {
+		err = eerr
+	}
+	if werr == nil && eerr == nil && v.burnIn != "" && len(result.Segments) > 0 {
+		var progress func(transcribe.Progress)
+		if showProgress() {
+			progress = (&progressBar{}).update
+		}
+		if eerr = burnSubtitles(ctx, ffmpegBinary(client), v.inputFile, v.burnIn, result, progress); eerr != nil {
+			err = fmt.Errorf("burning in subtitles: %v", eerr)
+		} else {
+			infof("Wrote %s with burned-in subtitles", v.burnIn)
+		}
+	}
+	if werr == nil && eerr == nil {
+		recordTranscript(opts, result)
 	}
+	sendWebhook(opts, newWebhookPayload(result, outPath, client.Stats.Snapshot(), time.Since(start), err))
+	if werr != nil {
+		errorf("Error writing output: %v", werr)
+		os.Exit(1)
+	}
+	if eerr != nil {
+		errorf("Error %v", err)
+		os.Exit(1)
+	}
+}
 
-	data, err := os.ReadFile(tmpPath)
-	if err != nil {
-		return nil, "", err
-	}
-
-	return data, "audio/mpeg", nil
-}
-
-func getMimeType(ext string) string {
-	mimeTypes := map[string]string{
-		".mp3":  "audio/mpeg",
-		".wav":  "audio/wav",
-		".ogg":  "audio/ogg",
-		".flac": "audio/flac",
-		".m4a":  "audio/mp4",
-		".aac":  "audio/aac",
-		".mp4":  "video/mp4",
-		".webm": "video/webm",
-		".mov":  "video/quicktime",
-		".avi":  "video/x-msvideo",
-		".mkv":  "video/x-matroska",
-	}
-	if mime, ok := mimeTypes[ext]; ok {
-		return mime
-	}
-	return "application/octet-stream"
-}
-
-func transcribe(apiKey, model, baseURL string, audioData []byte, mimeType, prompt string) (string, error) {
-	// Build request with inline data (base64 encoded)
-	req := GeminiRequest{
-		Contents: []Content{
-			{
-				Parts: []Part{
-					{
-						InlineData: &BlobData{
-							MimeType: mimeType,
-							Data:     base64.StdEncoding.EncodeToString(audioData),
-						},
-					},
-					{
-						Text: prompt,
-					},
-				},
-			},
-		},
-	}
-
-	reqBody, err := json.Marshal(req)
+// transcribeFile runs the library pipeline on one input, downloading it
+// first when it is a URL (through yt-dlp for --from-url) or reading it from
+// stdin for "-", within opts.timeout when set, and wraps the result in the
+// CLI's output shape.
+func transcribeFile(ctx context.Context, client *transcribe.Client, inputFile string, opts options) (jsonResult, error) {
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	path := inputFile
+	switch {
+	case opts.ytdlp:
+		var cleanup func()
+		var err error
+		if path, cleanup, err = fetchWithYtDlp(ctx, inputFile, opts.keepAudio, opts.proxy); err != nil {
+			return jsonResult{File: inputFile, Model: opts.Model}, err
+		}
+		defer cleanup()
+	case isURL(inputFile):
+		var err error
+		if path, err = downloadInput(ctx, client.HTTPClient, inputFile); err != nil {
+			return jsonResult{File: inputFile, Model: opts.Model}, err
+		}
+		defer os.Remove(path)
+	case isObject(inputFile):
+		var err error
+		if path, err = downloadObject(ctx, inputFile); err != nil {
+			return jsonResult{File: inputFile, Model: opts.Model}, err
+		}
+		defer os.Remove(path)
+	case inputFile == "-":
+		var err error
+		if path, err = readStdin(opts.stdinType, ffmpegBinary(client)); err != nil {
+			return jsonResult{File: inputFile, Model: opts.Model}, err
+		}
+		defer os.Remove(path)
+	}
+
+	if report := opts.dryRun; report != nil {
+		opts.DryRun = func(req transcribe.DryRunRequest) { report.add(inputFile, req) }
+	}
+	res, err := client.TranscribeFile(ctx, path, opts.Options)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("transcribing: timed out after %s", opts.timeout)
+	} else if err != nil && ctx.Err() == context.Canceled {
+		err = fmt.Errorf("transcribing: %w", errInterrupted)
+	}
+	var title string
+	var chapters []chapter
+	// Markdown and templates (the formats asking for topics) show a title
+	if opts.Topics && err == nil {
+		title, chapters = probeMetadata(ctx, ffprobeBinary(client), path)
+		switch {
+		case title != "":
+		case opts.ytdlp:
+			// The download is named after the video
+			title = titleFromPath(path)
+		case inputFile == "-":
+			title = "Transcript"
+		default:
+			title = titleFromPath(inputFile)
+		}
+	}
+	var confidence string
+	if len(res.Suspicions) > 0 {
+		confidence = "low"
+		warnf("Warning: %s: the transcript may be made up: %s", inputFile, strings.Join(res.Suspicions, "; "))
+	}
+	return jsonResult{
+		File:          inputFile,
+		Model:         res.Model,
+		Version:       currentBuild().Version,
+		Language:      res.Language,
+		Duration:      res.Duration,
+		Transcription: res.Text,
+		TranslatedTo:  res.TranslatedTo,
+		Source:        res.SourceText,
+		Summary:       res.Summary,
+		Confidence:    confidence,
+		Warnings:      res.Suspicions,
+		Segments:      res.Segments,
+		Title:         title,
+		Chapters:      chapters,
+	}, err
+}
+
+// describeSilence formats the stderr notice printed when the API call is skipped.
+func describeSilence(inputFile string) string {
+	return fmt.Sprintf("No speech detected in %s (peak level below %.0f dB), skipping API call", inputFile, transcribe.SilenceThresholdDB)
+}
+
+// finish prints or writes the result and, in verbose modes, the run's API
+// statistics. It returns the file written, or "" for stdout.
+func finish(result jsonResult, stats *transcribe.Stats, opts options) (string, error) {
+	if opts.stream {
+		// The text has already been printed as it arrived
+		fmt.Println()
+	}
+
+	snap := stats.Snapshot()
+	debugf("%s", snap.Summary())
+	if opts.showCost {
+		infof("%s", describeCost(result.Model, snap))
+	}
+	if opts.DetectLanguage && !slices.ContainsFunc(opts.formatList(), hasLanguage) && result.Language != "" {
+		infof("Detected language: %s", result.Language)
+	}
+	result.Usage = usageFor(result.Model, snap, opts.showCost)
+
+	if opts.verboseJSON {
+		result.Meta = &snap
+	}
+	if opts.output != "" || opts.sidecar || len(opts.formats) > 1 {
+		outPath := batchOutputPath(result.File, "", opts.format)
+		if opts.output != "" {
+			outPath = singleOutputPath(result.File, opts.output, opts.format)
+		}
+		if err := makeOutputDir(filepath.Dir(outPath)); err != nil {
+			return "", err
+		}
+		if err := writeResult(result, outPath, opts); err != nil {
+			return "", err
+		}
+		debugf("Wrote %s", outPath)
+		return outPath, nil
+	}
+	if !opts.stream {
+		fmt.Print(renderResult(result, opts))
+	}
+	return "", nil
+}
+
+// formatList returns every format being written: --formats, or just the
+// one format.
+func (o options) formatList() []string {
+	if len(o.formats) > 0 {
+		return o.formats
+	}
+	return []string{o.format}
+}
+
+// singleOutputPath resolves -o for a single input: an existing directory, or
+// a path ending in a separator, gets a file named after the input.
+func singleOutputPath(input, output, format string) string {
+	if info, err := os.Stat(output); err == nil && info.IsDir() || strings.HasSuffix(output, string(filepath.Separator)) || isObject(output) && strings.HasSuffix(output, "/") {
+		return batchOutputPath(input, output, format)
+	}
+	return output
+}
+
+// writeResult writes the rendered result to outPath. The other --formats
+// go next to it with their own extensions (talk.srt and talk.vtt), and so
+// does the plain transcript with --sidecar and a format other than text
+// (talk.srt and talk.txt).
+func writeResult(result jsonResult, outPath string, opts options) error {
+	if err := writeOutput(outPath, []byte(renderResult(result, opts))); err != nil {
+		return err
+	}
+	for _, f := range opts.formats[min(1, len(opts.formats)):] {
+		fOpts := opts
+		fOpts.format = f
+		if err := writeOutput(batchOutputPath(outPath, "", f), []byte(renderResult(result, fOpts))); err != nil {
+			return err
+		}
+	}
+	if !opts.sidecar || slices.Contains(opts.formatList(), "text") {
+		return nil
+	}
+	textOpts := opts
+	textOpts.format = "text"
+	return writeOutput(batchOutputPath(outPath, "", "text"), []byte(renderResult(result, textOpts)))
+}
+
+// usageFor converts a run's stats into the JSON usage block.
+func usageFor(model string, snap transcribe.StatsSnapshot, showCost bool) *usageJSON {
+	u := &usageJSON{
+		PromptTokens: snap.PromptTokens,
+		OutputTokens: snap.OutputTokens + snap.ThoughtsTokens,
+		TotalTokens:  snap.TotalTokens,
+	}
+	if cost, ok := transcribe.EstimateCost(model, snap); ok && showCost {
+		u.CostUSD = &cost
+	}
+	return u
+}
+
+// describeCost formats the --show-cost line printed to stderr.
+func describeCost(model string, snap transcribe.StatsSnapshot) string {
+	cost, ok := transcribe.EstimateCost(model, snap)
+	if !ok {
+		return fmt.Sprintf("Estimated cost: unknown (no price for %s)", model)
+	}
+	return fmt.Sprintf("Estimated cost: %s (%s list price)", formatCost(cost), model)
+}
+
+// formatCost formats a cost in dollars, with more decimals for small ones.
+func formatCost(cost float64) string {
+	prec := 4
+	if cost < 0.01 {
+		prec = 6
+	}
+	return fmt.Sprintf("$%.*f", prec, cost)
+}
+
+// renderResult formats a result in the selected output format.
+func renderResult(result jsonResult, opts options) string {
+	switch opts.format {
+	case "json":
+		if !opts.verboseJSON {
+			result.Meta = nil
+		}
+		if opts.summaryOnly {
+			result.Transcription, result.Segments = "", nil
+		}
+		out, _ := json.MarshalIndent(result, "", "  ")
+		return string(out) + "\n"
+	case "md":
+		return renderMarkdown(result, opts.summaryOnly)
+	case "template":
+		return renderTemplate(opts.template, result)
+	case "whisper-json":
+		out, _ := json.Marshal(toWhisperJSON(result))
+		return string(out) + "\n"
+	case "chapters":
+		return renderChapters(result)
+	case "srt":
+		cues := transcribe.ShapeCues(result.Segments, opts.cues)
+		if opts.karaoke {
+			cues = transcribe.KaraokeSRT(cues)
+		}
+		return transcribe.FormatSRT(cues)
+	case "vtt":
+		cues := transcribe.ShapeCues(result.Segments, opts.cues)
+		if opts.karaoke {
+			cues = transcribe.KaraokeVTT(cues)
+		}
+		return transcribe.FormatVTT(cues, opts.cueSettings)
+	default:
+		switch {
+		case result.Summary == "":
+			return result.Transcription + "\n"
+		case opts.summaryOnly:
+			return result.Summary + "\n"
+		}
+		return result.Transcription + "\n\nSummary:\n" + result.Summary + "\n"
+	}
+}
+
+// floatFlag parses a float flag within [lo, hi] into *p, leaving it nil
+// when the flag isn't given.
+func floatFlag(p **float64, lo, hi float64) func(string) error {
+	return func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		if v < lo || v > hi {
+			return fmt.Errorf("must be between %g and %g", lo, hi)
+		}
+		*p = &v
+		return nil
+	}
+}
+
+// timeFlag parses a position given as a timestamp (1:30:00, 90.5) or a
+// Go duration (90m) into *p.
+func timeFlag(p *time.Duration) func(string) error {
+	return func(s string) error {
+		if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+			*p = d
+			return nil
+		}
+		secs, err := transcribe.ParseTimestamp(strings.TrimSpace(s))
+		if err != nil || secs < 0 {
+			return fmt.Errorf("invalid time %q", s)
+		}
+		*p = time.Duration(secs * float64(time.Second))
+		return nil
+	}
+}
+
+// optionalFlag is a string flag whose value may be left off, as in
+// --summarize or --summarize=brief. The flag package treats it like a bool,
+// so a value must be attached with "=".
+type optionalFlag struct {
+	set   *bool
+	value *string
+}
+
+func (f optionalFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f optionalFlag) Set(s string) error {
+	switch s {
+	case "true":
+		*f.set = true
+	case "false":
+		*f.set = false
+	default:
+		*f.set, *f.value = true, s
+	}
+	return nil
+}
+
+func (f optionalFlag) IsBoolFlag() bool { return true }
+
+// outputExts are the file extensions of formats not written to a file
+// named after them; --template adds its own.
+var outputExts = map[string]string{
+	"text":     ".txt",
+	"chapters": ".chapters.txt",
+	// Where whisper --output_format json writes it
+	"whisper-json": ".json",
+}
+
+// outputExt is the file extension used when writing a format to disk.
+func outputExt(format string) string {
+	if ext, ok := outputExts[format]; ok {
+		return ext
+	}
+	return "." + format
+}
+
+// hasLanguage reports whether format includes the detected language, so it
+// is always asked for and not printed separately.
+func hasLanguage(format string) bool {
+	return format == "json" || format == "whisper-json" || format == "template"
+}
+
+// newCache returns the transcript cache in the user's cache directory, or
+// nil when there isn't one.
+func newCache() *transcribe.Cache {
+	dir, err := transcribe.DefaultCacheDir()
 	if err != nil {
-		return "", err
+		return nil
 	}
+	return &transcribe.Cache{Dir: dir}
+}
 
-	url := fmt.Sprintf(apiURLTemplate, baseURL, model, apiKey)
-	resp, err := http.Post(url, "application/json", bytes.NewReader(reqBody))
+// resolveAPIKey returns the key from the flag, GEMINI_API_KEY, the OS
+// keyring (see config set-key) or ~/.config/gemini/api_key, or errNoAPIKey
+// when none is set.
+func resolveAPIKey(apiKey string) (string, error) {
+	if apiKey == "" {
+		apiKey = os.Getenv("GEMINI_API_KEY")
+	}
+	if apiKey == "" {
+		apiKey = keyringAPIKey()
+	}
+	if apiKey == "" {
+		// Try config file
+		if home, err := os.UserHomeDir(); err == nil {
+			keyFile := filepath.Join(home, ".config", "gemini", "api_key")
+			if data, err := os.ReadFile(keyFile); err == nil {
+				apiKey = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if apiKey == "" {
+		return "", errNoAPIKey
+	}
+	return apiKey, nil
+}
+
+// splitList splits a comma-separated list such as API keys or models.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newVertexClient builds a Vertex AI client, taking the project and region
+// from the flags or GOOGLE_CLOUD_PROJECT and GOOGLE_CLOUD_LOCATION.
+func newVertexClient(project, location, credentialsFile string) (*transcribe.Client, error) {
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if location == "" {
+		location = os.Getenv("GOOGLE_CLOUD_LOCATION")
+	}
+	if location == "" {
+		location = "us-central1"
+	}
+	var credentialsJSON []byte
+	if credentialsFile != "" {
+		data, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		credentialsJSON = data
+	}
+	return transcribe.NewVertexClient(project, location, credentialsJSON)
+}
+
+// mustResolveAPIKey is resolveAPIKey for subcommands, exiting when no key
+// is set.
+func mustResolveAPIKey(apiKey string) string {
+	apiKey, err := resolveAPIKey(apiKey)
 	if err != nil {
-		return "", err
+		errorf("Error: %v", err)
+		os.Exit(exitAuth)
+	}
+	return apiKey
+}
+
+// resolveBaseURL returns the base URL from the flag, GEMINI_BASE_URL or the
+// default, without a trailing slash.
+func resolveBaseURL(baseURL string) string {
+	if baseURL == "" {
+		baseURL = os.Getenv("GEMINI_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = defaultBaseURL
 	}
-	defer resp.Body.Close()
+	// Remove trailing slash if present
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// profilePrompt returns the prompt, or the prompt file, when no -p or
+// --prompt-file flag sets one: --preset, then GEMINI_PROMPT or
+// GEMINI_PROMPT_FILE (left for resolvePrompt), then the profile's
+// preset, prompt or prompt_file.
+func profilePrompt(cfg config, prof profile, preset string) (prompt, promptFile string, err error) {
+	switch {
+	case preset != "":
+		prompt, err = cfg.preset(preset)
+		return prompt, "", err
+	case os.Getenv("GEMINI_PROMPT") != "" || os.Getenv("GEMINI_PROMPT_FILE") != "":
+		return "", "", nil
+	case prof.Preset != "":
+		prompt, err = cfg.preset(prof.Preset)
+		return prompt, "", err
+	case prof.Prompt != "":
+		return prof.Prompt, "", nil
+	case prof.PromptFile != "":
+		return "", expandHome(prof.PromptFile), nil
+	}
+	return "", "", nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// resolvePrompt picks the prompt when -p wasn't given: --prompt-file, then
+// GEMINI_PROMPT, then GEMINI_PROMPT_FILE, then the built-in default.
+func resolvePrompt(promptFile string) (string, error) {
+	if promptFile == "" {
+		if env := os.Getenv("GEMINI_PROMPT"); env != "" {
+			return env, nil
+		}
+		promptFile = os.Getenv("GEMINI_PROMPT_FILE")
+	}
+	if promptFile == "" {
+		return defaultPrompt, nil
+	}
+	data, err := os.ReadFile(promptFile)
 	if err != nil {
 		return "", err
 	}
-
-	var geminiResp GeminiResponse
-	if err := json.Unmarshal(body, &geminiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v\nBody: %s", err, string(body))
+	p := strings.TrimSpace(string(data))
+	if p == "" {
+		return "", fmt.Errorf("prompt file %s is empty", promptFile)
 	}
+	return p, nil
+}
 
-	if geminiResp.Error != nil {
-		return "", fmt.Errorf("API error (%d): %s", geminiResp.Error.Code, geminiResp.Error.Message)
+// readVocabulary reads a --vocab file: one term per line, skipping blank
+// lines and # comments.
+func readVocabulary(path string) ([]string, error) {
+	terms, err := readLines(path)
+	if err == nil && len(terms) == 0 {
+		err = fmt.Errorf("vocabulary file %s has no terms", path)
 	}
+	return terms, err
+}
 
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no transcription in response")
+// readAPIKeys reads a file of API keys, one per line, skipping blank lines
+// and # comments.
+func readAPIKeys(path string) ([]string, error) {
+	keys, err := readLines(path)
+	if err == nil && len(keys) == 0 {
+		err = fmt.Errorf("keys file %s has no keys", path)
 	}
+	return keys, err
+}
 
-	return strings.TrimSpace(geminiResp.Candidates[0].Content.Parts[0].Text), nil
+// readLines returns the trimmed lines of a file, without blank lines and
+// # comments.
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
 }
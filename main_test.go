@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPromptPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	envFile := write("env.txt", "from GEMINI_PROMPT_FILE\n")
+	profFile := write("profile.txt", "from the profile's prompt_file")
+	cfg := config{Presets: map[string]string{"mine": "from a config preset"}}
+
+	tests := []struct {
+		name      string
+		preset    string
+		env       string
+		envFile   string
+		prof      profile
+		want      string
+		wantError bool
+	}{
+		{"built-in default", "", "", "", profile{}, defaultPrompt, false},
+		{"profile prompt", "", "", "", profile{Prompt: "from the profile"}, "from the profile", false},
+		{"profile prompt file", "", "", "", profile{PromptFile: profFile}, "from the profile's prompt_file", false},
+		{"profile preset", "", "", "", profile{Preset: "mine", Prompt: "from the profile"}, "from a config preset", false},
+		{"env over profile", "", "from GEMINI_PROMPT", "", profile{Prompt: "from the profile"}, "from GEMINI_PROMPT", false},
+		{"env file over profile", "", "", envFile, profile{Preset: "mine"}, "from GEMINI_PROMPT_FILE", false},
+		{"env over env file", "", "from GEMINI_PROMPT", envFile, profile{}, "from GEMINI_PROMPT", false},
+		{"preset flag over env", "mine", "from GEMINI_PROMPT", "", profile{Prompt: "from the profile"}, "from a config preset", false},
+		{"unknown preset", "nope", "", "", profile{}, "", true},
+		{"env skips a bad profile preset", "", "from GEMINI_PROMPT", "", profile{Preset: "nope"}, "from GEMINI_PROMPT", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GEMINI_PROMPT", tt.env)
+			t.Setenv("GEMINI_PROMPT_FILE", tt.envFile)
+			prompt, promptFile, err := profilePrompt(cfg, tt.prof, tt.preset)
+			if err == nil && prompt == "" {
+				prompt, err = resolvePrompt(promptFile)
+			}
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("got %q, want an error", prompt)
+				}
+				return
+			}
+			if err != nil || prompt != tt.want {
+				t.Errorf("prompt = %q, %v; want %q", prompt, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// manifest is a --manifest CSV file: one batch input per row, with an
+// optional prompt, language and output name for each.
+type manifest struct {
+	path   string
+	header []string
+	rows   []manifestRow
+	byPath map[string]*manifestRow
+}
+
+type manifestRow struct {
+	record   []string
+	path     string
+	prompt   string
+	language string
+	output   string
+}
+
+// manifestColumns are the columns the manifest reads; any others are
+// copied through to the results.
+var manifestColumns = []string{"path", "prompt", "language", "output"}
+
+// loadManifest reads a manifest. The first row is a header naming the
+// columns; only path is required.
+func loadManifest(path string) (*manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("reading %s: no rows after the header", path)
+	}
+
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := col["path"]; !ok {
+		return nil, fmt.Errorf("reading %s: the header has no path column (want %s)", path, strings.Join(manifestColumns, ", "))
+	}
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	m := &manifest{path: path, header: records[0], byPath: map[string]*manifestRow{}}
+	for n, record := range records[1:] {
+		row := manifestRow{
+			record:   record,
+			path:     field(record, "path"),
+			prompt:   field(record, "prompt"),
+			language: field(record, "language"),
+			output:   field(record, "output"),
+		}
+		if row.path == "" {
+			return nil, fmt.Errorf("%s:%d: empty path", path, n+2)
+		}
+		m.rows = append(m.rows, row)
+	}
+	for i := range m.rows {
+		if _, dup := m.byPath[m.rows[i].path]; dup {
+			return nil, fmt.Errorf("%s:%d: %s is listed twice", path, i+2, m.rows[i].path)
+		}
+		m.byPath[m.rows[i].path] = &m.rows[i]
+	}
+	return m, nil
+}
+
+// inputs returns the manifest's paths in order.
+func (m *manifest) inputs() []string {
+	inputs := make([]string, len(m.rows))
+	for i, row := range m.rows {
+		inputs[i] = row.path
+	}
+	return inputs
+}
+
+// apply returns opts with the input's prompt and language from the
+// manifest, when it has them.
+func (m *manifest) apply(input string, opts options) options {
+	if m == nil {
+		return opts
+	}
+	if row, ok := m.byPath[input]; ok {
+		if row.prompt != "" {
+			opts.Prompt = row.prompt
+		}
+		if row.language != "" {
+			opts.Language = row.language
+		}
+	}
+	return opts
+}
+
+// outputPath is where the output for input in format goes: the manifest's
+// output name for it, inside outDir, or the usual batch name. The format's
+// extension is added unless the name already ends in it.
+func (m *manifest) outputPath(input, outDir, format string) string {
+	if m != nil {
+		if row, ok := m.byPath[input]; ok && row.output != "" {
+			return filepath.Join(outDir, strings.TrimSuffix(row.output, outputExt(format))+outputExt(format))
+		}
+	}
+	return batchOutputPath(input, outDir, format)
+}
+
+// resultsPath is where writeResults puts the results: list.csv gets
+// list.results.csv.
+func (m *manifest) resultsPath() string {
+	return strings.TrimSuffix(m.path, filepath.Ext(m.path)) + ".results.csv"
+}
+
+// writeResults writes the manifest back with status, output and error
+// columns filled in from each row's outcome.
+func (m *manifest) writeResults(outcomes []batchOutcome) error {
+	f, err := os.Create(m.resultsPath())
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write(append(slices.Clone(m.header), "status", "output_file", "error"))
+	for i, row := range m.rows {
+		record := make([]string, len(m.header))
+		copy(record, row.record)
+		status, output, msg := "pending", "", ""
+		switch o := outcomes[i]; {
+		case o.interrupted:
+		case o.err != nil:
+			status, msg = "failed", o.err.Error()
+		case o.dryRun:
+			status = "dry-run"
+		case o.skipped:
+			status, output = "skipped", o.outPath
+		case o.silent:
+			status, output = "no-speech", o.outPath
+		default:
+			status, output = "done", o.outPath
+		}
+		w.Write(append(record, status, output, msg))
+	}
+	w.Flush()
+	return errors.Join(w.Error(), f.Close())
+}
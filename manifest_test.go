@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.csv")
+	os.WriteFile(path, []byte(`Path, Speaker, prompt, language, output
+a.mp3, Anna, "Transcribe, with ""care""", de, anna.srt
+talks/b.mp3, Ben
+c.mp3, Cleo, , , cleo
+`), 0644)
+	m, err := loadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(m.inputs(), ","); got != "a.mp3,talks/b.mp3,c.mp3" {
+		t.Errorf("inputs() = %s", got)
+	}
+
+	opts := options{}
+	opts.Prompt, opts.Language = "default prompt", "en"
+	a := m.apply("a.mp3", opts)
+	if a.Prompt != `Transcribe, with "care"` || a.Language != "de" {
+		t.Errorf("apply(a.mp3) = %q, %q", a.Prompt, a.Language)
+	}
+	if b := m.apply("talks/b.mp3", opts); b.Prompt != "default prompt" || b.Language != "en" {
+		t.Errorf("apply(talks/b.mp3) = %q, %q; want the defaults", b.Prompt, b.Language)
+	}
+
+	tests := []struct {
+		input, format, want string
+	}{
+		{"a.mp3", "srt", filepath.Join("out", "anna.srt")},
+		{"a.mp3", "vtt", filepath.Join("out", "anna.srt.vtt")},
+		{"c.mp3", "text", filepath.Join("out", "cleo.txt")},
+		{"talks/b.mp3", "json", filepath.Join("out", "b.json")},
+	}
+	for _, tt := range tests {
+		if got := m.outputPath(tt.input, "out", tt.format); got != tt.want {
+			t.Errorf("outputPath(%s, %s) = %s, want %s", tt.input, tt.format, got, tt.want)
+		}
+	}
+
+	outcomes := []batchOutcome{{outPath: "out/anna.srt"}, {err: errors.New("boom")}, {interrupted: true}}
+	if err := m.writeResults(outcomes); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "list.results.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Path,Speaker,prompt,language,output,status,output_file,error
+a.mp3,Anna,"Transcribe, with ""care""",de,anna.srt,done,out/anna.srt,
+talks/b.mp3,Ben,,,,failed,,boom
+c.mp3,Cleo,,,cleo,pending,,
+`
+	if string(data) != want {
+		t.Errorf("results =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	tests := []struct {
+		name, csv, want string
+	}{
+		{"no rows", "path,prompt\n", "no rows after the header"},
+		{"no path column", "file,prompt\na.mp3,x\n", "no path column"},
+		{"empty path", "path,prompt\na.mp3,x\n ,y\n", ":3: empty path"},
+		{"listed twice", "path\na.mp3\nb.mp3\na.mp3\n", ":4: a.mp3 is listed twice"},
+		{"bad quoting", "path\n\"a.mp3\n", "reading"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "list.csv")
+			os.WriteFile(path, []byte(tt.csv), 0644)
+			if _, err := loadManifest(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadManifest() err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+)
+
+// chapter is a chapter marker from the input's container (podcasts, videos
+// with chapters), used for headings in Markdown output.
+type chapter struct {
+	Start float64
+	Title string
+}
+
+// probeMetadata reads the title tag and chapters of path with ffprobe.
+// Anything it can't read is left empty.
+func probeMetadata(ctx context.Context, ffprobe, path string) (title string, chapters []chapter) {
+	out, err := proc.Command(ctx, ffprobe,
+		"-v", "error",
+		"-show_entries", "format_tags=title:chapter=start_time:chapter_tags=title",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return "", nil
+	}
+	var probe struct {
+		Format struct {
+			Tags struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"format"`
+		Chapters []struct {
+			StartTime string `json:"start_time"`
+			Tags      struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"chapters"`
+	}
+	if json.Unmarshal(out, &probe) != nil {
+		return "", nil
+	}
+	for _, c := range probe.Chapters {
+		start, err := strconv.ParseFloat(c.StartTime, 64)
+		if err != nil || strings.TrimSpace(c.Tags.Title) == "" {
+			continue
+		}
+		chapters = append(chapters, chapter{Start: start, Title: strings.TrimSpace(c.Tags.Title)})
+	}
+	return strings.TrimSpace(probe.Format.Tags.Title), chapters
+}
+
+// ytdlpID matches the " [id]" yt-dlp adds to the names of its downloads.
+var ytdlpID = regexp.MustCompile(`\s*\[[\w-]+\]$`)
+
+// titleFromPath turns a file name into a title: no directory, extension
+// or yt-dlp video ID.
+func titleFromPath(path string) string {
+	base := filepath.Base(path)
+	return ytdlpID.ReplaceAllString(strings.TrimSuffix(base, filepath.Ext(base)), "")
+}
+
+// renderMarkdown renders the result as Markdown: the title, a line of
+// details, the summary, then the transcript under a heading for each
+// chapter (or, without chapters, each topic), with speakers in bold and
+// each segment's timestamp linking to that point in the recording.
+func renderMarkdown(result jsonResult, summaryOnly bool) string {
+	var b strings.Builder
+	title := result.Title
+	if title == "" {
+		title = titleFromPath(result.File)
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+
+	details := []string{}
+	if result.Duration > 0 {
+		details = append(details, formatClock(result.Duration))
+	}
+	if result.Language != "" {
+		details = append(details, result.Language)
+	}
+	details = append(details, "transcribed with "+result.Model)
+	fmt.Fprintf(&b, "*%s*\n\n", strings.Join(details, " · "))
+
+	if result.Summary != "" {
+		if summaryOnly {
+			return b.String() + result.Summary + "\n"
+		}
+		fmt.Fprintf(&b, "## Summary\n\n%s\n\n", result.Summary)
+	}
+	if len(result.Segments) == 0 {
+		b.WriteString(result.Transcription + "\n")
+		return b.String()
+	}
+
+	chapters := result.Chapters
+	heading, speaker := "", ""
+	for _, seg := range result.Segments {
+		next := heading
+		if len(result.Chapters) > 0 {
+			for len(chapters) > 0 && chapters[0].Start <= seg.Start+0.5 {
+				next, chapters = chapters[0].Title, chapters[1:]
+			}
+		} else if seg.Topic != "" {
+			next = seg.Topic
+		}
+		if next != heading {
+			heading, speaker = next, ""
+			fmt.Fprintf(&b, "## %s\n\n", heading)
+		}
+
+		at := math.Floor(seg.Start)
+		fmt.Fprintf(&b, "[%s](%s) ", formatClock(at), timestampLink(result.File, at))
+		if seg.Speaker != "" && seg.Speaker != speaker {
+			fmt.Fprintf(&b, "**%s:** ", seg.Speaker)
+			speaker = seg.Speaker
+		}
+		b.WriteString(seg.Text + "\n\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// timestampLink links to second s of the input: YouTube's t parameter for
+// YouTube URLs, and a media fragment (#t=) for other URLs and local files,
+// which browsers and Obsidian start playback from.
+func timestampLink(input string, s float64) string {
+	t := int(s)
+	if u, err := url.Parse(input); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		host := strings.TrimPrefix(u.Hostname(), "www.")
+		if host == "youtube.com" || host == "youtu.be" || host == "m.youtube.com" {
+			q := u.Query()
+			q.Set("t", strconv.Itoa(t))
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+		u.Fragment = "t=" + strconv.Itoa(t)
+		return u.String()
+	}
+	return (&url.URL{Path: filepath.ToSlash(input), Fragment: "t=" + strconv.Itoa(t)}).String()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the transcription
+// duration histogram: from a short voice memo to a long recording.
+var latencyBuckets = []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// metrics counts transcriptions for /metrics, in the Prometheus text
+// format. A nil *metrics records nothing.
+type metrics struct {
+	mu           sync.Mutex
+	transcribed  map[[2]string]int64 // by source and status
+	latency      map[string]*histogram
+	audioSeconds float64
+	usage        transcribe.StatsSnapshot
+	costUSD      float64
+
+	// queueDepth reports the jobs waiting for a worker, when there is a
+	// queue
+	queueDepth func() int
+}
+
+type histogram struct {
+	counts []int64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{transcribed: map[[2]string]int64{}, latency: map[string]*histogram{}}
+}
+
+// observe records one transcription from source ("transcribe", "job",
+// "watch"...): its outcome ("ok", "no_speech" or the error class), how
+// long it took, the audio length and the tokens it used.
+func (m *metrics) observe(source, model string, audioSeconds float64, snap transcribe.StatsSnapshot, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	status := "ok"
+	switch {
+	case errors.Is(err, transcribe.ErrNoSpeech):
+		status = "no_speech"
+	case err != nil:
+		status, _ = errorClass(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transcribed[[2]string{source, status}]++
+	h := m.latency[source]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+		m.latency[source] = h
+	}
+	secs := elapsed.Seconds()
+	i, _ := slices.BinarySearch(latencyBuckets, secs)
+	h.counts[i]++
+	h.sum += secs
+	h.count++
+	m.audioSeconds += audioSeconds
+	m.usage.Requests += snap.Requests
+	m.usage.Retries += snap.Retries
+	m.usage.PromptTokens += snap.PromptTokens
+	m.usage.OutputTokens += snap.OutputTokens + snap.ThoughtsTokens
+	if cost, ok := transcribe.EstimateCost(model, snap); ok {
+		m.costUSD += cost
+	}
+}
+
+// handle serves GET /metrics.
+func (m *metrics) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	b := currentBuild()
+	metric("gemini_transcribe_build_info", "gauge", "Build that is running.")
+	fmt.Fprintf(w, "gemini_transcribe_build_info{version=%q,commit=%q} 1\n", b.Version, b.Commit)
+
+	metric("gemini_transcribe_transcriptions_total", "counter", "Transcriptions by source and status: ok, no_speech or the error class (quota, auth, network...).")
+	keys := make([][2]string, 0, len(m.transcribed))
+	for k := range m.transcribed {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b [2]string) int { return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1]) })
+	for _, k := range keys {
+		fmt.Fprintf(w, "gemini_transcribe_transcriptions_total{source=%q,status=%q} %d\n", k[0], k[1], m.transcribed[k])
+	}
+
+	metric("gemini_transcribe_transcription_duration_seconds", "histogram", "Time taken per transcription, by source.")
+	sources := make([]string, 0, len(m.latency))
+	for source := range m.latency {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+	for _, source := range sources {
+		h := m.latency[source]
+		var cumulative int64
+		for i, le := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "gemini_transcribe_transcription_duration_seconds_bucket{source=%q,le=%q} %d\n", source, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "gemini_transcribe_transcription_duration_seconds_bucket{source=%q,le=\"+Inf\"} %d\n", source, h.count)
+		fmt.Fprintf(w, "gemini_transcribe_transcription_duration_seconds_sum{source=%q} %g\n", source, h.sum)
+		fmt.Fprintf(w, "gemini_transcribe_transcription_duration_seconds_count{source=%q} %d\n", source, h.count)
+	}
+
+	metric("gemini_transcribe_audio_seconds_total", "counter", "Seconds of audio transcribed.")
+	fmt.Fprintf(w, "gemini_transcribe_audio_seconds_total %g\n", m.audioSeconds)
+	metric("gemini_transcribe_api_requests_total", "counter", "Requests sent to the Gemini API, retries included.")
+	fmt.Fprintf(w, "gemini_transcribe_api_requests_total %d\n", m.usage.Requests)
+	metric("gemini_transcribe_api_retries_total", "counter", "Gemini API requests repeated after a failure.")
+	fmt.Fprintf(w, "gemini_transcribe_api_retries_total %d\n", m.usage.Retries)
+	metric("gemini_transcribe_tokens_total", "counter", "Tokens used, by type; output includes thinking tokens.")
+	fmt.Fprintf(w, "gemini_transcribe_tokens_total{type=\"prompt\"} %d\n", m.usage.PromptTokens)
+	fmt.Fprintf(w, "gemini_transcribe_tokens_total{type=\"output\"} %d\n", m.usage.OutputTokens)
+	metric("gemini_transcribe_estimated_cost_usd_total", "counter", "Estimated cost at list prices, in US dollars.")
+	fmt.Fprintf(w, "gemini_transcribe_estimated_cost_usd_total %g\n", m.costUSD)
+
+	if m.queueDepth != nil {
+		metric("gemini_transcribe_queue_depth", "gauge", "Queued jobs waiting for a worker.")
+		fmt.Fprintf(w, "gemini_transcribe_queue_depth %d\n", m.queueDepth())
+	}
+}
+
+// serveMetrics serves /metrics on addr, for commands without an HTTP
+// server of their own.
+func serveMetrics(addr string, m *metrics) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", m.handle)
+	infof("Serving metrics on %s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		errorf("Error: metrics server: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// defaultMicSegment is how much audio --mic records before sending it.
+const defaultMicSegment = 30 * time.Second
+
+// micInputArgs returns the ffmpeg input arguments for the platform's audio
+// capture device. device overrides the default input.
+func micInputArgs(device string) ([]string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if device == "" {
+			device = "default"
+		}
+		return []string{"-f", "avfoundation", "-i", ":" + device}, nil
+	case "windows":
+		if device == "" {
+			return nil, errors.New("--mic needs --mic-device on Windows (see ffmpeg -list_devices true -f dshow -i dummy)")
+		}
+		return []string{"-f", "dshow", "-i", "audio=" + device}, nil
+	default:
+		if device == "" {
+			device = "default"
+		}
+		return []string{"-f", "alsa", "-i", device}, nil
+	}
+}
+
+// runMic records from the microphone in rolling segments and prints each
+// segment's transcript as soon as it is ready, until interrupted.
+func runMic(client *transcribe.Client, opts options, device string, segment time.Duration) error {
+	if _, err := exec.LookPath(ffmpegBinary(client)); err != nil {
+		return errors.New("--mic requires ffmpeg")
+	}
+	inputArgs, err := micInputArgs(device)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "gemini-transcribe-mic-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, inputArgs...)
+	args = append(args,
+		"-ac", "1", "-ar", "16000", "-c:a", "libmp3lame", "-b:a", "64k",
+		"-f", "segment", "-segment_time", fmt.Sprintf("%.3f", segment.Seconds()),
+		"-reset_timestamps", "1",
+		filepath.Join(dir, "seg%05d.mp3"))
+	cmd := exec.CommandContext(ctx, ffmpegBinary(client), args...)
+	// Let ffmpeg finish the segment it is writing instead of killing it
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting ffmpeg: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	infof("Listening (%s segments), press Ctrl-C to stop...", segment)
+
+	err = followSegments(dir, done, func(n int, path string) {
+		transcribeMicSegment(client, path, time.Duration(n)*segment, opts)
+	})
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("recording failed: %v", err)
+	}
+	return nil
+}
+
+// followSegments calls handle, in order, for each file ffmpeg's segment
+// muxer writes to dir as seg%05d.mp3, once it is complete: when ffmpeg has
+// moved on to the next one, or has exited. done delivers ffmpeg's exit,
+// which is returned after the last segment.
+func followSegments(dir string, done <-chan error, handle func(n int, path string)) error {
+	segPath := func(n int) string { return filepath.Join(dir, fmt.Sprintf("seg%05d.mp3", n)) }
+	exists := func(n int) bool {
+		_, err := os.Stat(segPath(n))
+		return err == nil
+	}
+
+	var waitErr error
+	next, recording := 0, true
+	for {
+		if recording {
+			select {
+			case waitErr = <-done:
+				recording = false
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+		for exists(next) && (!recording || exists(next+1)) {
+			handle(next, segPath(next))
+			os.Remove(segPath(next))
+			next++
+		}
+		if !recording {
+			return waitErr
+		}
+	}
+}
+
+// transcribeMicSegment transcribes one recorded segment and prints it with
+// its offset from the start of the recording.
+func transcribeMicSegment(client *transcribe.Client, path string, offset time.Duration, opts options) {
+	// Not the interrupted context: the last segment still gets transcribed
+	res, err := client.TranscribeFile(context.Background(), path, opts.Options)
+	if errors.Is(err, transcribe.ErrNoSpeech) {
+		return
+	} else if err != nil {
+		errorf("Error at %s: %v", transcribe.FormatTimecode(offset.Seconds(), "."), err)
+		return
+	}
+	if text := strings.TrimSpace(res.Text); text != "" {
+		fmt.Printf("[%s] %s\n", transcribe.FormatTimecode(offset.Seconds(), "."), text)
+	}
+}
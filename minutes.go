@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// minutesJSON is the minutes subcommand's JSON output.
+type minutesJSON struct {
+	File  string `json:"file"`
+	Model string `json:"model"`
+	transcribe.Minutes
+	Transcription string `json:"transcription"`
+}
+
+// minutesFlags are the minutes command's flag values.
+type minutesFlags struct {
+	format     string
+	outputJSON bool
+	output     string
+	noCache    bool
+	opts       options
+	g          globalFlags
+}
+
+// flagSet returns the minutes command's flags, bound to v.
+func (v *minutesFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("minutes", flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Language, "language", "", "Spoken language of the audio, as a hint (e.g. German, pt)")
+	fs.StringVar(&v.format, "f", "markdown", "Output format: markdown, json")
+	fs.StringVar(&v.format, "format", "markdown", "Output format: markdown, json")
+	fs.BoolVar(&v.outputJSON, "json", false, "Output as JSON (same as --format json)")
+	fs.StringVar(&v.output, "o", "", "Write the minutes to this file instead of stdout")
+	fs.StringVar(&v.output, "output", "", "Write the minutes to this file instead of stdout")
+	fs.BoolVar(&v.noCache, "no-cache", false, "Always call the API instead of reusing a cached transcript of the same audio")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe minutes [options] <file>\n\n")
+		fmt.Fprintf(os.Stderr, "Transcribes a meeting recording and extracts a summary, decisions, action items\n")
+		fmt.Fprintf(os.Stderr, "and open questions. A .txt or .md file is taken as an existing transcript.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runMinutes implements the minutes subcommand.
+func runMinutes(args []string) {
+	var v minutesFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	opts, g := v.opts, v.g
+	g.initLogging(false)
+	opts.verbose = g.verbose
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	input := fs.Arg(0)
+	if v.outputJSON {
+		v.format = "json"
+	}
+	if v.format != "markdown" && v.format != "json" {
+		errorf("Error: Unknown format %q (want markdown or json)", v.format)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(input); err != nil && !isURL(input) && !isObject(input) {
+		errorf("Error: File not found: %s", input)
+		os.Exit(exitNotFound)
+	}
+
+	opts.Upload = "auto"
+	opts.ChunkDuration = transcribe.DefaultChunkDuration
+	opts.ChunkOverlap = transcribe.DefaultChunkOverlap
+	opts.MaxContinuations = transcribe.DefaultMaxContinuations
+
+	client := g.newClient()
+	if !v.noCache {
+		client.Cache = newCache()
+	}
+	if opts.verbose {
+		client.Logf = debugf
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var transcript string
+	switch strings.ToLower(filepath.Ext(input)) {
+	case ".txt", ".md":
+		data, err := os.ReadFile(input)
+		if err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+		transcript = string(data)
+	default:
+		result, err := transcribeFile(ctx, client, input, opts)
+		if err != nil {
+			_, code := errorClass(err)
+			errorf("Error %v", err)
+			os.Exit(code)
+		}
+		transcript = result.Transcription
+	}
+
+	debugf("Extracting minutes...")
+	minutes, err := client.Minutes(ctx, transcript, opts.Options)
+	if err != nil {
+		_, code := errorClass(err)
+		errorf("Error %v", err)
+		os.Exit(code)
+	}
+	debugf("%s", client.Stats.Snapshot().Summary())
+
+	var out string
+	if v.format == "json" {
+		data, _ := json.MarshalIndent(minutesJSON{
+			File:          input,
+			Model:         opts.Model,
+			Minutes:       minutes,
+			Transcription: transcript,
+		}, "", "  ")
+		out = string(data) + "\n"
+	} else {
+		out = renderMinutes(input, minutes)
+	}
+
+	if v.output == "" {
+		fmt.Print(out)
+		return
+	}
+	if err := writeOutput(v.output, []byte(out)); err != nil {
+		errorf("Error writing output: %v", err)
+		os.Exit(1)
+	}
+}
+
+// renderMinutes formats minutes as a Markdown document titled after the
+// input.
+func renderMinutes(input string, m transcribe.Minutes) string {
+	var b strings.Builder
+	name := filepath.Base(input)
+	if isURL(input) {
+		name = urlBaseName(input)
+	}
+	fmt.Fprintf(&b, "# Minutes: %s\n\n", strings.TrimSuffix(name, filepath.Ext(name)))
+
+	if m.Summary != "" {
+		fmt.Fprintf(&b, "## Summary\n\n%s\n\n", m.Summary)
+	}
+
+	b.WriteString("## Decisions\n\n")
+	writeList(&b, m.Decisions)
+
+	b.WriteString("## Action Items\n\n")
+	if len(m.ActionItems) == 0 {
+		b.WriteString("None.\n\n")
+	}
+	for _, item := range m.ActionItems {
+		fmt.Fprintf(&b, "- [ ] %s", item.Task)
+		if item.Owner != "" {
+			fmt.Fprintf(&b, " — **%s**", item.Owner)
+		}
+		if item.Due != "" {
+			fmt.Fprintf(&b, " (due %s)", item.Due)
+		}
+		b.WriteString("\n")
+	}
+	if len(m.ActionItems) > 0 {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Open Questions\n\n")
+	writeList(&b, m.OpenQuestions)
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// writeList writes items as a Markdown bullet list, or "None." when empty.
+func writeList(b *strings.Builder, items []string) {
+	if len(items) == 0 {
+		b.WriteString("None.\n\n")
+		return
+	}
+	for _, item := range items {
+		fmt.Fprintf(b, "- %s\n", item)
+	}
+	b.WriteString("\n")
+}
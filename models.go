@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// modelsFlags are the models command's flag values.
+type modelsFlags struct {
+	all        bool
+	outputJSON bool
+	g          globalFlags
+}
+
+// flagSet returns the models command's flags, bound to v.
+func (v *modelsFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("models", flag.ExitOnError)
+	v.g.register(fs)
+	fs.BoolVar(&v.all, "all", false, "List every model, not just those that can transcribe audio")
+	fs.BoolVar(&v.outputJSON, "json", false, "Output as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe models [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Lists the models available to your API key that accept audio, for use with -m.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runModels implements the models subcommand.
+func runModels(args []string) {
+	var v modelsFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	g := v.g
+	g.initLogging(false)
+
+	client := g.newClient()
+
+	models, err := client.ListModels(context.Background())
+	if err != nil {
+		errorf("Error listing models: %v", err)
+		os.Exit(1)
+	}
+	if !v.all {
+		var audio []transcribe.Model
+		for _, m := range models {
+			if m.AcceptsAudio() {
+				audio = append(audio, m)
+			}
+		}
+		models = audio
+	}
+
+	if v.outputJSON {
+		out, _ := json.MarshalIndent(models, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tINPUT TOKENS\tOUTPUT TOKENS\tNAME")
+	for _, m := range models {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", m.ID(), m.InputTokenLimit, m.OutputTokenLimit, m.DisplayName)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// whisperVerboseJSON mirrors OpenAI's verbose_json transcription response.
+type whisperVerboseJSON struct {
+	Task     string           `json:"task"`
+	Language string           `json:"language"`
+	Duration float64          `json:"duration"`
+	Text     string           `json:"text"`
+	Segments []whisperSegment `json:"segments"`
+}
+
+type whisperSegment struct {
+	ID               int     `json:"id"`
+	Seek             int     `json:"seek"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Tokens           []int   `json:"tokens"`
+	Temperature      float64 `json:"temperature"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+
+	// Words are only given in whisper-json output, as whisper
+	// --word_timestamps does.
+	Words []whisperWord `json:"words,omitempty"`
+}
+
+type whisperWord struct {
+	Word        string  `json:"word"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Probability float64 `json:"probability"`
+}
+
+// whisperJSON is the file whisper --output_format json writes.
+type whisperJSON struct {
+	Text     string           `json:"text"`
+	Segments []whisperSegment `json:"segments"`
+	Language string           `json:"language"`
+}
+
+// handleOpenAITranscription implements POST /v1/audio/transcriptions with the
+// request and response shapes of OpenAI's Whisper API, so existing clients
+// can point their base URL at this server.
+func (s *server) handleOpenAITranscription(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "file", fmt.Sprintf("missing audio in multipart field \"file\": %v", err))
+		return
+	}
+	defer file.Close()
+
+	opts := s.defaults
+	// Whisper clients send "whisper-1"; only honour Gemini model names.
+	if v := r.FormValue("model"); strings.HasPrefix(v, "gemini") {
+		opts.Model = v
+	}
+	// Whisper's prompt is context (spellings, previous text), not an instruction.
+	if v := r.FormValue("prompt"); v != "" {
+		opts.Prompt += "\n\nUse this context for spelling and style: " + v
+	}
+	opts.Language = r.FormValue("language")
+
+	if v := r.FormValue("temperature"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > 2 {
+			writeOpenAIError(w, http.StatusBadRequest, "temperature", fmt.Sprintf("invalid temperature %q", v))
+			return
+		}
+		cfg := transcribe.GenerationConfig{}
+		if opts.GenerationConfig != nil {
+			cfg = *opts.GenerationConfig
+		}
+		cfg.Temperature = &t
+		opts.GenerationConfig = &cfg
+	}
+
+	format := r.FormValue("response_format")
+	if format == "" {
+		format = "json"
+	}
+	switch format {
+	case "json", "text":
+	case "srt", "vtt":
+		opts.Timestamps = true
+	case "verbose_json":
+		opts.Timestamps = true
+		opts.DetectLanguage = opts.Language == ""
+	default:
+		writeOpenAIError(w, http.StatusBadRequest, "response_format", fmt.Sprintf("unsupported response_format %q", format))
+		return
+	}
+
+	tmpPath, err := saveUpload("", file, header.Filename)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	defer os.Remove(tmpPath)
+
+	result, _, err := s.transcribeUpload(r.Context(), "openai", tmpPath, header.Filename, opts)
+	if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+		errorf("POST /v1/audio/transcriptions %s: %v", header.Filename, err)
+		writeOpenAIError(w, http.StatusBadGateway, "", err.Error())
+		return
+	}
+	infof("POST /v1/audio/transcriptions %s (%d bytes) in %s", header.Filename, header.Size, time.Since(start).Round(time.Millisecond))
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"text": result.Transcription})
+	case "verbose_json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toWhisperVerbose(result, opts.Language))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, result.Transcription+"\n")
+	case "srt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, transcribe.FormatSRT(result.Segments))
+	case "vtt":
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		io.WriteString(w, transcribe.FormatVTT(result.Segments, ""))
+	}
+}
+
+// toWhisperVerbose converts a result into verbose_json. Gemini has no
+// token-level probabilities, so those fields are zero placeholders.
+func toWhisperVerbose(result jsonResult, language string) whisperVerboseJSON {
+	if language == "" {
+		language = result.Language
+	}
+	out := whisperVerboseJSON{
+		Task:     "transcribe",
+		Language: language,
+		Duration: result.Duration,
+		Text:     result.Transcription,
+		Segments: []whisperSegment{},
+	}
+	for i, seg := range result.Segments {
+		out.Segments = append(out.Segments, whisperSegment{
+			ID:     i,
+			Start:  seg.Start,
+			End:    seg.End,
+			Text:   " " + seg.Text,
+			Tokens: []int{},
+		})
+		out.Duration = max(out.Duration, seg.End)
+	}
+	return out
+}
+
+// toWhisperJSON converts a result into the schema of whisper's JSON output
+// (--format whisper-json), so scripts written for it work unchanged. As in
+// verbose_json the probability fields are placeholders; word probabilities
+// are 1 and the rest 0.
+func toWhisperJSON(result jsonResult) whisperJSON {
+	out := whisperJSON{
+		Text:     result.Transcription,
+		Segments: toWhisperVerbose(result, "").Segments,
+		Language: result.Language,
+	}
+	for i, seg := range result.Segments {
+		for _, w := range seg.Words {
+			out.Segments[i].Words = append(out.Segments[i].Words, whisperWord{
+				Word:        " " + w.Word,
+				Start:       w.Start,
+				End:         w.End,
+				Probability: 1,
+			})
+		}
+	}
+	return out
+}
+
+// writeOpenAIError writes an error in OpenAI's {"error": {...}} shape.
+func writeOpenAIError(w http.ResponseWriter, status int, param, msg string) {
+	errType := "invalid_request_error"
+	if status >= 500 {
+		errType = "server_error"
+	}
+	body := map[string]any{
+		"message": msg,
+		"type":    errType,
+		"param":   nil,
+		"code":    nil,
+	}
+	if param != "" {
+		body["param"] = param
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": body})
+}
@@ -0,0 +1,347 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+)
+
+// TranscribeFile runs the whole pipeline for one file: silence check,
+// conversion (chunked for long recordings), the API call and segment
+// parsing. It returns ErrNoSpeech, with an empty Result, for silent input,
+// and notes near-silent input in Result.Suspicions.
+func (c *Client) TranscribeFile(ctx context.Context, inputFile string, opts Options) (Result, error) {
+	if err := checkChunking(opts); err != nil {
+		return Result{Model: opts.model()}, err
+	}
+	// Skip the API call entirely on silent input
+	peak, mean, measured := c.levels(ctx, inputFile, opts)
+	if measured && peak <= SilenceThresholdDB {
+		return Result{Model: opts.model()}, ErrNoSpeech
+	}
+
+	result, opts, err := c.withFallback(ctx, opts, func(opts Options) (Result, error) {
+		return c.transcribeInput(ctx, inputFile, opts)
+	})
+	if err != nil {
+		return result, err
+	}
+	if measured && mean <= QuietThresholdDB && result.Text != "" {
+		result.Suspicions = append(result.Suspicions, fmt.Sprintf("the audio is near silent (mean level %.0f dB)", mean))
+	}
+	return c.refine(ctx, result, opts)
+}
+
+// transcribeInput converts and transcribes a file, or the opts.Start to
+// opts.End slice of it, in chunks when that is longer than
+// opts.ChunkDuration.
+func (c *Client) transcribeInput(ctx context.Context, inputFile string, opts Options) (Result, error) {
+	probeStart := time.Now()
+	duration, durErr := c.probeDuration(ctx, inputFile)
+	if durErr == nil {
+		c.logf("Probed in %s\n", since(probeStart))
+	}
+	whole := chunkSpan{Start: opts.Start.Seconds(), End: duration}
+	if opts.End > 0 && (durErr != nil || opts.End.Seconds() < duration) {
+		whole.End = opts.End.Seconds()
+	}
+	ranged := opts.Start > 0 || opts.End > 0
+	if ranged && durErr == nil && whole.Start >= duration {
+		return Result{Model: opts.model()}, fmt.Errorf("start %s is past the end of the input (%s)",
+			FormatTimecode(whole.Start, "."), FormatTimecode(duration, "."))
+	}
+
+	known := durErr == nil || opts.End > 0
+	if known {
+		perRequest := whole.End - whole.Start
+		if opts.ChunkDuration > 0 {
+			perRequest = min(perRequest, opts.ChunkDuration.Seconds())
+		}
+		if err := c.checkLength(whole.End-whole.Start, perRequest, opts); err != nil {
+			return Result{Model: opts.model()}, err
+		}
+	}
+	if opts.ChunkDuration > 0 && known && whole.End-whole.Start > opts.ChunkDuration.Seconds() {
+		return c.transcribeChunked(ctx, inputFile, whole, opts)
+	}
+
+	if ranged {
+		return c.transcribeRange(ctx, inputFile, whole, known, opts)
+	}
+
+	if durErr == nil {
+		opts.duration = duration
+	}
+	// Convert to audio if needed
+	audioData, mimeType, err := c.prepareAudio(ctx, inputFile, opts)
+	if err != nil {
+		return Result{Model: opts.model()}, fmt.Errorf("preparing audio: %w", err)
+	}
+	result, err := c.transcribeData(ctx, audioData, mimeType, opts)
+	if durErr == nil {
+		result.Duration = duration
+	}
+	return result, err
+}
+
+// transcribeRange extracts a slice of the input with ffmpeg and transcribes
+// it, shifting timed segments back onto the input's timeline. When the end
+// isn't known the slice runs to the end of the input.
+func (c *Client) transcribeRange(ctx context.Context, inputFile string, span chunkSpan, bounded bool, opts Options) (Result, error) {
+	if _, err := exec.LookPath(c.ffmpeg()); err != nil {
+		return Result{Model: opts.model()}, errors.New("preparing audio: transcribing a time range needs ffmpeg")
+	}
+	args := []string{"-ss", strconv.FormatFloat(span.Start, 'f', 3, 64)}
+	if bounded {
+		args = append(args, "-t", strconv.FormatFloat(span.End-span.Start, 'f', 3, 64))
+		opts.duration = span.End - span.Start
+	}
+	c.logf("Extracting %s-%s with ffmpeg...\n", FormatTimecode(span.Start, "."), FormatTimecode(span.End, "."))
+	data, mimeType, err := c.convertAudio(ctx, inputFile, opts, args...)
+	if err != nil {
+		return Result{Model: opts.model()}, fmt.Errorf("preparing audio: %w", err)
+	}
+	result, err := c.transcribeData(ctx, data, mimeType, opts)
+	if err != nil {
+		return result, err
+	}
+	for i, seg := range result.Segments {
+		result.Segments[i] = shiftSegment(seg, span.Start)
+	}
+	if bounded {
+		result.Duration = span.End - span.Start
+	}
+	return result, nil
+}
+
+// prepareAudio reads a file Gemini accepts as is, or converts it to mp3
+// with ffmpeg. The MIME type is sniffed from the file's contents, so
+// renamed and extensionless files are sent with the right type.
+func (c *Client) prepareAudio(ctx context.Context, inputFile string, opts Options) ([]byte, string, error) {
+	mimeType := detectMimeType(inputFile, strings.ToLower(filepath.Ext(inputFile)))
+	filters, err := audioFilters(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	// Options that change the audio itself, rather than the codec
+	changed := len(opts.FFmpegArgs) > 0 || len(opts.FFmpegInputArgs) > 0 || filters != "" || opts.AudioTrack > 0
+	custom := changed || (opts.ConvertTo != "" && opts.ConvertTo != "mp3")
+
+	// Check if ffmpeg is available
+	if _, err := exec.LookPath(c.ffmpeg()); err != nil {
+		return c.prepareWithoutFFmpeg(inputFile, mimeType, changed, opts)
+	}
+
+	// If already a good audio format and small enough, use directly
+	// (unless the conversion was customised)
+	if geminiAudio[mimeType] && !custom {
+		info, err := os.Stat(inputFile)
+		if err == nil && info.Size() < 20*1024*1024 { // Under 20MB
+			data, err := os.ReadFile(inputFile)
+			if err != nil {
+				return nil, "", err
+			}
+			return data, mimeType, nil
+		}
+	}
+
+	// Convert using ffmpeg
+	c.logf("Converting with ffmpeg...\n")
+	return c.convertAudio(ctx, inputFile, opts)
+}
+
+// geminiAudio are the audio types Gemini accepts as they are.
+var geminiAudio = map[string]bool{
+	"audio/mpeg": true, "audio/wav": true, "audio/ogg": true,
+	"audio/flac": true, "audio/mp4": true, "audio/aac": true,
+}
+
+// prepareWithoutFFmpeg reads a file when ffmpeg isn't installed. WAV is
+// resampled to 16 kHz mono in Go, which can also pick a channel. MP3,
+// FLAC, OGG and the other audio in geminiAudio are compressed already and
+// sent as they are with their MIME type, whatever codec opts.ConvertTo
+// asks for; options that change the audio (changed) fail for them. Video
+// fails rather than being uploaded whole, as does anything else Gemini
+// can't take.
+func (c *Client) prepareWithoutFFmpeg(inputFile, mimeType string, changed bool, opts Options) ([]byte, string, error) {
+	channel, _ := channelIndex(opts.Channel)
+	if mimeType == "audio/wav" && !needsFFmpeg(opts) {
+		f, err := os.Open(inputFile)
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		c.logf("ffmpeg not found, resampling WAV to 16 kHz mono...\n")
+		data, err := resampleWAV(f, channel)
+		if err == nil {
+			return data, "audio/wav", nil
+		}
+		if channel >= 0 {
+			return nil, "", err
+		}
+		c.logf("Can't resample (%v), sending as is\n", err)
+	} else if changed {
+		return nil, "", errors.New("ffmpeg arguments, track and channel selection, normalization and denoising need ffmpeg")
+	}
+	if strings.HasPrefix(mimeType, "video/") {
+		return nil, "", fmt.Errorf("%s is a video, and extracting its audio needs ffmpeg", filepath.Base(inputFile))
+	}
+	if !geminiAudio[mimeType] {
+		return nil, "", fmt.Errorf("%s isn't in a format Gemini accepts as it is, and converting it needs ffmpeg", filepath.Base(inputFile))
+	}
+
+	c.logf("ffmpeg not found, sending the %s file as it is...\n", mimeType)
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, mimeType, nil
+}
+
+// needsFFmpeg reports whether opts customise the conversion beyond what
+// resampleWAV can do without ffmpeg. The codec isn't one of them: without
+// ffmpeg, WAV is what there is.
+func needsFFmpeg(opts Options) bool {
+	return len(opts.FFmpegArgs) > 0 || len(opts.FFmpegInputArgs) > 0 || opts.AudioTrack > 0 ||
+		opts.Normalize || opts.Denoise || opts.DenoiseModel != ""
+}
+
+// Conversions are the output options for each Options.ConvertTo codec,
+// all tuned for speech: mono at 16 kHz, the rate Gemini works at.
+var Conversions = map[string][]string{
+	// mp3 at 64 kbps, the default
+	"mp3": {"-vn", "-acodec", "libmp3lame", "-ar", "16000", "-ac", "1", "-b:a", "64k", "-f", "mp3"},
+	// Opus at 24 kbps, under half the size of the mp3
+	"opus": {"-vn", "-acodec", "libopus", "-ar", "16000", "-ac", "1", "-b:a", "24k", "-application", "voip", "-f", "ogg"},
+	// Lossless, for difficult audio
+	"flac": {"-vn", "-acodec", "flac", "-ar", "16000", "-ac", "1", "-f", "flac"},
+	"wav":  {"-vn", "-acodec", "pcm_s16le", "-ar", "16000", "-ac", "1", "-f", "wav"},
+}
+
+// ffmpegFormats maps the ffmpeg output formats that can be sent to Gemini
+// to their MIME types.
+var ffmpegFormats = map[string]string{
+	"mp3":  "audio/mpeg",
+	"wav":  "audio/wav",
+	"ogg":  "audio/ogg",
+	"flac": "audio/flac",
+	"adts": "audio/aac",
+	"ipod": "audio/mp4",
+}
+
+// convertAudio runs inputFile through ffmpeg with opts.FFmpegArgs (or the
+// options for opts.ConvertTo) plus any filters opts asks for, and returns
+// the audio, read straight from ffmpeg's stdout, with its MIME type.
+// inputArgs are placed before -i after opts.FFmpegInputArgs, e.g. "-ss",
+// "60", "-t", "30" to extract a slice.
+func (c *Client) convertAudio(ctx context.Context, inputFile string, opts Options, inputArgs ...string) ([]byte, string, error) {
+	outputArgs := opts.FFmpegArgs
+	if len(outputArgs) == 0 {
+		codec := opts.ConvertTo
+		if codec == "" {
+			codec = "mp3"
+		}
+		var ok bool
+		if outputArgs, ok = Conversions[codec]; !ok {
+			return nil, "", fmt.Errorf("unknown conversion codec %q (want mp3, opus, flac or wav)", codec)
+		}
+	}
+	filters, err := audioFilters(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if filters != "" {
+		outputArgs = withAudioFilter(outputArgs, filters)
+	}
+	format := "mp3"
+	for i, arg := range outputArgs {
+		if arg == "-f" && i+1 < len(outputArgs) {
+			format = outputArgs[i+1]
+		}
+	}
+	mimeType, ok := ffmpegFormats[format]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported ffmpeg output format %q (want mp3, wav, ogg, flac, adts or ipod)", format)
+	}
+
+	var args []string
+	if c.OnProgress != nil {
+		args = append(args, "-progress", "pipe:2", "-nostats")
+	}
+	args = append(args, opts.FFmpegInputArgs...)
+	args = append(args, inputArgs...)
+	args = append(args, "-i", inputFile)
+	args = append(args, opts.trackArgs()...)
+	args = append(args, outputArgs...)
+	if !slices.Contains(outputArgs, "-f") {
+		args = append(args, "-f", format)
+	}
+	args = append(args, "pipe:1")
+	cmd := proc.Command(ctx, c.ffmpeg(), args...)
+
+	var stdout bytes.Buffer
+	stderr := &ffmpegProgress{c: c, total: opts.duration}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		return nil, "", &FFmpegError{Err: err, Stderr: stderr.String()}
+	}
+	if total := max(opts.duration, stderr.done); total > 0 {
+		c.progress("convert", total, total)
+	}
+	c.logf("Converted in %s (%s of %s)\n", since(start), byteSize(stdout.Len()), format)
+	return stdout.Bytes(), mimeType, nil
+}
+
+// ffmpeg returns the ffmpeg binary to run.
+func (c *Client) ffmpeg() string {
+	if c.FFmpegPath != "" {
+		return c.FFmpegPath
+	}
+	return "ffmpeg"
+}
+
+// ffprobe returns the ffprobe binary to run.
+func (c *Client) ffprobe() string {
+	if c.FFprobePath != "" {
+		return c.FFprobePath
+	}
+	return "ffprobe"
+}
+
+// MimeType maps a file extension (with the dot, lower case) to the MIME type
+// sent to Gemini.
+func MimeType(ext string) string {
+	mimeTypes := map[string]string{
+		".mp3":  "audio/mpeg",
+		".wav":  "audio/wav",
+		".ogg":  "audio/ogg",
+		".flac": "audio/flac",
+		".m4a":  "audio/mp4",
+		".aac":  "audio/aac",
+		".mp4":  "video/mp4",
+		".webm": "video/webm",
+		".mov":  "video/quicktime",
+		".avi":  "video/x-msvideo",
+		".mkv":  "video/x-matroska",
+	}
+	if mime, ok := mimeTypes[ext]; ok {
+		return mime
+	}
+	return "application/octet-stream"
+}
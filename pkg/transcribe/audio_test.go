@@ -0,0 +1,56 @@
+package transcribe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareWithoutFFmpeg(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	mp3 := write("talk.mp3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00frames"))
+	flac := write("talk.flac", []byte("fLaC\x00\x00\x00\x22"))
+	wav := write("talk.wav", sineWAV(48000, 2, 440, 0.1))
+	odd := write("talk.wma", []byte("\x30\x26\xb2\x75 not sniffable"))
+	video := write("talk.mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2"))
+
+	tests := []struct {
+		name     string
+		path     string
+		changed  bool
+		opts     Options
+		wantMime string
+		wantErr  bool
+	}{
+		{"mp3 as is", mp3, false, Options{}, "audio/mpeg", false},
+		{"mp3 whatever the codec", mp3, false, Options{ConvertTo: "opus"}, "audio/mpeg", false},
+		{"flac as is", flac, false, Options{}, "audio/flac", false},
+		{"mp3 filters need ffmpeg", mp3, true, Options{}, "", true},
+		{"wav resampled", wav, false, Options{ConvertTo: "opus"}, "audio/wav", false},
+		{"wav channel", wav, true, Options{Channel: "right"}, "audio/wav", false},
+		{"unknown format", odd, false, Options{}, "", true},
+		{"video needs ffmpeg", video, false, Options{}, "", true},
+	}
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, mime, err := c.prepareWithoutFFmpeg(tt.path, detectMimeType(tt.path, filepath.Ext(tt.path)), tt.changed, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %s, want an error", mime)
+				}
+				return
+			}
+			if err != nil || mime != tt.wantMime || len(data) == 0 {
+				t.Errorf("got %d bytes of %s, %v; want %s", len(data), mime, err, tt.wantMime)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package transcribe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Cache stores model responses on disk, keyed by a hash of the audio sent
+// and everything else that shapes the response: model, full prompt,
+// generation and safety settings. A nil *Cache caches nothing.
+type Cache struct {
+	Dir string
+}
+
+// DefaultCacheDir returns the per-user cache directory,
+// ~/.cache/gemini-transcribe on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gemini-transcribe"), nil
+}
+
+// cacheKey hashes one request's audio and settings.
+func cacheKey(audioData []byte, mimeType string, opts Options) string {
+	h := sha256.New()
+	gen, _ := json.Marshal(opts.GenerationConfig)
+	safety, _ := json.Marshal(opts.SafetySettings)
+	for _, s := range []string{opts.model(), opts.prompt(), opts.SystemInstruction, string(gen), string(safety), mimeType} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	h.Write(audioData)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the cached response for key, if any.
+func (c *Cache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(c.Dir, key+".txt"))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// put stores a response. Failures only cost a future cache miss, so they
+// are ignored.
+func (c *Cache) put(key, text string) {
+	if c == nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return
+	}
+	// Write then rename so a concurrent reader never sees a partial file
+	tmp, err := os.CreateTemp(c.Dir, key+"-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.WriteString(text)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.Dir, key+".txt"))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
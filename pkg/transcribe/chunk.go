@@ -0,0 +1,271 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+)
+
+const (
+	DefaultChunkDuration = 15 * time.Minute
+	DefaultChunkOverlap  = 10 * time.Second
+
+	// MaxAudioDuration is the longest audio Gemini accepts in one prompt.
+	MaxAudioDuration = 9*time.Hour + 30*time.Minute
+
+	// audioTokensPerSecond is the rate Gemini bills audio input at.
+	audioTokensPerSecond = 32
+)
+
+// chunkSpan is one slice of the input, in seconds.
+type chunkSpan struct {
+	Start float64
+	End   float64
+}
+
+// probeDuration returns the media duration in seconds using ffprobe.
+func (c *Client) probeDuration(ctx context.Context, inputFile string) (float64, error) {
+	cmd := proc.Command(ctx, c.ffprobe(),
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		inputFile,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %v", err)
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe returned no duration")
+	}
+	return d, nil
+}
+
+// checkLength logs the length and estimated token cost of the audio, and
+// refuses it with a TooLongError when a single request (perRequest seconds)
+// would exceed MaxAudioDuration, unless opts.Force is set.
+func (c *Client) checkLength(total, perRequest float64, opts Options) error {
+	c.logf("Audio length: %s, about %d input tokens\n", seconds(total), int(total*audioTokensPerSecond))
+	if perRequest <= MaxAudioDuration.Seconds() {
+		return nil
+	}
+	err := &TooLongError{
+		Duration: seconds(perRequest),
+		Limit:    MaxAudioDuration,
+		Tokens:   int(perRequest * audioTokensPerSecond),
+	}
+	if !opts.Force {
+		return err
+	}
+	c.logf("Warning: %v; sending anyway\n", err)
+	return nil
+}
+
+// seconds converts a length in seconds to a Duration rounded to the second.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+// checkChunking rejects chunk settings the input can't be split with: an
+// overlap as long as the chunk would never get past the first one.
+func checkChunking(opts Options) error {
+	if opts.ChunkDuration < 0 || opts.ChunkOverlap < 0 {
+		return fmt.Errorf("chunk duration (%s) and overlap (%s) must not be negative", opts.ChunkDuration, opts.ChunkOverlap)
+	}
+	if opts.ChunkDuration > 0 && opts.ChunkOverlap >= opts.ChunkDuration {
+		return fmt.Errorf("chunk overlap (%s) must be shorter than the chunk duration (%s)", opts.ChunkOverlap, opts.ChunkDuration)
+	}
+	return nil
+}
+
+// planChunks covers whole with spans of length chunk, each starting overlap
+// seconds before the previous one ends.
+func planChunks(whole chunkSpan, chunk, overlap float64) []chunkSpan {
+	var spans []chunkSpan
+	for start := whole.Start; ; start += chunk - overlap {
+		end := math.Min(start+chunk, whole.End)
+		spans = append(spans, chunkSpan{Start: start, End: end})
+		if end >= whole.End {
+			return spans
+		}
+	}
+}
+
+// transcribeChunked transcribes a long recording chunk by chunk and stitches
+// the pieces together. Timed output is shifted onto the original timeline and
+// each chunk keeps only the segments starting in its half of the overlaps;
+// plain text is joined after dropping the words repeated across the overlap.
+func (c *Client) transcribeChunked(ctx context.Context, inputFile string, whole chunkSpan, opts Options) (Result, error) {
+	duration := whole.End - whole.Start
+	result := Result{Model: opts.model(), Duration: duration}
+	if opts.ChunkDuration <= 0 {
+		return result, fmt.Errorf("chunk duration %s must be positive", opts.ChunkDuration)
+	}
+	if err := checkChunking(opts); err != nil {
+		return result, err
+	}
+	spans := planChunks(whole, opts.ChunkDuration.Seconds(), opts.ChunkOverlap.Seconds())
+	half := opts.ChunkOverlap.Seconds() / 2
+
+	// Chunks aren't streamed; OnText gets each chunk's text once stitched
+	chunkOpts := opts
+	chunkOpts.OnText = nil
+
+	c.logf("Duration %.0fs exceeds %s, splitting into %d chunks...\n", duration, opts.ChunkDuration, len(spans))
+
+	for i, span := range spans {
+		c.logf("Chunk %d/%d (%s-%s)...\n", i+1, len(spans),
+			FormatTimecode(span.Start, "."), FormatTimecode(span.End, "."))
+
+		chunkOpts.duration = span.End - span.Start
+		data, mimeType, err := c.convertAudio(ctx, inputFile, chunkOpts,
+			"-ss", strconv.FormatFloat(span.Start, 'f', 3, 64),
+			"-t", strconv.FormatFloat(span.End-span.Start, 'f', 3, 64))
+		if err != nil {
+			return result, fmt.Errorf("preparing chunk %d: %w", i+1, err)
+		}
+		upload := c.shouldUpload(len(data), opts.Upload)
+		text, err := c.send(ctx, data, mimeType, upload, chunkOpts)
+		if errors.Is(err, ErrDryRun) {
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("transcribing chunk %d: %w", i+1, err)
+		}
+		if opts.Refine && text != "" {
+			if text, err = c.refineDraft(ctx, data, mimeType, upload, text, chunkOpts); err != nil {
+				return result, fmt.Errorf("refining chunk %d: %w", i+1, err)
+			}
+		}
+		if opts.DetectLanguage && !opts.structured() {
+			var lang string
+			lang, text = splitLanguage(text)
+			if result.Language == "" {
+				result.Language = lang
+			}
+		}
+
+		if !opts.timed() {
+			merged := mergeOverlap(result.Text, text)
+			if opts.OnText != nil {
+				opts.OnText(strings.TrimPrefix(merged, result.Text))
+			}
+			result.Text = merged
+			continue
+		}
+
+		start := time.Now()
+		segments, lang, err := parseTimed(text, opts)
+		if err != nil {
+			return result, fmt.Errorf("parsing chunk %d: %v", i+1, err)
+		}
+		c.logf("Parsed %d segments in %s\n", len(segments), since(start))
+		if result.Language == "" {
+			result.Language = lang
+		}
+		lo, hi := span.Start+half, span.End-half
+		if i == 0 {
+			lo = math.Inf(-1)
+		}
+		if i == len(spans)-1 {
+			hi = math.Inf(1)
+		}
+		for _, seg := range segments {
+			seg = shiftSegment(seg, span.Start)
+			if seg.Start >= lo && seg.Start < hi {
+				result.Segments = append(result.Segments, seg)
+			}
+		}
+	}
+
+	if opts.DryRun != nil {
+		return result, ErrDryRun
+	}
+	if opts.timed() {
+		result.Text = JoinSegments(result.Segments)
+	}
+	return result, nil
+}
+
+// shiftSegment moves a segment and its words by offset seconds.
+func shiftSegment(seg Segment, offset float64) Segment {
+	seg.Start += offset
+	seg.End += offset
+	if len(seg.Words) > 0 {
+		words := make([]Word, len(seg.Words))
+		for i, w := range seg.Words {
+			words[i] = Word{Word: w.Word, Start: w.Start + offset, End: w.End + offset}
+		}
+		seg.Words = words
+	}
+	return seg
+}
+
+// maxOverlapWords bounds how far mergeOverlap looks for repeated text; ten
+// seconds of speech rarely exceeds a few dozen words.
+const maxOverlapWords = 60
+
+var nonWordRe = regexp.MustCompile(`[^\p{L}\p{N}']+`)
+
+// mergeOverlap appends next to prev, dropping the longest run of words at the
+// start of next that repeats the end of prev. Words are compared ignoring
+// case and punctuation, and at least three must match to count as overlap.
+// The rest of next is kept as written, line breaks and all.
+func mergeOverlap(prev, next string) string {
+	next = strings.TrimSpace(next)
+	if prev == "" {
+		return next
+	}
+	if next == "" {
+		return prev
+	}
+
+	prevWords := strings.Fields(prev)
+	nextWords := strings.Fields(next)
+	norm := func(w string) string {
+		return strings.ToLower(nonWordRe.ReplaceAllString(w, ""))
+	}
+
+	limit := min(len(prevWords), len(nextWords), maxOverlapWords)
+	for k := limit; k >= 3; k-- {
+		match := true
+		for j := 0; j < k; j++ {
+			if norm(prevWords[len(prevWords)-k+j]) != norm(nextWords[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			next = afterWords(next, k)
+			break
+		}
+	}
+
+	if next == "" {
+		return prev
+	}
+	return prev + " " + next
+}
+
+// afterWords returns s after its first n words, without the space that
+// followed them.
+func afterWords(s string, n int) string {
+	for range n {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		s = s[end:]
+	}
+	return strings.TrimSpace(s)
+}
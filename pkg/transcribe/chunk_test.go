@@ -0,0 +1,73 @@
+package transcribe
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanChunks(t *testing.T) {
+	tests := []struct {
+		name           string
+		whole          chunkSpan
+		chunk, overlap float64
+		want           []chunkSpan
+	}{
+		{"shorter than a chunk", chunkSpan{0, 50}, 100, 10, []chunkSpan{{0, 50}}},
+		{"exactly one chunk", chunkSpan{0, 100}, 100, 10, []chunkSpan{{0, 100}}},
+		{"overlapping", chunkSpan{0, 250}, 100, 10, []chunkSpan{{0, 100}, {90, 190}, {180, 250}}},
+		{"no overlap", chunkSpan{0, 200}, 100, 0, []chunkSpan{{0, 100}, {100, 200}}},
+		{"offset start", chunkSpan{30, 200}, 100, 20, []chunkSpan{{30, 130}, {110, 200}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planChunks(tt.whole, tt.chunk, tt.overlap); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planChunks(%v, %v, %v) = %v, want %v", tt.whole, tt.chunk, tt.overlap, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckChunking(t *testing.T) {
+	tests := []struct {
+		duration, overlap time.Duration
+		ok                bool
+	}{
+		{15 * time.Minute, 10 * time.Second, true},
+		{15 * time.Minute, 0, true},
+		{0, 10 * time.Second, true}, // chunking disabled
+		{10 * time.Second, 10 * time.Second, false},
+		{10 * time.Second, time.Minute, false},
+		{-time.Minute, 0, false},
+		{time.Minute, -time.Second, false},
+	}
+	for _, tt := range tests {
+		err := checkChunking(Options{ChunkDuration: tt.duration, ChunkOverlap: tt.overlap})
+		if (err == nil) != tt.ok {
+			t.Errorf("checkChunking(%s, %s) = %v, want ok %v", tt.duration, tt.overlap, err, tt.ok)
+		}
+	}
+}
+
+func TestMergeOverlap(t *testing.T) {
+	tests := []struct {
+		name, prev, next, want string
+	}{
+		{"first chunk", "", " Hello there. ", "Hello there."},
+		{"empty next", "Hello there.", "", "Hello there."},
+		{"overlap dropped", "and then we went to the shop", "went to the shop and bought milk", "and then we went to the shop and bought milk"},
+		{"case and punctuation ignored", "we went to the shop.", "Went to the shop, and bought milk", "we went to the shop. and bought milk"},
+		{"too short to count", "we went home", "went home today", "we went home went home today"},
+		{"no overlap", "first part", "second part", "first part second part"},
+		{"line breaks kept", "so that was the plan", "that was the plan\n\nSpeaker 2: Right.\nOkay.", "so that was the plan Speaker 2: Right.\nOkay."},
+		{"line breaks kept without overlap", "one", "two\nthree", "one two\nthree"},
+		{"all overlap", "a b c d", "b c d", "a b c d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeOverlap(tt.prev, tt.next); got != tt.want {
+				t.Errorf("mergeOverlap(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,701 @@
+// Package transcribe transcribes audio and video with the Gemini API.
+//
+// A Client sends audio either inline or through the Files API, retries
+// transient failures and can parse timed segments out of the response:
+//
+//	c := transcribe.NewClient(os.Getenv("GEMINI_API_KEY"))
+//	res, err := c.Transcribe(ctx, f, transcribe.Options{MimeType: "audio/mpeg"})
+//
+// TranscribeFile adds the file pipeline used by the gemini-transcribe CLI:
+// silence detection, ffmpeg conversion and chunking of long recordings.
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultModel   = "gemini-2.5-flash"
+	DefaultBaseURL = "https://generativelanguage.googleapis.com"
+	DefaultPrompt  = "Transcribe this audio accurately. Output only the transcription, no extra commentary."
+	apiURLTemplate = "%s/v1beta/models/%s:%s?key=%s"
+)
+
+// DefaultMaxContinuations is how often the CLI continues a response cut off
+// at the output token limit.
+const DefaultMaxContinuations = 3
+
+// continuePrompt asks for the rest of a truncated response.
+const continuePrompt = "Your previous response was cut off. Continue it exactly where it stopped, without repeating anything already written and without any commentary."
+
+// ErrNoSpeech is returned by TranscribeFile when the input is silent and the
+// API call was skipped. The accompanying Result is empty but valid.
+var ErrNoSpeech = errors.New("no speech detected")
+
+type GeminiRequest struct {
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Contents          []Content         `json:"contents"`
+	SafetySettings    []SafetySetting   `json:"safetySettings,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GenerationConfig holds the sampling settings sent as generationConfig.
+// Unset fields are left to the model's defaults.
+type GenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+
+	// ResponseMIMEType set to "application/json" makes the model answer
+	// with JSON only, shaped by ResponseSchema when that is set.
+	ResponseMIMEType string  `json:"responseMimeType,omitempty"`
+	ResponseSchema   *Schema `json:"responseSchema,omitempty"`
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+type Part struct {
+	Text       string    `json:"text,omitempty"`
+	InlineData *BlobData `json:"inline_data,omitempty"`
+	FileData   *FileData `json:"file_data,omitempty"`
+}
+
+type BlobData struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+
+	// raw is the audio itself, encoded into Data as the request is sent
+	// (see requestBody).
+	raw []byte
+}
+
+type FileData struct {
+	MimeType string `json:"mime_type"`
+	FileURI  string `json:"file_uri"`
+}
+
+type GeminiResponse struct {
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
+	Error          *APIError       `json:"error,omitempty"`
+}
+
+// Candidate is one generated response. Thinking models can include their
+// reasoning as parts marked Thought, which aren't part of the answer.
+type Candidate struct {
+	Content struct {
+		Parts []struct {
+			Text    string `json:"text"`
+			Thought bool   `json:"thought,omitempty"`
+		} `json:"parts"`
+	} `json:"content"`
+	FinishReason  string         `json:"finishReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// text joins the answer parts; long responses can be split across several.
+func (cand Candidate) text() string {
+	var b strings.Builder
+	for _, p := range cand.Content.Parts {
+		if !p.Thought {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
+
+type UsageMetadata struct {
+	PromptTokenCount     int              `json:"promptTokenCount"`
+	CandidatesTokenCount int              `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int              `json:"thoughtsTokenCount"`
+	TotalTokenCount      int              `json:"totalTokenCount"`
+	PromptTokensDetails  []ModalityTokens `json:"promptTokensDetails,omitempty"`
+}
+
+// ModalityTokens is the token count for one input modality (TEXT, AUDIO,
+// VIDEO, ...).
+type ModalityTokens struct {
+	Modality   string `json:"modality"`
+	TokenCount int    `json:"tokenCount"`
+}
+
+// Client holds what every API call needs: credentials, the endpoint, retry
+// policy and the stats sink. Copy a Client to vary fields per call site; the
+// copies share Stats unless it is replaced.
+type Client struct {
+	APIKey  string
+	BaseURL string
+
+	// Project and Location select Vertex AI instead of the Gemini API;
+	// requests are then authenticated by TokenSource. See NewVertexClient.
+	Project     string
+	Location    string
+	TokenSource TokenSource
+
+	// HTTPClient is used for every request; nil means http.DefaultClient.
+	HTTPClient *http.Client
+
+	// MaxRetries and RetryDelay control retries of transient failures.
+	MaxRetries int
+	RetryDelay time.Duration
+
+	// Keys, when non-nil, rotates Gemini API requests over several keys in
+	// place of APIKey.
+	Keys *KeyPool
+
+	// Limiter, when non-nil, caps the rate and concurrency of API
+	// requests, retries included.
+	Limiter *RateLimiter
+
+	// Stats, when non-nil, accumulates request, token and byte counts.
+	Stats *Stats
+
+	// Cache, when non-nil, returns earlier responses for identical audio,
+	// model, prompt and generation settings without calling the API.
+	Cache *Cache
+
+	// FFmpegPath and FFprobePath name the binaries used for conversion
+	// and probing; empty means "ffmpeg" and "ffprobe" on the PATH.
+	FFmpegPath  string
+	FFprobePath string
+
+	// Logf, when non-nil, receives progress messages.
+	Logf func(format string, args ...any)
+
+	// OnProgress, when non-nil, is called as ffmpeg conversions and Files
+	// API uploads advance, e.g. to draw a progress bar. Calls come often,
+	// from the goroutine doing the work; the last of a step has Done equal
+	// to Total.
+	OnProgress func(Progress)
+}
+
+// NewClient returns a Client for the public Gemini API with default retry
+// settings and a fresh Stats.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		APIKey:     apiKey,
+		BaseURL:    DefaultBaseURL,
+		MaxRetries: DefaultMaxRetries,
+		RetryDelay: DefaultRetryDelay,
+		Stats:      NewStats(),
+	}
+}
+
+// Options controls a single transcription.
+type Options struct {
+	// Model defaults to DefaultModel, Prompt to DefaultPrompt.
+	Model  string
+	Prompt string
+
+	// FallbackModels are tried in order when Model fails with an exhausted
+	// quota, an overloaded or failing server, or an unknown model.
+	// Result.Model records the one that succeeded.
+	FallbackModels []string
+
+	// SystemInstruction is sent as Gemini's systemInstruction, apart from
+	// the prompt. Rules given there ("never add commentary") are followed
+	// more reliably than in the prompt. Follow-up text requests such as
+	// summaries don't carry it.
+	SystemInstruction string
+
+	// MimeType describes the audio passed to Transcribe. TranscribeFile
+	// derives it from the file.
+	MimeType string
+
+	// Upload selects inline data or the Files API: "auto" (the default,
+	// uploads when the request would exceed 20MB), "always" or "never".
+	Upload string
+
+	// Timestamps asks for timed segments; Words additionally asks for
+	// word-level timing and implies Timestamps.
+	Timestamps bool
+	Words      bool
+
+	// PlainTimestamps asks for timed segments as text lines (or, with
+	// Words, JSON described only in the prompt), for models and proxies
+	// without responseSchema support. By default the response is JSON
+	// constrained by a schema, which also labels speakers.
+	PlainTimestamps bool
+
+	// Topics asks for a short title of the topic under discussion on each
+	// timed segment, returned in Segment.Topic, for outlines such as
+	// Markdown headings. It needs the schema-constrained response and is
+	// ignored with PlainTimestamps.
+	Topics bool
+
+	// Language hints at the spoken language (a name or ISO 639-1 code),
+	// which helps with accents and code-switching. DetectLanguage asks the
+	// model to report the spoken language, returned in Result.Language.
+	Language       string
+	DetectLanguage bool
+
+	// Style is "clean", to leave out fillers (um, uh), false starts and
+	// repeated words, or "verbatim", to keep them all along with sounds
+	// such as [laughter]. The model is told so, and the transcript is then
+	// tidied to match. Empty leaves it to the prompt.
+	Style string
+
+	// Vocabulary lists names and terms the model should spell exactly. They
+	// are added to the prompt, and near-misses in the transcript are
+	// corrected afterwards with CorrectTerms.
+	Vocabulary []string
+
+	// Rules are find/replace fixes applied in order to the transcript,
+	// after vocabulary correction and before a two-pass translation or a
+	// summary.
+	Rules []Rule
+
+	// Redact hides personal information of the RedactCategories given:
+	// the model is asked to put placeholders such as [EMAIL] in its place,
+	// and patterns catch emails, phone numbers and street addresses it
+	// missed, in the transcript, its words and the summary.
+	Redact []string
+
+	// Profanity, "mask" or "remove", has swear words written as f*** or
+	// left out: the model is asked to, and a word list catches the common
+	// English ones it missed.
+	Profanity string
+
+	// Refine sends the audio again with the draft transcript and asks the
+	// model to correct it: misheard words, names from Vocabulary, missing
+	// punctuation. It helps with hard audio at the cost of a second request
+	// for every one sent.
+	Refine bool
+
+	// TranslateTo, when set, produces the transcript in that language. By
+	// default translation happens in the same request; TwoPassTranslate
+	// transcribes first and translates the text in a second call, keeping
+	// the original in Result.SourceText.
+	TranslateTo      string
+	TwoPassTranslate bool
+
+	// Summarize adds a summary of the transcript, made with a second,
+	// text-only request, in Result.Summary. SummaryStyle is "brief",
+	// "detailed", "bullets" or a free-form instruction; empty gives a
+	// paragraph plus key points.
+	Summarize    bool
+	SummaryStyle string
+
+	// GenerationConfig, when set, is sent with every request, e.g. a low
+	// temperature to reduce hallucination on noisy audio.
+	GenerationConfig *GenerationConfig
+
+	// SafetySettings, when set, replace the API's default blocking
+	// thresholds. See SafetyPreset.
+	SafetySettings []SafetySetting
+
+	// OnText, when set, switches to streamGenerateContent and receives the
+	// transcript text as it arrives. Chunked recordings report each chunk's
+	// new text once it has been stitched, so what OnText sees always adds up
+	// to Result.Text for untimed output.
+	OnText func(text string)
+
+	// Start and End limit TranscribeFile to a slice of the input (End zero
+	// means the end of the input). Timed segments keep the input's
+	// timeline. Needs ffmpeg.
+	Start time.Duration
+	End   time.Duration
+
+	// ChunkDuration splits longer recordings in TranscribeFile into chunks
+	// overlapping by ChunkOverlap. Zero disables chunking.
+	ChunkDuration time.Duration
+	ChunkOverlap  time.Duration
+
+	// ConvertTo is the codec files are converted to before sending: "mp3"
+	// (the default), "opus" for the smallest uploads, or "flac" or "wav"
+	// to lose nothing. See Conversions.
+	ConvertTo string
+
+	// FFmpegArgs replace the output options of the ffmpeg conversion
+	// (those for ConvertTo). A "-f" among them picks the
+	// container, mp3 when absent. FFmpegInputArgs go before -i, e.g. to
+	// choose a hardware decoder.
+	FFmpegArgs      []string
+	FFmpegInputArgs []string
+
+	// AudioTrack picks the audio track of a multi-track container such as
+	// an MKV screen recording, counting from 1 (ffmpeg's -map 0:a:N-1).
+	// Zero leaves the choice to ffmpeg.
+	AudioTrack int
+
+	// Channel picks the channel to transcribe, for recordings with each
+	// speaker on their own channel: "left", "right", a 0-based channel
+	// number, or "mix" (the default) to downmix them all. Selecting one
+	// makes every file go through ffmpeg.
+	Channel string
+
+	// Normalize evens out the loudness, and Denoise reduces background
+	// noise (with ffmpeg's arnndn and the RNNoise model file DenoiseModel
+	// when set, afftdn otherwise), as part of the ffmpeg conversion. Both
+	// help on quiet or noisy field recordings, and make every file go
+	// through ffmpeg.
+	Normalize    bool
+	Denoise      bool
+	DenoiseModel string
+
+	// MaxContinuations is how many follow-up requests may ask for the rest
+	// of a response cut off at the output token limit. Zero disables
+	// continuation.
+	MaxContinuations int
+
+	// Force sends audio longer than MaxAudioDuration in one request
+	// instead of failing with a TooLongError.
+	Force bool
+
+	// DryRun, when set, receives each transcription request instead of it
+	// being sent; the audio is still checked and converted. The
+	// transcription then fails with ErrDryRun.
+	DryRun func(DryRunRequest)
+
+	// duration is the length in seconds of the audio being sent, when
+	// known, for DryRunRequest.
+	duration float64
+}
+
+func (o Options) model() string {
+	if o.Model == "" {
+		return DefaultModel
+	}
+	return o.Model
+}
+
+// timed reports whether the options ask for timed segments.
+func (o Options) timed() bool {
+	return o.Timestamps || o.Words
+}
+
+// structured reports whether timed segments come as JSON constrained by
+// segmentSchema.
+func (o Options) structured() bool {
+	return o.timed() && !o.PlainTimestamps
+}
+
+// prompt returns the prompt with the style, language and vocabulary hints
+// and the instructions for translation, profanity, the requested timed
+// output shape and redaction appended, so custom prompts keep working.
+func (o Options) prompt() string {
+	p := o.Prompt
+	if p == "" {
+		p = DefaultPrompt
+	}
+	if s := styleInstruction(o.Style); s != "" {
+		p += "\n\n" + s
+	}
+	if o.Language != "" {
+		p += "\n\n" + languageHint(o.Language)
+	}
+	if len(o.Vocabulary) > 0 {
+		p += "\n\n" + vocabularyInstruction(o.Vocabulary)
+	}
+	if o.TranslateTo != "" && !o.TwoPassTranslate {
+		p += "\n\n" + translateInstruction(o.TranslateTo)
+	}
+	if o.Profanity != "" {
+		p += "\n\n" + profanityInstruction(o.Profanity)
+	}
+	switch {
+	case o.structured():
+		p += "\n\n" + structuredInstruction(o.Words, o.DetectLanguage, o.Topics)
+	case o.Words:
+		p = wordPrompt(p)
+	case o.Timestamps:
+		p = segmentPrompt(p)
+	}
+	if o.DetectLanguage && !o.structured() {
+		p += "\n\n" + languageInstruction
+	}
+	// Last, so it overrides naming the speakers
+	if len(o.Redact) > 0 {
+		p += "\n\n" + redactInstruction(o.Redact)
+	}
+	return p
+}
+
+// Result is the outcome of a transcription.
+type Result struct {
+	Model    string
+	Text     string
+	Segments []Segment
+
+	// Language is the ISO 639-1 code reported with DetectLanguage. Duration
+	// is the audio length in seconds: from ffprobe when TranscribeFile can
+	// run it, otherwise the end of the last segment.
+	Language string
+	Duration float64
+
+	// TranslatedTo is the target language when translation was requested;
+	// SourceText holds the untranslated transcript in two-pass mode.
+	TranslatedTo string
+	SourceText   string
+
+	// Summary is set when Options.Summarize is.
+	Summary string
+
+	// Suspicions gives the reasons to doubt that the transcript was heard
+	// rather than made up, such as a phrase repeated over and over or
+	// near-silent audio. It's empty when nothing looks wrong.
+	Suspicions []string
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// Transcribe reads audio of type opts.MimeType from r and transcribes it.
+func (c *Client) Transcribe(ctx context.Context, r io.Reader, opts Options) (Result, error) {
+	audioData, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.MimeType == "" {
+		return Result{}, errors.New("MimeType is required")
+	}
+	result, opts, err := c.withFallback(ctx, opts, func(opts Options) (Result, error) {
+		return c.transcribeData(ctx, audioData, opts.MimeType, opts)
+	})
+	if err != nil {
+		return result, err
+	}
+	return c.refine(ctx, result, opts)
+}
+
+// refine runs the follow-up steps opts asks for on a finished
+// transcription: the hallucination checks, the style's tidying, vocabulary
+// correction, translation, summarization, then redaction and the
+// profanity filter over all of it.
+func (c *Client) refine(ctx context.Context, result Result, opts Options) (Result, error) {
+	result.Suspicions = append(result.Suspicions, suspicions(result)...)
+	result = applyStyle(result, opts.Style)
+	result = correctVocabulary(result, opts.Vocabulary)
+	result = applyRules(result, opts.Rules)
+	result, err := c.translate(ctx, result, opts)
+	if err != nil {
+		return result, err
+	}
+	result, err = c.summarize(ctx, result, opts)
+	return censor(redact(result, opts.Redact), opts.Profanity), err
+}
+
+// transcribeData sends the audio and parses the response into a Result.
+func (c *Client) transcribeData(ctx context.Context, audioData []byte, mimeType string, opts Options) (Result, error) {
+	result := Result{Model: opts.model()}
+
+	upload := c.shouldUpload(len(audioData), opts.Upload)
+	c.logf("Audio size: %d bytes, MIME: %s\n", len(audioData), mimeType)
+	draftOpts := opts
+	if opts.Refine {
+		// Only the corrected transcript is streamed
+		draftOpts.OnText = nil
+	}
+	text, err := c.send(ctx, audioData, mimeType, upload, draftOpts)
+	if err != nil {
+		return result, fmt.Errorf("transcribing: %w", err)
+	}
+	if opts.Refine && text != "" {
+		if text, err = c.refineDraft(ctx, audioData, mimeType, upload, text, opts); err != nil {
+			return result, fmt.Errorf("refining: %w", err)
+		}
+	}
+
+	if opts.DetectLanguage && !opts.structured() {
+		result.Language, text = splitLanguage(text)
+	}
+	result.Text = text
+	if opts.timed() {
+		var lang string
+		start := time.Now()
+		result.Segments, lang, err = parseTimed(text, opts)
+		if lang != "" {
+			result.Language = lang
+		}
+		if err != nil {
+			return result, fmt.Errorf("parsing segments: %v", err)
+		}
+		c.logf("Parsed %d segments in %s\n", len(result.Segments), since(start))
+		result.Text = JoinSegments(result.Segments)
+		result.Duration = result.Segments[len(result.Segments)-1].End
+	}
+	return result, nil
+}
+
+// parseTimed parses the model output in whichever timed shape opts asked
+// for. Only structured output reports the language alongside.
+func parseTimed(text string, opts Options) ([]Segment, string, error) {
+	if opts.structured() {
+		return parseStructured(text)
+	}
+	var segments []Segment
+	var err error
+	if opts.Words {
+		segments, err = parseWordSegments(text)
+	} else {
+		segments, err = parseSegments(text)
+	}
+	return segments, "", err
+}
+
+// send sends the audio with the prompt for opts and returns the model's
+// text. When upload is set the audio goes through the Files API first and is
+// referenced by URI; otherwise it is inlined as base64. opts.OnText streams
+// the response. Timed output is constrained by segmentSchema unless
+// opts.PlainTimestamps is set. Responses are looked up in and added to
+// c.Cache.
+func (c *Client) send(ctx context.Context, audioData []byte, mimeType string, upload bool, opts Options) (string, error) {
+	if opts.structured() {
+		opts.GenerationConfig = opts.structuredConfig()
+	}
+	key := cacheKey(audioData, mimeType, opts)
+	if opts.DryRun != nil {
+		_, cached := c.Cache.get(key)
+		return "", c.dryRun(audioData, mimeType, upload, cached, opts)
+	}
+	if text, ok := c.Cache.get(key); ok {
+		c.logf("Using cached transcript\n")
+		if opts.OnText != nil {
+			opts.OnText(text)
+		}
+		return text, nil
+	}
+
+	var audio Part
+	if upload {
+		c = c.pinKey()
+		c.logf("Uploading via Files API...\n")
+		file, err := c.uploadFile(ctx, audioData, mimeType, "gemini-transcribe audio")
+		if err != nil {
+			return "", fmt.Errorf("upload failed: %w", err)
+		}
+		defer c.deleteFile(file.Name)
+		audio.FileData = &FileData{MimeType: file.MimeType, FileURI: file.URI}
+	} else {
+		audio.InlineData = &BlobData{MimeType: mimeType, raw: audioData}
+	}
+
+	c.logf("Sending to Gemini (%s)...\n", opts.model())
+	text, err := c.generate(ctx, opts, []Part{audio, {Text: opts.prompt()}})
+	if err == nil {
+		c.Cache.put(key, text)
+	}
+	return text, err
+}
+
+// generate sends a single user turn made of parts and returns the model's
+// text, streamed to opts.OnText when set. A response cut off at the output
+// token limit is continued in follow-up turns, up to opts.MaxContinuations
+// times, and the pieces are joined. Under a response schema each turn
+// answers with a JSON object of its own, so their segments are merged
+// instead.
+func (c *Client) generate(ctx context.Context, opts Options, parts []Part) (string, error) {
+	contents := []Content{{Role: "user", Parts: parts}}
+	structured := opts.GenerationConfig != nil && opts.GenerationConfig.ResponseSchema != nil
+	var text strings.Builder
+	var pieces []string
+	truncated := false
+	for round := 0; ; round++ {
+		var piece, finishReason string
+		var err error
+		if opts.OnText != nil {
+			piece, finishReason, err = c.generateStream(ctx, opts, contents, round == 0)
+		} else {
+			piece, finishReason, err = c.generateContent(ctx, opts, contents)
+		}
+		if err != nil {
+			return "", err
+		}
+		text.WriteString(piece)
+		pieces = append(pieces, piece)
+
+		if finishReason != "MAX_TOKENS" {
+			break
+		}
+		truncated = true
+		if round == opts.MaxContinuations {
+			c.logf("Output truncated at the token limit after %d continuations\n", round)
+			break
+		}
+		c.logf("Output hit the token limit, continuing (%d/%d)...\n", round+1, opts.MaxContinuations)
+		next := continuePrompt
+		if structured {
+			next = structuredContinuePrompt
+		}
+		contents = append(contents,
+			Content{Role: "model", Parts: []Part{{Text: piece}}},
+			Content{Role: "user", Parts: []Part{{Text: next}}})
+	}
+
+	if opts.OnText != nil && text.Len() == 0 {
+		return "", ErrNoTranscription
+	}
+	if structured && truncated {
+		return mergeStructured(pieces), nil
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// generateContent makes one generateContent call and returns the untrimmed
+// text and finish reason of the first candidate.
+func (c *Client) generateContent(ctx context.Context, opts Options, contents []Content) (string, string, error) {
+	reqBody, err := requestBody(contents, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	url := c.endpoint(opts.model(), "generateContent", "")
+	start := time.Now()
+	stop := c.waiting("the response", start)
+	status, body, err := c.postWithRetry(ctx, url, "application/json", reqBody)
+	stop()
+	if err != nil {
+		return "", "", err
+	}
+	c.logf("Response after %s\n", since(start))
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		if status != http.StatusOK {
+			return "", "", responseError(status, body)
+		}
+		return "", "", fmt.Errorf("failed to parse response: %v\nBody: %s", err, string(body))
+	}
+
+	c.Stats.recordUsage(geminiResp.UsageMetadata)
+
+	if geminiResp.Error != nil {
+		return "", "", geminiResp.Error
+	}
+	if blocked := geminiResp.blocked(); blocked != nil {
+		return "", "", blocked
+	}
+
+	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
+		return "", "", ErrNoTranscription
+	}
+	// Requests ask for the default single candidate; should more come back,
+	// the first is the one the API ranks best.
+	if n := len(geminiResp.Candidates); n > 1 {
+		c.logf("Response has %d candidates, using the first\n", n)
+	}
+
+	cand := geminiResp.Candidates[0]
+	return cand.text(), cand.FinishReason, nil
+}
@@ -0,0 +1,82 @@
+package transcribe
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrDryRun is returned, with an empty Result, when Options.DryRun kept the
+// transcription requests from being sent.
+var ErrDryRun = errors.New("dry run")
+
+// DryRunRequest describes a transcription request Options.DryRun kept from
+// being sent, after the audio was converted as usual.
+type DryRunRequest struct {
+	Model string
+
+	// URL is the endpoint the request would go to, with the API key
+	// redacted.
+	URL string
+
+	// Prompt and SystemInstruction are the text sent with the audio.
+	Prompt            string
+	SystemInstruction string
+
+	// MimeType and AudioBytes describe the converted audio, which Upload
+	// says would go through the Files API rather than inline.
+	MimeType   string
+	AudioBytes int
+	Upload     bool
+
+	// RequestBytes is the size of the request body.
+	RequestBytes int64
+
+	// Duration is the length of the audio in seconds, zero when unknown.
+	// AudioTokens estimates its input tokens from that, and PromptTokens
+	// those of the text at about four characters a token.
+	Duration     float64
+	AudioTokens  int
+	PromptTokens int
+
+	// Cached is set when the response is already in the client's cache,
+	// so no request would be made.
+	Cached bool
+}
+
+// dryRun passes the request send would make to opts.DryRun and returns
+// ErrDryRun.
+func (c *Client) dryRun(audioData []byte, mimeType string, upload, cached bool, opts Options) error {
+	audio := Part{InlineData: &BlobData{MimeType: mimeType, raw: audioData}}
+	if upload {
+		audio = Part{FileData: &FileData{MimeType: mimeType, FileURI: "files/dry-run"}}
+	}
+	body, err := requestBody([]Content{{Role: "user", Parts: []Part{audio, {Text: opts.prompt()}}}}, opts)
+	if err != nil {
+		return err
+	}
+
+	method, query := "generateContent", ""
+	if opts.OnText != nil {
+		method, query = "streamGenerateContent", "alt=sse"
+	}
+	url := c.endpoint(opts.model(), method, query)
+	if c.APIKey != "" {
+		url = strings.Replace(url, "key="+c.APIKey, "key=REDACTED", 1)
+	}
+
+	opts.DryRun(DryRunRequest{
+		Model:             opts.model(),
+		URL:               url,
+		Prompt:            opts.prompt(),
+		SystemInstruction: opts.SystemInstruction,
+		MimeType:          mimeType,
+		AudioBytes:        len(audioData),
+		Upload:            upload,
+		RequestBytes:      body.size(),
+		Duration:          opts.duration,
+		AudioTokens:       int(opts.duration * audioTokensPerSecond),
+		PromptTokens:      (len(opts.prompt()) + len(opts.SystemInstruction) + 3) / 4,
+		Cached:            cached,
+	})
+	return ErrDryRun
+}
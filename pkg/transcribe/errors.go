@@ -0,0 +1,108 @@
+package transcribe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Error classes callers can test for with errors.Is, regardless of which
+// request or pipeline step failed.
+var (
+	// ErrAuth covers rejected API keys and missing or invalid Google Cloud
+	// credentials.
+	ErrAuth = errors.New("authentication failed")
+
+	// ErrQuota covers rate limits and exhausted quotas that outlasted the
+	// retries.
+	ErrQuota = errors.New("quota exceeded")
+
+	// ErrNoTranscription is returned when the API answers without any
+	// text.
+	ErrNoTranscription = errors.New("no transcription in response")
+)
+
+// APIError is an error response from the Gemini or Vertex AI API. It
+// matches ErrAuth or ErrQuota when the status says so.
+type APIError struct {
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (%d): %s", e.Code, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		// An invalid key is reported as a plain 400 INVALID_ARGUMENT
+		return e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden ||
+			e.Status == "UNAUTHENTICATED" || e.Status == "PERMISSION_DENIED" ||
+			strings.Contains(e.Message, "API key not valid")
+	case ErrQuota:
+		return e.Code == http.StatusTooManyRequests || e.Status == "RESOURCE_EXHAUSTED"
+	}
+	return false
+}
+
+// parseAPIError returns the error in an API response body, or nil when the
+// body doesn't hold one.
+func parseAPIError(body []byte) *APIError {
+	var e struct {
+		Error *APIError `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return nil
+	}
+	return e.Error
+}
+
+// responseError turns a failed response into an *APIError, keeping the raw
+// body as the message when it isn't a JSON error.
+func responseError(status int, body []byte) *APIError {
+	if e := parseAPIError(body); e != nil {
+		return e
+	}
+	return &APIError{Code: status, Message: strings.TrimSpace(string(body))}
+}
+
+// FFmpegError is a failed ffmpeg run, with what it printed.
+type FFmpegError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *FFmpegError) Error() string {
+	return fmt.Sprintf("ffmpeg failed: %v\n%s", e.Err, e.Stderr)
+}
+
+func (e *FFmpegError) Unwrap() error { return e.Err }
+
+// TooLongError is returned before any request when the audio sent in one
+// request would be longer than Gemini accepts. Options.Force sends it
+// anyway.
+type TooLongError struct {
+	Duration time.Duration
+	Limit    time.Duration
+
+	// Tokens estimates the input tokens the audio would cost.
+	Tokens int
+}
+
+func (e *TooLongError) Error() string {
+	return fmt.Sprintf("audio is %s long, over the %s Gemini accepts per request (about %d input tokens)",
+		e.Duration, e.Limit, e.Tokens)
+}
+
+// authError marks a credentials failure that happens before any request
+// reaches the API.
+type authError struct{ err error }
+
+func (e authError) Error() string        { return e.err.Error() }
+func (e authError) Unwrap() error        { return e.err }
+func (e authError) Is(target error) bool { return target == ErrAuth }
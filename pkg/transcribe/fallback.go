@@ -0,0 +1,36 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// withFallback runs attempt with opts.Model and then, while the failure is
+// one a different model may not share, with each of opts.FallbackModels in
+// turn. The result's Model names the model that produced it, and the
+// returned Options carry that model for any follow-up requests.
+func (c *Client) withFallback(ctx context.Context, opts Options, attempt func(Options) (Result, error)) (Result, Options, error) {
+	models := append([]string{opts.model()}, opts.FallbackModels...)
+	for i := 0; ; i++ {
+		opts.Model = models[i]
+		result, err := attempt(opts)
+		if err == nil || i == len(models)-1 || ctx.Err() != nil || !modelUnavailable(err) {
+			return result, opts, err
+		}
+		c.logf("%s failed (%v), falling back to %s\n", models[i], err, models[i+1])
+	}
+}
+
+// modelUnavailable reports whether err means the model couldn't serve the
+// request: its quota is exhausted, it is overloaded or failing, or it
+// doesn't exist.
+func modelUnavailable(err error) bool {
+	if errors.Is(err, ErrQuota) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.Code == http.StatusNotFound || apiErr.Code >= 500 ||
+			apiErr.Status == "NOT_FOUND" || apiErr.Status == "UNAVAILABLE")
+}
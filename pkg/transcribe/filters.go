@@ -0,0 +1,98 @@
+package transcribe
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// loudnormFilter normalizes to -16 LUFS, loud enough for quiet
+	// speakers without clipping the loud ones.
+	loudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+	// afftdnFilter is FFT denoising with a noise floor suited to room
+	// tone and hiss.
+	afftdnFilter = "afftdn=nf=-25"
+)
+
+// CheckChannel reports whether channel is a valid Options.Channel.
+func CheckChannel(channel string) error {
+	_, err := channelFilter(channel)
+	return err
+}
+
+// channelFilter returns the pan filter that keeps only the selected
+// channel, or "" to downmix all of them.
+func channelFilter(channel string) (string, error) {
+	n, err := channelIndex(channel)
+	if n < 0 || err != nil {
+		return "", err
+	}
+	return "pan=mono|c0=c" + strconv.Itoa(n), nil
+}
+
+// channelIndex returns the 0-based index of the selected channel, or -1 to
+// downmix all of them.
+func channelIndex(channel string) (int, error) {
+	switch channel {
+	case "", "mix":
+		return -1, nil
+	case "left":
+		return 0, nil
+	case "right":
+		return 1, nil
+	}
+	if n, err := strconv.Atoi(channel); err == nil && n >= 0 {
+		return n, nil
+	}
+	return -1, fmt.Errorf("unknown channel %q (want left, right, mix or a channel number)", channel)
+}
+
+// audioFilters returns the ffmpeg filter chain opts asks for: channel
+// selection, denoising, then loudness normalization. It is empty when
+// none is set.
+func audioFilters(opts Options) (string, error) {
+	var filters []string
+	pan, err := channelFilter(opts.Channel)
+	if err != nil {
+		return "", err
+	}
+	if pan != "" {
+		filters = append(filters, pan)
+	}
+	switch {
+	case opts.DenoiseModel != "":
+		// arnndn's model path is quoted so colons and commas in it
+		// don't end the option
+		filters = append(filters, "arnndn=m='"+strings.ReplaceAll(opts.DenoiseModel, "'", `'\''`)+"'")
+	case opts.Denoise:
+		filters = append(filters, afftdnFilter)
+	}
+	if opts.Normalize {
+		filters = append(filters, loudnormFilter)
+	}
+	return strings.Join(filters, ","), nil
+}
+
+// trackArgs returns the ffmpeg output options that select opts.AudioTrack.
+func (o Options) trackArgs() []string {
+	if o.AudioTrack <= 0 {
+		return nil
+	}
+	return []string{"-map", fmt.Sprintf("0:a:%d", o.AudioTrack-1)}
+}
+
+// withAudioFilter adds filters to the end of the audio filter chain in
+// args, or as a new -af when args have none.
+func withAudioFilter(args []string, filters string) []string {
+	args = slices.Clone(args)
+	for i, arg := range args {
+		if (arg == "-af" || arg == "-filter:a") && i+1 < len(args) {
+			args[i+1] += "," + filters
+			return args
+		}
+	}
+	return append(args, "-af", filters)
+}
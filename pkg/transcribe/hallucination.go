@@ -0,0 +1,107 @@
+package transcribe
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// stockPhrases are what models tend to produce for silence or music,
+// learned from the end of videos and subtitle credits.
+var stockPhrases = []string{
+	"thank you for watching",
+	"thanks for watching",
+	"thank you for listening",
+	"thanks for listening",
+	"please subscribe",
+	"like and subscribe",
+	"subscribe to my channel",
+	"see you in the next video",
+	"subtitles by",
+	"subtitled by",
+	"captions by",
+	"transcribed by",
+	"amara org",
+}
+
+const (
+	// shortTranscript is the most words a transcript can have for a
+	// stock phrase in it to be suspicious.
+	shortTranscript = 20
+
+	// maxWordsPerMinute is faster than anyone speaks for long; more words
+	// than that means the output ran away.
+	maxWordsPerMinute = 400
+)
+
+// suspicions looks for the signs of a made-up transcript: a phrase
+// repeated over and over, a short transcript that is mostly a stock
+// phrase, or more words than the audio has time for.
+func suspicions(result Result) []string {
+	words := normalizedWords(result.Text)
+	if len(words) == 0 {
+		return nil
+	}
+	var found []string
+	if phrase, n := longestRepeat(words); n > 0 {
+		found = append(found, fmt.Sprintf("%q repeats %d times in a row", phrase, n))
+	}
+	if len(words) <= shortTranscript {
+		text := " " + strings.Join(words, " ") + " "
+		for _, p := range stockPhrases {
+			if strings.Contains(text, " "+p+" ") {
+				found = append(found, fmt.Sprintf("it is little more than %q, which models produce for silence and music", p))
+				break
+			}
+		}
+	}
+	if d := result.Duration; d >= 10 && float64(len(words))/d*60 > maxWordsPerMinute {
+		found = append(found, fmt.Sprintf("%d words in %s is faster than anyone speaks", len(words), time.Duration(d*float64(time.Second)).Round(time.Second)))
+	}
+	return found
+}
+
+// longestRepeat finds the phrase of up to ten words repeated back to back
+// the most, counting it when it repeats at least four times and twelve
+// words (eight for a single word) in all. n is zero when none does.
+func longestRepeat(words []string) (phrase string, n int) {
+	for size := 1; size <= 10; size++ {
+		for i := 0; i+size <= len(words); i++ {
+			reps := 1
+			for j := i + size; j+size <= len(words) && slices.Equal(words[i:i+size], words[j:j+size]); j += size {
+				reps++
+			}
+			least := max(4, (12+size-1)/size)
+			if size == 1 {
+				least = 8
+			}
+			if reps >= least && reps*size > n*len(strings.Fields(phrase)) {
+				phrase, n = strings.Join(words[i:i+size], " "), reps
+			}
+			// Starting later within the run finds no more repeats
+			i += (reps - 1) * size
+		}
+	}
+	return phrase, n
+}
+
+// normalizedWords splits text into lower-case words without punctuation.
+func normalizedWords(text string) []string {
+	var words []string
+	for _, f := range strings.Fields(text) {
+		w := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '\'' {
+				return unicode.ToLower(r)
+			}
+			if r == '.' {
+				// "amara.org"
+				return ' '
+			}
+			return -1
+		}, f)
+		words = append(words, strings.Fields(w)...)
+	}
+	return words
+}
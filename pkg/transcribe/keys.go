@@ -0,0 +1,81 @@
+package transcribe
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyPool spreads Gemini API requests over several API keys, taking them
+// in turn and resting a key that hit a rate limit (429) until its backoff
+// has passed. Keys from different projects pool their quotas.
+type KeyPool struct {
+	mu    sync.Mutex
+	keys  []string
+	until []time.Time // end of each key's rate limit backoff
+	next  int
+}
+
+// NewKeyPool returns a pool rotating through keys, which must not be
+// empty.
+func NewKeyPool(keys []string) *KeyPool {
+	return &KeyPool{keys: keys, until: make([]time.Time, len(keys))}
+}
+
+// Len returns the number of keys in the pool.
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+// pick returns the next key in turn that isn't backing off or, when all
+// are, the one free soonest, with its index.
+func (p *KeyPool) pick() (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	best := -1
+	for n := range p.keys {
+		i := (p.next + n) % len(p.keys)
+		if !p.until[i].After(now) {
+			best = i
+			break
+		}
+		if best < 0 || p.until[i].Before(p.until[best]) {
+			best = i
+		}
+	}
+	p.next = (best + 1) % len(p.keys)
+	return best, p.keys[best]
+}
+
+// rest keeps key i out of rotation for d.
+func (p *KeyPool) rest(i int, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until[i] = time.Now().Add(d)
+}
+
+// wait returns how long until some key is free again, 0 if one is now.
+func (p *KeyPool) wait() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	soonest := p.until[0]
+	for _, t := range p.until[1:] {
+		if t.Before(soonest) {
+			soonest = t
+		}
+	}
+	return max(time.Until(soonest), 0)
+}
+
+// pinKey returns c, or when c rotates keys a copy of it fixed to the next
+// one. Files uploaded through the Files API belong to the project of the
+// key that uploaded them, so the requests that use them must stay on it.
+func (c *Client) pinKey() *Client {
+	if c.Keys == nil || c.vertex() {
+		return c
+	}
+	pinned := *c
+	_, pinned.APIKey = c.Keys.pick()
+	pinned.Keys = nil
+	return &pinned
+}
@@ -0,0 +1,82 @@
+package transcribe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyPool(t *testing.T) {
+	p := NewKeyPool([]string{"a", "b", "c"})
+	var got []string
+	for range 4 {
+		_, key := p.pick()
+		got = append(got, key)
+	}
+	if s := strings.Join(got, ""); s != "abca" {
+		t.Errorf("keys taken in turn = %s, want abca", s)
+	}
+
+	// b rests, so after a comes c
+	p.rest(1, time.Hour)
+	if _, key := p.pick(); key != "c" {
+		t.Errorf("after resting b, got %s, want c", key)
+	}
+	if _, key := p.pick(); key != "a" {
+		t.Errorf("got %s, want a", key)
+	}
+	if d := p.wait(); d != 0 {
+		t.Errorf("wait() = %s with keys free", d)
+	}
+
+	// All resting: the one free soonest
+	p.rest(0, 2*time.Hour)
+	p.rest(2, 30*time.Minute)
+	if i, key := p.pick(); i != 2 || key != "c" {
+		t.Errorf("with all resting got %d %s, want 2 c", i, key)
+	}
+	if d := p.wait(); d < 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("wait() = %s, want about 30m", d)
+	}
+}
+
+// TestKeyPoolRetry checks that a request rate-limited on one key is
+// retried at once on the next.
+func TestKeyPoolRetry(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+		if key == "a" {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{Keys: NewKeyPool([]string{"a", "b"}), MaxRetries: 3, RetryDelay: time.Hour}
+	start := time.Now()
+	for range 2 {
+		if status, _, err := c.postWithRetry(context.Background(), srv.URL, "", payload{}); err != nil || status != http.StatusOK {
+			t.Fatalf("postWithRetry() = %d, %v", status, err)
+		}
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("took %s; the retry should go to the free key at once", d)
+	}
+	if s := strings.Join(keys, ""); s != "abb" {
+		t.Errorf("keys sent = %s, want abb", s)
+	}
+
+	pinned := c.pinKey()
+	if pinned.Keys != nil || pinned.APIKey != "b" {
+		t.Errorf("pinKey() = key %q, pool %v; want b alone", pinned.APIKey, pinned.Keys)
+	}
+}
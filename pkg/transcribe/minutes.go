@@ -0,0 +1,72 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Minutes is the structured outcome of a meeting, extracted from its
+// transcript.
+type Minutes struct {
+	Summary       string       `json:"summary"`
+	Decisions     []string     `json:"decisions"`
+	ActionItems   []ActionItem `json:"action_items"`
+	OpenQuestions []string     `json:"open_questions"`
+}
+
+// ActionItem is a task someone agreed to do. Owner and Due are empty when
+// the meeting didn't say.
+type ActionItem struct {
+	Task  string `json:"task"`
+	Owner string `json:"owner,omitempty"`
+	Due   string `json:"due,omitempty"`
+}
+
+const minutesPrompt = `From the meeting transcript below, extract the minutes as JSON with exactly these fields:
+{"summary": "two or three sentences on what the meeting covered",
+ "decisions": ["each decision that was made"],
+ "action_items": [{"task": "what needs doing", "owner": "who agreed to do it, or empty", "due": "deadline if one was mentioned, or empty"}],
+ "open_questions": ["each question raised but not resolved"]}
+Use empty arrays when there is nothing to list. Only include what the transcript supports; don't invent owners or dates. Write in the same language as the transcript.
+
+`
+
+// Minutes extracts decisions, action items and open questions from a
+// meeting transcript with a text-only request. Only the model and
+// generation settings of opts are used.
+func (c *Client) Minutes(ctx context.Context, transcript string, opts Options) (Minutes, error) {
+	gen := GenerationConfig{}
+	if opts.GenerationConfig != nil {
+		gen = *opts.GenerationConfig
+	}
+	gen.ResponseMIMEType = "application/json"
+	opts.GenerationConfig = &gen
+	opts.OnText = nil
+	opts.SystemInstruction = ""
+
+	text, err := c.generate(ctx, opts, []Part{{Text: minutesPrompt + transcript}})
+	if err != nil {
+		return Minutes{}, fmt.Errorf("extracting minutes: %w", err)
+	}
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var m Minutes
+	if err := json.Unmarshal([]byte(text), &m); err != nil {
+		return Minutes{}, fmt.Errorf("parsing minutes: %v", err)
+	}
+	// Keep empty lists as [] rather than null in JSON output
+	if m.Decisions == nil {
+		m.Decisions = []string{}
+	}
+	if m.ActionItems == nil {
+		m.ActionItems = []ActionItem{}
+	}
+	if m.OpenQuestions == nil {
+		m.OpenQuestions = []string{}
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const modelsURLTemplate = "%s/v1beta/models?key=%s&pageSize=1000"
+
+// Model describes a model returned by ListModels.
+type Model struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	Description                string   `json:"description,omitempty"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+// ID is the name to pass as Options.Model, without the "models/" prefix.
+func (m Model) ID() string {
+	return strings.TrimPrefix(m.Name, "models/")
+}
+
+// AcceptsAudio reports whether the model can transcribe: a Gemini model
+// serving generateContent. The API doesn't list input modalities, so
+// embedding, image and speech generation models are excluded by name.
+func (m Model) AcceptsAudio() bool {
+	id := m.ID()
+	if !strings.HasPrefix(id, "gemini") || !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+		return false
+	}
+	for _, s := range []string{"embedding", "image", "-tts"} {
+		if strings.Contains(id, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListModels returns every model the API key can use, following pagination.
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	if c.vertex() {
+		return nil, errors.New("listing models is not supported on Vertex AI")
+	}
+
+	var models []Model
+	pageToken := ""
+	for {
+		u := fmt.Sprintf(modelsURLTemplate, c.BaseURL, c.APIKey)
+		if pageToken != "" {
+			u += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		resp, err := c.doWithRetry(ctx, "GET", u, "", payload{})
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != 200 {
+			return nil, responseError(resp.StatusCode, body)
+		}
+
+		var page struct {
+			Models        []Model `json:"models"`
+			NextPageToken string  `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %v", err)
+		}
+		models = append(models, page.Models...)
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,117 @@
+package transcribe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+)
+
+// payload is a JSON request body whose inline audio is kept as raw bytes
+// and base64-encoded while the body is sent. Marshaling the audio up front
+// would hold it three times over (raw, encoded, and inside the JSON); this
+// way peak memory stays near the size of the audio. The body can be opened
+// again for each retry.
+type payload struct {
+	json  [][]byte // JSON around the audio, one more than audio
+	audio [][]byte
+}
+
+// size is the length of the body as sent.
+func (p payload) size() int64 {
+	var n int64
+	for _, j := range p.json {
+		n += int64(len(j))
+	}
+	for _, a := range p.audio {
+		n += int64(base64.StdEncoding.EncodedLen(len(a)))
+	}
+	return n
+}
+
+// open returns a reader for the body. Each audio part is encoded in its
+// own goroutine through an io.Pipe; closing the reader stops them.
+func (p payload) open() io.ReadCloser {
+	r := &payloadReader{}
+	var readers []io.Reader
+	for i, j := range p.json {
+		readers = append(readers, bytes.NewReader(j))
+		if i < len(p.audio) {
+			pr, pw := io.Pipe()
+			go func(audio []byte) {
+				enc := base64.NewEncoder(base64.StdEncoding, pw)
+				_, err := enc.Write(audio)
+				if err == nil {
+					err = enc.Close()
+				}
+				pw.CloseWithError(err)
+			}(p.audio[i])
+			readers = append(readers, pr)
+			r.pipes = append(r.pipes, pr)
+		}
+	}
+	r.Reader = io.MultiReader(readers...)
+	return r
+}
+
+type payloadReader struct {
+	io.Reader
+	pipes []*io.PipeReader
+}
+
+func (r *payloadReader) Close() error {
+	for _, pr := range r.pipes {
+		pr.Close()
+	}
+	return nil
+}
+
+// requestBody builds the request for the conversation so far with the
+// safety and generation settings from opts. Inline audio given as raw bytes
+// is left out of the marshaled JSON and streamed in its place when the
+// payload is read.
+func requestBody(contents []Content, opts Options) (payload, error) {
+	// Marshal a copy with a unique placeholder standing in for each raw
+	// audio part, then split the JSON around the placeholders
+	marker := fmt.Sprintf("inline-audio-%016x-", rand.Uint64())
+	var audio [][]byte
+	copied := make([]Content, len(contents))
+	for i, content := range contents {
+		copied[i] = Content{Role: content.Role, Parts: make([]Part, len(content.Parts))}
+		for j, part := range content.Parts {
+			if part.InlineData != nil && part.InlineData.raw != nil {
+				part.InlineData = &BlobData{
+					MimeType: part.InlineData.MimeType,
+					Data:     marker + strconv.Itoa(len(audio)),
+				}
+				audio = append(audio, content.Parts[j].InlineData.raw)
+			}
+			copied[i].Parts[j] = part
+		}
+	}
+
+	req := GeminiRequest{
+		Contents:         copied,
+		SafetySettings:   opts.SafetySettings,
+		GenerationConfig: opts.GenerationConfig,
+	}
+	if opts.SystemInstruction != "" {
+		req.SystemInstruction = &Content{Parts: []Part{{Text: opts.SystemInstruction}}}
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return payload{}, err
+	}
+
+	p := payload{audio: audio}
+	for i := range audio {
+		before, after, _ := bytes.Cut(data, []byte(marker+strconv.Itoa(i)+`"`))
+		p.json = append(p.json, before)
+		data = append([]byte(`"`), after...)
+	}
+	p.json = append(p.json, data)
+	return p, nil
+}
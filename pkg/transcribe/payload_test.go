@@ -0,0 +1,70 @@
+package transcribe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestRequestBody(t *testing.T) {
+	first := bytes.Repeat([]byte{0, 1, 2, 0xff}, 10000)
+	second := []byte(`audio with "quotes" and inline-audio-0`)
+	contents := []Content{
+		{Role: "user", Parts: []Part{
+			{InlineData: &BlobData{MimeType: "audio/mpeg", raw: first}},
+			{Text: "Transcribe both."},
+			{InlineData: &BlobData{MimeType: "audio/wav", raw: second}},
+		}},
+		{Role: "model", Parts: []Part{{Text: "Partial"}}},
+	}
+	temperature := 0.5
+	opts := Options{SystemInstruction: "Be brief.", GenerationConfig: &GenerationConfig{Temperature: &temperature}}
+
+	p, err := requestBody(contents, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.audio) != 2 || len(p.json) != 3 {
+		t.Fatalf("payload has %d audio and %d JSON pieces, want 2 and 3", len(p.audio), len(p.json))
+	}
+
+	// The streamed body is what marshaling the encoded audio would give
+	encoded := []Content{
+		{Role: "user", Parts: []Part{
+			{InlineData: &BlobData{MimeType: "audio/mpeg", Data: base64.StdEncoding.EncodeToString(first)}},
+			{Text: "Transcribe both."},
+			{InlineData: &BlobData{MimeType: "audio/wav", Data: base64.StdEncoding.EncodeToString(second)}},
+		}},
+		contents[1],
+	}
+	want, _ := json.Marshal(GeminiRequest{
+		Contents:          encoded,
+		GenerationConfig:  opts.GenerationConfig,
+		SystemInstruction: &Content{Parts: []Part{{Text: "Be brief."}}},
+	})
+	for i := range 2 {
+		r := p.open()
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("read %d = %.200s...\nwant %.200s...", i, got, want)
+		}
+		if p.size() != int64(len(got)) {
+			t.Errorf("size() = %d, read %d bytes", p.size(), len(got))
+		}
+	}
+	// The contents keep their raw audio
+	if contents[0].Parts[0].InlineData.Data != "" || contents[0].Parts[0].InlineData.raw == nil {
+		t.Error("requestBody changed its contents")
+	}
+
+	// Closing part way stops the encoders
+	r := p.open()
+	io.ReadFull(r, make([]byte, 100))
+	r.Close()
+}
@@ -0,0 +1,51 @@
+package transcribe
+
+import "strings"
+
+// Price is a model's list price in USD per million tokens. Thinking tokens
+// are billed as output.
+type Price struct {
+	Input      float64
+	AudioInput float64
+	Output     float64
+}
+
+// Prices holds the standard paid-tier rates for the models this tool is
+// usually run with (prompts up to 200k tokens). Versioned names such as
+// "gemini-2.5-flash-preview-09-2025" use the longest matching prefix.
+var Prices = map[string]Price{
+	"gemini-2.5-pro":        {Input: 1.25, AudioInput: 1.25, Output: 10.00},
+	"gemini-2.5-flash":      {Input: 0.30, AudioInput: 1.00, Output: 2.50},
+	"gemini-2.5-flash-lite": {Input: 0.10, AudioInput: 0.30, Output: 0.40},
+	"gemini-2.0-flash":      {Input: 0.10, AudioInput: 0.70, Output: 0.40},
+	"gemini-2.0-flash-lite": {Input: 0.075, AudioInput: 0.075, Output: 0.30},
+}
+
+// PriceFor returns the price of model, matching the longest known prefix.
+func PriceFor(model string) (Price, bool) {
+	model = strings.TrimPrefix(model, "models/")
+	var best string
+	for name := range Prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return Prices[best], true
+}
+
+// EstimateCost prices the token counts in s at model's list price. It
+// reports false for models without a known price.
+func EstimateCost(model string, s StatsSnapshot) (float64, bool) {
+	p, ok := PriceFor(model)
+	if !ok {
+		return 0, false
+	}
+	textIn := s.PromptTokens - s.AudioTokens
+	cost := float64(textIn)*p.Input +
+		float64(s.AudioTokens)*p.AudioInput +
+		float64(s.OutputTokens+s.ThoughtsTokens)*p.Output
+	return cost / 1e6, true
+}
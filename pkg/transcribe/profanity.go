@@ -0,0 +1,93 @@
+package transcribe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ProfanityModes are the values of Options.Profanity.
+var ProfanityModes = []string{"mask", "remove"}
+
+// profanityRe is the word list behind the prompt, catching the common
+// English swear words the model left in, with their inflections. Words
+// with an everyday meaning too (cock, prick, damning) are left to the
+// model.
+var profanityRe = regexp.MustCompile(`(?i)\b(?:(?:mother)?fuck\w*|(?:bull)?shit(?:s|ty|ted|ting|head|heads)?|bitch\w*|bastards?|a(?:ss|rse)holes?|cunts?|dickheads?|piss(?:ed|es|ing)?|twats?|wank\w*|sluts?|whores?|(?:god)?damn(?:it|ed)?)\b`)
+
+// maskedRe matches words already masked as f***, so remove mode drops
+// the model's masks too.
+var maskedRe = regexp.MustCompile(`(?i)\b[a-z]\*{2,}[a-z]*`)
+
+// CheckProfanity returns an error for a mode not in ProfanityModes.
+func CheckProfanity(mode string) error {
+	switch mode {
+	case "", "mask", "remove":
+		return nil
+	}
+	return fmt.Errorf("unknown profanity filter %q (want %s)", mode, strings.Join(ProfanityModes, " or "))
+}
+
+// profanityInstruction asks the model to filter profanity the way mode
+// says.
+func profanityInstruction(mode string) string {
+	if mode == "remove" {
+		return "Leave profanity and swear words out of the transcription."
+	}
+	return "Write profanity and swear words as their first letter followed by asterisks, e.g. f***."
+}
+
+// filterProfanity masks or removes the profanity in s.
+func filterProfanity(s, mode string) string {
+	if mode == "remove" {
+		s = profanityRe.ReplaceAllString(s, "")
+		s = maskedRe.ReplaceAllString(s, "")
+		return tidySpaces(s)
+	}
+	return profanityRe.ReplaceAllStringFunc(s, maskWord)
+}
+
+// maskWord keeps the first letter of a word and stars the rest.
+func maskWord(w string) string {
+	first := []rune(w)[0]
+	return string(first) + strings.Repeat("*", len([]rune(w))-1)
+}
+
+var (
+	spacesRe         = regexp.MustCompile(`[ \t]{2,}`)
+	spaceBeforePunct = regexp.MustCompile(`[ \t]+([.,!?;:])`)
+	doubledComma     = regexp.MustCompile(`,{2,}`)
+)
+
+// tidySpaces closes the gaps removed words leave behind.
+func tidySpaces(s string) string {
+	s = spacesRe.ReplaceAllString(s, " ")
+	s = spaceBeforePunct.ReplaceAllString(s, "$1")
+	s = doubledComma.ReplaceAllString(s, ",")
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// censor applies opts.Profanity to every text in the result. In remove
+// mode timed words that are nothing but profanity are dropped.
+func censor(result Result, mode string) Result {
+	if mode == "" {
+		return result
+	}
+	result = mapText(result, func(s string) string { return filterProfanity(s, mode) })
+	for i := range result.Segments {
+		var words []Word
+		for _, w := range result.Segments[i].Words {
+			if w.Word = filterProfanity(w.Word, mode); w.Word != "" {
+				words = append(words, w)
+			}
+		}
+		result.Segments[i].Words = words
+	}
+	result.SourceText = filterProfanity(result.SourceText, mode)
+	result.Summary = filterProfanity(result.Summary, mode)
+	return result
+}
@@ -0,0 +1,51 @@
+package transcribe
+
+import "testing"
+
+func TestFilterProfanity(t *testing.T) {
+	tests := []struct {
+		in, mode, want string
+	}{
+		{"What the fuck is that?", "mask", "What the f*** is that?"},
+		{"Shit, I forgot. Goddamn it.", "mask", "S***, I forgot. G****** it."},
+		{"That's bullshit!", "mask", "That's b*******!"},
+		{"What the fuck is that?", "remove", "What the is that?"},
+		{"Well, shit, it broke.", "remove", "Well, it broke."},
+		{"It's f*** great.", "remove", "It's great."},
+		{"line one damn\nline two", "remove", "line one\nline two"},
+	}
+	for _, tt := range tests {
+		if got := filterProfanity(tt.in, tt.mode); got != tt.want {
+			t.Errorf("filterProfanity(%q, %q) = %q, want %q", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+// TestFilterProfanityLeavesWordsAlone lists ordinary words that contain or
+// look like a swear word and must come through unchanged.
+func TestFilterProfanityLeavesWordsAlone(t *testing.T) {
+	words := []string{
+		"cock", "cocks", "cocktail", "prick", "pricked", "pricks",
+		"damning", "damnation", "condemn", "Scunthorpe", "assessment",
+		"class", "passion", "compass", "shitake", "Dickens", "cockpit",
+		"therapist", "hello", "Essex", "pissarro",
+	}
+	for _, w := range words {
+		for _, mode := range ProfanityModes {
+			if got := filterProfanity(w, mode); got != w {
+				t.Errorf("filterProfanity(%q, %q) = %q, want it left alone", w, mode, got)
+			}
+		}
+	}
+}
+
+func TestCheckProfanity(t *testing.T) {
+	for _, mode := range []string{"", "mask", "remove"} {
+		if err := CheckProfanity(mode); err != nil {
+			t.Errorf("CheckProfanity(%q) = %v", mode, err)
+		}
+	}
+	if err := CheckProfanity("bleep"); err == nil {
+		t.Error("CheckProfanity(\"bleep\") = nil, want an error")
+	}
+}
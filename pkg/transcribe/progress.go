@@ -0,0 +1,146 @@
+package transcribe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress reports how far a conversion or upload has got.
+type Progress struct {
+	// Stage is "convert", counting seconds of audio written, or "upload",
+	// counting bytes sent.
+	Stage string
+	Done  float64
+	// Total is 0 when it isn't known, e.g. converting a file ffprobe
+	// couldn't read the duration of.
+	Total float64
+}
+
+// progress reports p to c.OnProgress, if set.
+func (c *Client) progress(stage string, done, total float64) {
+	if c.OnProgress != nil {
+		c.OnProgress(Progress{Stage: stage, Done: done, Total: total})
+	}
+}
+
+// waitingInterval is how often a request that hasn't answered yet is
+// reported in the log.
+const waitingInterval = 15 * time.Second
+
+// since returns the time elapsed since start, rounded for the log.
+func since(start time.Time) time.Duration {
+	d := time.Since(start)
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(100 * time.Millisecond)
+}
+
+// byteSize formats a byte count in KB or MB for the log.
+func byteSize(n int) string {
+	if n < 1e6 {
+		return fmt.Sprintf("%.0f KB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+}
+
+// throughput formats the rate of n bytes sent in d.
+func throughput(n int, d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f MB/s", float64(n)/1e6/d.Seconds())
+}
+
+// waiting logs "Still waiting for what" every waitingInterval from start
+// until the returned function is called, so long requests aren't silent.
+func (c *Client) waiting(what string, start time.Time) func() {
+	if c.Logf == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(waitingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.logf("Still waiting for %s (%s)...\n", what, since(start).Round(time.Second))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// countingReader reports the bytes read through it as upload progress.
+type countingReader struct {
+	r     io.Reader
+	c     *Client
+	n     int
+	total int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	r.c.progress("upload", float64(r.n), float64(r.total))
+	return n, err
+}
+
+// ffmpegProgress splits ffmpeg's stderr, run with -progress pipe:2, into
+// the key=value progress lines, reported as conversion progress, and
+// everything else, kept in log for error messages.
+type ffmpegProgress struct {
+	c     *Client
+	total float64
+	done  float64
+	log   bytes.Buffer
+	line  []byte
+}
+
+func (w *ffmpegProgress) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != '\n' {
+			w.line = append(w.line, b)
+			continue
+		}
+		w.handle(string(w.line))
+		w.line = w.line[:0]
+	}
+	return len(p), nil
+}
+
+// String returns the non-progress output, including an unfinished line.
+func (w *ffmpegProgress) String() string {
+	return w.log.String() + string(w.line)
+}
+
+func (w *ffmpegProgress) handle(line string) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok || strings.ContainsAny(key, " \t") || !isProgressKey(key) {
+		w.log.WriteString(line + "\n")
+		return
+	}
+	if key == "out_time_us" {
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+			w.done = float64(us) / 1e6
+			w.c.progress("convert", w.done, w.total)
+		}
+	}
+}
+
+// isProgressKey reports whether key is one ffmpeg's -progress writes.
+func isProgressKey(key string) bool {
+	switch key {
+	case "frame", "fps", "bitrate", "total_size", "out_time_us", "out_time_ms", "out_time",
+		"dup_frames", "drop_frames", "speed", "progress":
+		return true
+	}
+	return strings.HasPrefix(key, "stream_")
+}
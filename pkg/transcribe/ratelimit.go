@@ -0,0 +1,83 @@
+package transcribe
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter keeps API requests under a quota on the client side: at most
+// a set number a minute, from a token bucket that refills one request at a
+// time so they are spread evenly over the minute, and at most a set number
+// in flight at once. One limiter can be shared by several clients.
+type RateLimiter struct {
+	interval time.Duration
+	slots    chan struct{} // nil when concurrency isn't limited
+
+	mu   sync.Mutex
+	next time.Time // when the bucket next holds a token
+}
+
+// NewRateLimiter returns a limiter allowing rpm requests a minute and
+// concurrent requests at once; zero leaves either unlimited.
+func NewRateLimiter(rpm, concurrent int) *RateLimiter {
+	l := &RateLimiter{}
+	if rpm > 0 {
+		l.interval = time.Minute / time.Duration(rpm)
+	}
+	if concurrent > 0 {
+		l.slots = make(chan struct{}, concurrent)
+	}
+	return l
+}
+
+// wait blocks until a request may be sent and returns the wait and a func
+// to call once the request is done. A nil limiter never waits.
+func (l *RateLimiter) wait(ctx context.Context) (time.Duration, func(), error) {
+	if l == nil {
+		return 0, func() {}, nil
+	}
+	start := time.Now()
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-l.slots }) }
+	}
+
+	if l.interval > 0 {
+		l.mu.Lock()
+		at := time.Now()
+		if l.next.After(at) {
+			at = l.next
+		}
+		l.next = at.Add(l.interval)
+		l.mu.Unlock()
+		if d := time.Until(at); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				release()
+				return 0, nil, ctx.Err()
+			}
+		}
+	}
+	return time.Since(start), release, nil
+}
+
+// releaseBody frees a limiter slot when a response body is closed, so a
+// streamed response counts as in flight until it has been read.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releaseBody) Close() error {
+	b.release()
+	return b.ReadCloser.Close()
+}
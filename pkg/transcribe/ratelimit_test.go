@@ -0,0 +1,72 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRPM(t *testing.T) {
+	l := NewRateLimiter(1200, 0) // one every 50ms
+	start := time.Now()
+	for range 4 {
+		_, release, err := l.wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	// The first goes at once and the others 50ms apart
+	if d := time.Since(start); d < 150*time.Millisecond || d > 2*time.Second {
+		t.Errorf("4 requests took %s, want about 150ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 2 {
+		if _, _, err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("wait() with a canceled context = %v", err)
+		}
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	l := NewRateLimiter(0, 1)
+	_, release, err := l.wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second wait() with the slot taken = %v, want the deadline", err)
+	}
+
+	// Closing the response body frees the slot, once however often
+	body := releaseBody{io.NopCloser(strings.NewReader("")), release}
+	body.Close()
+	body.Close()
+	waited, release, err := l.wait(context.Background())
+	if err != nil || waited > time.Second {
+		t.Fatalf("wait() after release = %s, %v", waited, err)
+	}
+	release()
+	if len(l.slots) != 0 {
+		t.Errorf("%d slots still taken", len(l.slots))
+	}
+}
+
+func TestRateLimiterNil(t *testing.T) {
+	var l *RateLimiter
+	for _, l := range []*RateLimiter{l, NewRateLimiter(0, 0)} {
+		waited, release, err := l.wait(context.Background())
+		if err != nil || waited > 10*time.Millisecond {
+			t.Errorf("wait() = %s, %v; want no wait", waited, err)
+		}
+		release()
+	}
+}
@@ -0,0 +1,185 @@
+package transcribe
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RecordTransport is an http.RoundTripper that saves every response to
+// Dir, for ReplayTransport to answer the same requests with later, e.g. to
+// run scripts and tests without spending quota. Recordings are keyed by
+// the method, the URL path and query without the API key, and the request
+// body, so they replay against any base URL.
+type RecordTransport struct {
+	Dir string
+
+	// Next sends the requests; nil means http.DefaultTransport.
+	Next http.RoundTripper
+
+	seen recordingCounter
+}
+
+// ReplayTransport is an http.RoundTripper that answers requests with the
+// responses RecordTransport saved in Dir, without touching the network.
+// A request that wasn't recorded fails.
+type ReplayTransport struct {
+	Dir string
+
+	seen recordingCounter
+}
+
+// recording is one saved response. The body is kept as text when it is
+// valid UTF-8, so recordings of API responses can be read and edited.
+type recording struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"body_base64,omitempty"`
+}
+
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, body, err := t.seen.next(req)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	rec := recording{Method: req.Method, URL: redactedURL(req), Status: resp.StatusCode, Header: resp.Header}
+	if utf8.Valid(respBody) {
+		rec.Body = string(respBody)
+	} else {
+		rec.BodyBase64 = respBody
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(t.Dir, 0755); err == nil {
+			err = os.WriteFile(filepath.Join(t.Dir, name), append(data, '\n'), 0644)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recording response: %v", err)
+	}
+	return resp, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, _, err := t.seen.next(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(t.Dir, name))
+	if os.IsNotExist(err) {
+		// Fall back on the first recording of a request repeated more
+		// often than when it was recorded
+		first := strings.TrimSuffix(name, path.Ext(name))
+		first = first[:strings.LastIndex(first, "-")] + "-0.json"
+		data, err = os.ReadFile(filepath.Join(t.Dir, first))
+	}
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("no recording in %s for %s %s", t.Dir, req.Method, redactedURL(req))
+	}
+	var rec recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("reading recording %s: %v", name, err)
+	}
+	body := rec.BodyBase64
+	if body == nil {
+		body = []byte(rec.Body)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// recordingCounter names the recording of each request, numbering
+// identical requests (such as retries) in the order they are made.
+type recordingCounter struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+// next reads req's body and returns the file name of its recording along
+// with the body.
+func (c *recordingCounter) next(req *http.Request) (string, []byte, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	h := sha256.New()
+	u, _ := url.Parse(redactedURL(req))
+	fmt.Fprintf(h, "%s %s\n", req.Method, u.RequestURI())
+	h.Write(body)
+	key := hex.EncodeToString(h.Sum(nil))[:16]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = map[string]int{}
+	}
+	n := c.seen[key]
+	c.seen[key]++
+
+	// Name recordings after the endpoint, e.g. generateContent-<hash>-0.json
+	method := path.Base(req.URL.Path)
+	if i := strings.LastIndex(method, ":"); i >= 0 {
+		method = method[i+1:]
+	}
+	return strings.ToLower(req.Method) + "-" + method + "-" + key + "-" + strconv.Itoa(n) + ".json", body, nil
+}
+
+// redactedURL is req's URL without the API key.
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	q := u.Query()
+	if q.Has("key") {
+		q.Del("key")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,142 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeGemini answers generateContent with text, counting the calls.
+func fakeGemini(t *testing.T, text string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !strings.HasSuffix(r.URL.Path, ":generateContent") {
+			http.NotFound(w, r)
+			return
+		}
+		part, _ := json.Marshal(text)
+		fmt.Fprintf(w, `{"candidates": [{"content": {"parts": [{"text": %s}]}, "finishReason": "STOP"}]}`, part)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+	srv := fakeGemini(t, "Hello there.", &calls)
+	opts := Options{MimeType: "audio/mpeg"}
+
+	rec := NewClient("recording-key")
+	rec.BaseURL = srv.URL
+	rec.HTTPClient = &http.Client{Transport: &RecordTransport{Dir: dir}}
+	want, err := rec.Transcribe(context.Background(), strings.NewReader("audio"), opts)
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded %d files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if bytes.Contains(data, []byte("recording-key")) {
+		t.Errorf("recording holds the API key:\n%s", data)
+	}
+
+	// Replayed with another key and base URL, and no server to reach
+	srv.Close()
+	replay := NewClient("other-key")
+	replay.BaseURL = "http://127.0.0.1:1"
+	replay.HTTPClient = &http.Client{Transport: &ReplayTransport{Dir: dir}}
+	for i := range 2 {
+		got, err := replay.Transcribe(context.Background(), strings.NewReader("audio"), opts)
+		if err != nil {
+			t.Fatalf("replay %d: %v", i, err)
+		}
+		if got.Text != want.Text {
+			t.Errorf("replay %d = %q, want %q", i, got.Text, want.Text)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server called %d times, want 1", n)
+	}
+
+	replay.MaxRetries = 0
+	if _, err := replay.Transcribe(context.Background(), strings.NewReader("other audio"), opts); err == nil || !strings.Contains(err.Error(), "no recording") {
+		t.Errorf("unrecorded request: err = %v, want no recording", err)
+	}
+}
+
+func TestRecordTransportBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"text", []byte(`{"ok":true}`)},
+		{"binary", []byte{0xff, 0xfe, 0x00, 0x01}},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "yes")
+				w.WriteHeader(http.StatusTeapot)
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+			dir := t.TempDir()
+
+			send := func(rt http.RoundTripper, base string) *http.Response {
+				t.Helper()
+				req, _ := http.NewRequest("POST", base+"/v1beta/files?key=secret", strings.NewReader("request"))
+				resp, err := rt.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return resp
+			}
+			for _, resp := range []*http.Response{
+				send(&RecordTransport{Dir: dir}, srv.URL),
+				send(&ReplayTransport{Dir: dir}, "http://example.com"),
+			} {
+				body, _ := io.ReadAll(resp.Body)
+				if resp.StatusCode != http.StatusTeapot || resp.Header.Get("X-Test") != "yes" || !bytes.Equal(body, tt.body) {
+					t.Errorf("got %d %v %q, want 418 X-Test: yes %q", resp.StatusCode, resp.Header, body, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordingNames(t *testing.T) {
+	var c recordingCounter
+	name := func(method, url, body string) string {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		n, _, err := c.next(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	first := name("POST", "https://a.example/v1beta/models/m:generateContent?key=1", "x")
+	if !strings.HasPrefix(first, "post-generateContent-") || !strings.HasSuffix(first, "-0.json") {
+		t.Errorf("first name = %q", first)
+	}
+	// The same request with another key and host is a repeat
+	if again := name("POST", "http://b.example/v1beta/models/m:generateContent?key=2", "x"); again != strings.TrimSuffix(first, "0.json")+"1.json" {
+		t.Errorf("repeat name = %q, want it numbered 1 after %q", again, first)
+	}
+	if other := name("POST", "https://a.example/v1beta/models/m:generateContent?key=1", "y"); strings.HasSuffix(other, "-1.json") {
+		t.Errorf("another body's name = %q, want a new key", other)
+	}
+}
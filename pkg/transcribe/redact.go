@@ -0,0 +1,164 @@
+package transcribe
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RedactCategories are the kinds of personal information Options.Redact
+// can hide, each replaced by its placeholder.
+var RedactCategories = []string{"names", "emails", "phones", "addresses"}
+
+var redactPlaceholders = map[string]string{
+	"names":     "[NAME]",
+	"emails":    "[EMAIL]",
+	"phones":    "[PHONE]",
+	"addresses": "[ADDRESS]",
+}
+
+// redactPatterns are the safety net behind the prompt, catching what the
+// model let through. Names have no pattern; hiding them is up to the model.
+var redactPatterns = map[string]*regexp.Regexp{
+	// Written addresses, and spoken ones ("anna at example dot com")
+	"emails": regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}|\b[a-z0-9._-]+ at [a-z0-9-]+(?: dot [a-z]{2,})+\b`),
+	// Checked for the number of digits by phoneLike
+	"phones":    regexp.MustCompile(`\+?\(?\d[\d ().-]{5,}\d`),
+	"addresses": regexp.MustCompile(`\b\d{1,5} (?:[A-Z][a-z]+ ){1,3}(?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Way|Place|Pl|Terrace|Square)\b`),
+}
+
+// CheckRedact returns an error for a category not in RedactCategories.
+func CheckRedact(categories []string) error {
+	for _, c := range categories {
+		if !slices.Contains(RedactCategories, c) {
+			return fmt.Errorf("unknown redaction %q (want %s)", c, strings.Join(RedactCategories, ", "))
+		}
+	}
+	return nil
+}
+
+// redactInstruction asks the model to leave the categories out of the
+// transcript, putting placeholders in their place.
+func redactInstruction(categories []string) string {
+	var kinds []string
+	for _, c := range categories {
+		switch c {
+		case "names":
+			kinds = append(kinds, "people's names with [NAME] (label speakers Speaker 1, Speaker 2, ... rather than by name)")
+		case "emails":
+			kinds = append(kinds, "email addresses with [EMAIL]")
+		case "phones":
+			kinds = append(kinds, "phone numbers with [PHONE]")
+		case "addresses":
+			kinds = append(kinds, "postal and street addresses with [ADDRESS]")
+		}
+	}
+	return "For privacy, replace " + strings.Join(kinds, ", ") + ", everywhere in the output."
+}
+
+// redactText replaces what the patterns of categories match in s with
+// their placeholders.
+func redactText(s string, categories []string) string {
+	for _, c := range categories {
+		re := redactPatterns[c]
+		if re == nil {
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			if c == "phones" && !phoneLike(m) {
+				return m
+			}
+			return redactPlaceholders[c]
+		})
+	}
+	return s
+}
+
+// phoneLike reports whether a phones match has as many digits as a phone
+// number, rather than being a year or an amount. Groups split by spaces
+// alone ("1990 2000") need more of them.
+func phoneLike(s string) bool {
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	least := 7
+	if !strings.ContainsAny(s, "+()-.") {
+		least = 9
+	}
+	return digits >= least && digits <= 15
+}
+
+// redact applies the patterns of opts.Redact to every text in the result.
+// Timed words caught by a pattern are merged into one word holding the
+// placeholder, so the word list gives nothing away either.
+func redact(result Result, categories []string) Result {
+	if len(categories) == 0 {
+		return result
+	}
+	result = mapText(result, func(s string) string { return redactText(s, categories) })
+	for i := range result.Segments {
+		if words := result.Segments[i].Words; len(words) > 0 {
+			result.Segments[i].Words = redactWords(words, categories)
+		}
+	}
+	result.SourceText = redactText(result.SourceText, categories)
+	result.Summary = redactText(result.Summary, categories)
+	return result
+}
+
+// redactWords redacts a segment's timed words, matching the patterns
+// across word boundaries.
+func redactWords(words []Word, categories []string) []Word {
+	var b strings.Builder
+	starts := make([]int, len(words))
+	for i, w := range words {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		starts[i] = b.Len()
+		b.WriteString(w.Word)
+	}
+	text := b.String()
+	// The placeholder for each word a match starts in, and "" for the
+	// words it goes on into
+	replace := make([]*string, len(words))
+	for _, c := range categories {
+		re := redactPatterns[c]
+		if re == nil {
+			continue
+		}
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			if c == "phones" && !phoneLike(text[m[0]:m[1]]) {
+				continue
+			}
+			for i := range words {
+				end := starts[i] + len(words[i].Word)
+				if end <= m[0] || starts[i] >= m[1] || replace[i] != nil {
+					continue
+				}
+				p := ""
+				if i == 0 || replace[i-1] == nil || starts[i] <= m[0] {
+					p = redactPlaceholders[c]
+				}
+				replace[i] = &p
+			}
+		}
+	}
+	var out []Word
+	for i, w := range words {
+		switch {
+		case replace[i] == nil:
+			out = append(out, w)
+		case *replace[i] == "" && len(out) > 0:
+			out[len(out)-1].End = w.End
+		default:
+			w.Word = *replace[i]
+			out = append(out, w)
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package transcribe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactText(t *testing.T) {
+	all := RedactCategories
+	tests := []struct {
+		in, want string
+	}{
+		{"Mail anna.b@example.co.uk today.", "Mail [EMAIL] today."},
+		{"That's anna at example dot com.", "That's [EMAIL]."},
+		{"Call +44 (20) 7946-0958 or 555-0134 4455.", "Call [PHONE] or [PHONE]."},
+		{"Between 1990 and 2000 we sold 1,250,000 units.", "Between 1990 and 2000 we sold 1,250,000 units."},
+		{"Dial 020 7946 0958.", "Dial [PHONE]."},
+		{"Meet at 221 Baker Street at noon.", "Meet at [ADDRESS] at noon."},
+		{"Anna said hi.", "Anna said hi."},
+	}
+	for _, tt := range tests {
+		if got := redactText(tt.in, all); got != tt.want {
+			t.Errorf("redactText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := redactText("anna@example.com, 555-0134 4455", []string{"phones"}); got != "anna@example.com, [PHONE]" {
+		t.Errorf("phones only = %q", got)
+	}
+}
+
+func TestRedactWords(t *testing.T) {
+	words := []Word{
+		{"Call", 0, 1}, {"555-0134", 1, 2}, {"4455", 2, 3}, {"or", 3, 4},
+		{"anna", 4, 5}, {"at", 5, 6}, {"example", 6, 7}, {"dot", 7, 8}, {"com", 8, 9},
+	}
+	want := []Word{{"Call", 0, 1}, {"[PHONE]", 1, 3}, {"or", 3, 4}, {"[EMAIL]", 4, 9}}
+	if got := redactWords(words, RedactCategories); !reflect.DeepEqual(got, want) {
+		t.Errorf("redactWords() = %v, want %v", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	result := Result{
+		Segments: []Segment{
+			{Start: 0, End: 2, Text: "Write to bob@example.org.", Words: []Word{{"Write", 0, 0.5}, {"to", 0.5, 1}, {"bob@example.org.", 1, 2}}},
+			{Start: 2, End: 3, Text: "Thanks."},
+		},
+		Summary:    "Bob gave bob@example.org.",
+		SourceText: "Schreib an bob@example.org.",
+	}
+	got := redact(result, []string{"emails"})
+	if got.Text != "Write to [EMAIL]. Thanks." {
+		t.Errorf("Text = %q", got.Text)
+	}
+	if w := got.Segments[0].Words; len(w) != 3 || w[2].Word != "[EMAIL]" {
+		t.Errorf("Words = %v", w)
+	}
+	if got.Summary != "Bob gave [EMAIL]." || got.SourceText != "Schreib an [EMAIL]." {
+		t.Errorf("Summary = %q, SourceText = %q", got.Summary, got.SourceText)
+	}
+	if err := CheckRedact([]string{"emails", "ssn"}); err == nil {
+		t.Error("CheckRedact accepted an unknown category")
+	}
+}
@@ -0,0 +1,24 @@
+package transcribe
+
+import "context"
+
+// refineInstruction asks for a corrected copy of the draft, which follows
+// it, in the draft's own shape so it parses like a first answer.
+const refineInstruction = `Below is a draft transcription of this audio. Listen to the audio again and correct the draft: misheard or missing words, misspelled names and terms, and missing or wrong punctuation and capitalization. Keep everything that is already right, and answer with the complete corrected transcription in exactly the draft's format, not a list of changes.
+
+Draft:
+`
+
+// refineDraft sends the audio a second time with the draft transcript and
+// returns the model's corrected version, for Options.Refine. The prompt's
+// instructions (vocabulary, language, timed output) still apply, so the
+// answer comes in the same shape as the draft.
+func (c *Client) refineDraft(ctx context.Context, audioData []byte, mimeType string, upload bool, draft string, opts Options) (string, error) {
+	c.logf("Refining the draft transcript...\n")
+	p := opts.Prompt
+	if p == "" {
+		p = DefaultPrompt
+	}
+	opts.Prompt = p + "\n\n" + refineInstruction + draft
+	return c.send(ctx, audioData, mimeType, upload, opts)
+}
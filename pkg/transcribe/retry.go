@@ -0,0 +1,154 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryDelay = 2 * time.Second
+	maxRetryDelay     = 60 * time.Second
+)
+
+// retryableStatus reports whether an HTTP status is a transient failure
+// worth repeating: rate limiting and server-side errors.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoffDelay returns the wait before retry number attempt (0-based):
+// base doubled per attempt, capped at maxRetryDelay, with the upper half
+// randomised so parallel workers don't retry in lockstep.
+func backoffDelay(attempt int, base time.Duration) time.Duration {
+	d := base << attempt
+	if d <= 0 || d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// doWithRetry sends a request with body to url, repeating on network
+// errors and retryable statuses up to c.MaxRetries times, each attempt
+// waiting its turn with c.Limiter and taking the next key from c.Keys.
+// The final response is returned with its body unread, whatever its status.
+func (c *Client) doWithRetry(ctx context.Context, method, url, contentType string, body payload) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if err := c.authorize(req); err != nil {
+			return nil, err
+		}
+		if n := body.size(); n > 0 {
+			req.ContentLength = n
+			req.Body = body.open()
+			req.GetBody = func() (io.ReadCloser, error) { return body.open(), nil }
+		}
+
+		waited, release, err := c.Limiter.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if waited >= time.Second {
+			c.logf("Waited %s for the request rate limit\n", waited.Round(time.Millisecond))
+		}
+		keyIndex := -1
+		if c.Keys != nil && !c.vertex() {
+			var key string
+			keyIndex, key = c.Keys.pick()
+			q := req.URL.Query()
+			q.Set("key", key)
+			req.URL.RawQuery = q.Encode()
+		}
+		c.Stats.recordRequest(int(body.size()))
+		resp, err := c.httpClient().Do(req)
+
+		wait := backoffDelay(attempt, c.RetryDelay)
+		var reason string
+		switch {
+		case err != nil:
+			reason = err.Error()
+		case retryableStatus(resp.StatusCode):
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = d
+			}
+			// Rest the rate-limited key and carry on with another one
+			if keyIndex >= 0 && resp.StatusCode == http.StatusTooManyRequests {
+				c.Keys.rest(keyIndex, wait)
+				wait = c.Keys.wait()
+				reason = fmt.Sprintf("HTTP %d on API key %d of %d", resp.StatusCode, keyIndex+1, c.Keys.Len())
+			}
+		default:
+			resp.Body = releaseBody{resp.Body, release}
+			return resp, nil
+		}
+
+		if attempt >= c.MaxRetries || ctx.Err() != nil {
+			if resp != nil {
+				resp.Body = releaseBody{resp.Body, release}
+			} else {
+				release()
+			}
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		release()
+
+		c.Stats.recordRetry()
+		c.logf("Request failed (%s), retrying in %s (%d/%d)...\n",
+			reason, wait.Round(time.Millisecond), attempt+1, c.MaxRetries)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// postWithRetry is doWithRetry for callers that want the whole body.
+func (c *Client) postWithRetry(ctx context.Context, url, contentType string, body payload) (int, []byte, error) {
+	resp, err := c.doWithRetry(ctx, "POST", url, contentType, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
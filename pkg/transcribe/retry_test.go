@@ -0,0 +1,114 @@
+package transcribe
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		base     time.Duration
+		min, max time.Duration
+	}{
+		{0, time.Second, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second, 4 * time.Second},
+		{10, time.Second, maxRetryDelay / 2, maxRetryDelay},
+		{100, time.Second, maxRetryDelay / 2, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := backoffDelay(tt.attempt, tt.base); d < tt.min || d > tt.max {
+				t.Errorf("backoffDelay(%d, %s) = %s, want %s to %s", tt.attempt, tt.base, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		h    string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.h)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.h, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(an hour from now) = %s, %v", got, ok)
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		want      int
+		wantCalls int32
+	}{
+		{"success", []int{200}, 200, 1},
+		{"retried until it works", []int{503, 429, 200}, 200, 3},
+		{"client errors aren't retried", []int{400, 200}, 400, 1},
+		{"gives up after the retries", []int{500, 502, 503, 504, 200}, 504, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				if body, _ := io.ReadAll(r.Body); string(body) != `{"a":1}` {
+					t.Errorf("attempt %d sent %q", n, body)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+				io.WriteString(w, "body")
+			}))
+			defer srv.Close()
+
+			c := &Client{MaxRetries: 3, RetryDelay: time.Millisecond, Stats: NewStats()}
+			status, body, err := c.postWithRetry(context.Background(), srv.URL, "application/json", payload{json: [][]byte{[]byte(`{"a":1}`)}})
+			if err != nil || status != tt.want || string(body) != "body" {
+				t.Fatalf("postWithRetry() = %d, %q, %v; want %d", status, body, err, tt.want)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("server called %d times, want %d", n, tt.wantCalls)
+			}
+			if s := c.Stats.Snapshot(); s.Requests != int(tt.wantCalls) || s.Retries != int(tt.wantCalls)-1 {
+				t.Errorf("stats = %d requests, %d retries", s.Requests, s.Retries)
+			}
+		})
+	}
+}
+
+func TestDoWithRetryCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := &Client{MaxRetries: 3, RetryDelay: time.Millisecond}
+	start := time.Now()
+	if _, _, err := c.postWithRetry(ctx, srv.URL, "", payload{}); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want the deadline", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("waited %s for a Retry-After past the deadline", d)
+	}
+}
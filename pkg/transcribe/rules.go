@@ -0,0 +1,38 @@
+package transcribe
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rule is a find/replace fix applied to the transcript before output, for
+// mis-transcriptions that keep coming back. Find is matched literally;
+// when Pattern is set it is used instead and Replace may refer to its
+// groups ($1, ${name}).
+type Rule struct {
+	Find    string
+	Pattern *regexp.Regexp
+	Replace string
+}
+
+// ApplyRules applies rules to text in order, each to the previous one's
+// output.
+func ApplyRules(text string, rules []Rule) string {
+	for _, r := range rules {
+		switch {
+		case r.Pattern != nil:
+			text = r.Pattern.ReplaceAllString(text, r.Replace)
+		case r.Find != "":
+			text = strings.ReplaceAll(text, r.Find, r.Replace)
+		}
+	}
+	return text
+}
+
+// applyRules applies rules to the transcript and its segments.
+func applyRules(result Result, rules []Rule) Result {
+	if len(rules) == 0 {
+		return result
+	}
+	return mapText(result, func(s string) string { return ApplyRules(s, rules) })
+}
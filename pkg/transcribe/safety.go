@@ -0,0 +1,105 @@
+package transcribe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SafetySetting sets the blocking threshold for one harm category, sent in
+// the request's safetySettings.
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// harmCategories are the categories the Gemini API lets callers adjust.
+var harmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+}
+
+// SafetyLevels lists the names SafetyPreset accepts.
+var SafetyLevels = []string{"none", "default", "strict"}
+
+// SafetyPreset returns the settings for a named level: "none" turns
+// blocking off for every category, "strict" blocks anything with a low or
+// higher probability of harm, and "default" (or "") sends nothing so the
+// API's defaults apply.
+func SafetyPreset(level string) ([]SafetySetting, error) {
+	var threshold string
+	switch level {
+	case "", "default":
+		return nil, nil
+	case "none":
+		threshold = "BLOCK_NONE"
+	case "strict":
+		threshold = "BLOCK_LOW_AND_ABOVE"
+	default:
+		return nil, fmt.Errorf("unknown safety level %q (want none, default or strict)", level)
+	}
+	settings := make([]SafetySetting, len(harmCategories))
+	for i, category := range harmCategories {
+		settings[i] = SafetySetting{Category: category, Threshold: threshold}
+	}
+	return settings, nil
+}
+
+// SafetyRating is the API's assessment of one harm category for a prompt
+// or response.
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// PromptFeedback explains why the API refused a request outright.
+type PromptFeedback struct {
+	BlockReason   string         `json:"blockReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// blockedFinishReasons are finish reasons that mean the response was
+// withheld rather than completed.
+var blockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// BlockedError is returned when the API blocks the prompt or withholds the
+// response, with the reason and the ratings that triggered it.
+type BlockedError struct {
+	Reason  string         `json:"reason"`
+	Ratings []SafetyRating `json:"safety_ratings,omitempty"`
+}
+
+func (e *BlockedError) Error() string {
+	var flagged []string
+	for _, r := range e.Ratings {
+		if r.Blocked || r.Probability == "MEDIUM" || r.Probability == "HIGH" {
+			flagged = append(flagged, fmt.Sprintf("%s, probability %s",
+				strings.TrimPrefix(r.Category, "HARM_CATEGORY_"), r.Probability))
+		}
+	}
+	if len(flagged) == 0 {
+		return "blocked: " + e.Reason
+	}
+	return "blocked: " + strings.Join(flagged, "; ")
+}
+
+// blocked returns a BlockedError when the response was refused, or nil.
+func (r *GeminiResponse) blocked() *BlockedError {
+	if f := r.PromptFeedback; f != nil && f.BlockReason != "" {
+		return &BlockedError{Reason: f.BlockReason, Ratings: f.SafetyRatings}
+	}
+	if len(r.Candidates) > 0 && blockedFinishReasons[r.Candidates[0].FinishReason] {
+		cand := r.Candidates[0]
+		return &BlockedError{Reason: cand.FinishReason, Ratings: cand.SafetyRatings}
+	}
+	return nil
+}
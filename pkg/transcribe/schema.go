@@ -0,0 +1,188 @@
+package transcribe
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Schema is the subset of OpenAPI schemas Gemini accepts as a
+// responseSchema.
+type Schema struct {
+	Type             string             `json:"type"`
+	Description      string             `json:"description,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+	PropertyOrdering []string           `json:"propertyOrdering,omitempty"`
+}
+
+// structuredInstruction replaces segmentInstruction and wordInstruction
+// when the response is constrained by segmentSchema; the schema fixes the
+// shape, so only the content is described.
+func structuredInstruction(words, language, topics bool) string {
+	s := "Split the transcription into short subtitle segments of at most two sentences. For each, give start and end in seconds from the start of the audio, the speaker (their name once introduced, otherwise Speaker 1, Speaker 2, ...) and the text."
+	if words {
+		s += " List every spoken word in words with its own start and end time."
+	}
+	if topics {
+		s += " Give each segment the topic under discussion as a short title, repeating the same title for consecutive segments on the same topic."
+	}
+	if language {
+		s += " Set language to the ISO 639-1 code of the main spoken language."
+	}
+	return s
+}
+
+// structuredContinuePrompt asks for the rest of a response cut off under
+// segmentSchema. The answer is a JSON object of its own, so it's asked
+// for the segments that follow rather than the rest of the text.
+const structuredContinuePrompt = "Your previous response was cut off. Answer with the segments that come after the last complete one in it, starting where that one ended, without repeating any."
+
+// segmentSchema describes the JSON answer for timed output: the segments
+// (with their words when words is set and a topic title when topics is)
+// and, when language is set, the spoken language.
+func segmentSchema(words, language, topics bool) *Schema {
+	seconds := &Schema{Type: "NUMBER", Description: "Seconds from the start of the audio"}
+	segment := &Schema{
+		Type: "OBJECT",
+		Properties: map[string]*Schema{
+			"start":   seconds,
+			"end":     seconds,
+			"speaker": {Type: "STRING"},
+			"text":    {Type: "STRING"},
+		},
+		Required:         []string{"start", "end", "text"},
+		PropertyOrdering: []string{"start", "end", "speaker", "text"},
+	}
+	if words {
+		segment.Properties["words"] = &Schema{Type: "ARRAY", Items: &Schema{
+			Type: "OBJECT",
+			Properties: map[string]*Schema{
+				"word":  {Type: "STRING"},
+				"start": seconds,
+				"end":   seconds,
+			},
+			Required:         []string{"word", "start", "end"},
+			PropertyOrdering: []string{"word", "start", "end"},
+		}}
+		segment.Required = append(segment.Required, "words")
+		segment.PropertyOrdering = append(segment.PropertyOrdering, "words")
+	}
+	if topics {
+		segment.Properties["topic"] = &Schema{Type: "STRING", Description: "Short title of the topic under discussion"}
+		segment.Required = append(segment.Required, "topic")
+		segment.PropertyOrdering = append([]string{"topic"}, segment.PropertyOrdering...)
+	}
+
+	s := &Schema{
+		Type:             "OBJECT",
+		Properties:       map[string]*Schema{"segments": {Type: "ARRAY", Items: segment}},
+		Required:         []string{"segments"},
+		PropertyOrdering: []string{"segments"},
+	}
+	if language {
+		s.Properties["language"] = &Schema{Type: "STRING", Description: "ISO 639-1 code"}
+		s.Required = append(s.Required, "language")
+		s.PropertyOrdering = []string{"language", "segments"}
+	}
+	return s
+}
+
+// structuredConfig returns opts' generation settings with the response
+// constrained to segmentSchema.
+func (o Options) structuredConfig() *GenerationConfig {
+	gen := GenerationConfig{}
+	if o.GenerationConfig != nil {
+		gen = *o.GenerationConfig
+	}
+	gen.ResponseMIMEType = "application/json"
+	gen.ResponseSchema = segmentSchema(o.Words, o.DetectLanguage, o.Topics)
+	return &gen
+}
+
+// parseStructured decodes a response constrained by segmentSchema into
+// segments and the reported language.
+func parseStructured(text string) ([]Segment, string, error) {
+	var resp struct {
+		Language string       `json:"language"`
+		Segments []rawSegment `json:"segments"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &resp); err != nil {
+		return nil, "", fmt.Errorf("invalid segment JSON: %v", err)
+	}
+	if len(resp.Segments) == 0 {
+		return nil, "", fmt.Errorf("no segments in response")
+	}
+	return convertSegments(resp.Segments), strings.ToLower(resp.Language), nil
+}
+
+// mergeStructured joins the responses of a structured answer that was
+// continued after hitting the token limit into one. The complete segments
+// of each are kept, up to where a response was cut off, and segments
+// starting no later than the last one kept are taken for repeats.
+func mergeStructured(pieces []string) string {
+	var merged struct {
+		Language string       `json:"language,omitempty"`
+		Segments []rawSegment `json:"segments"`
+	}
+	last := math.Inf(-1)
+	for _, p := range pieces {
+		segments, lang := salvageStructured(p)
+		if merged.Language == "" {
+			merged.Language = lang
+		}
+		for _, seg := range segments {
+			if float64(seg.Start) <= last {
+				continue
+			}
+			merged.Segments = append(merged.Segments, seg)
+			last = float64(seg.Start)
+		}
+	}
+	out, _ := json.Marshal(merged)
+	return string(out)
+}
+
+// salvageStructured decodes the language and the complete segments of a
+// response constrained by segmentSchema, which may be cut off part way.
+func salvageStructured(text string) (segments []rawSegment, language string) {
+	dec := json.NewDecoder(strings.NewReader(strings.TrimSpace(text)))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, ""
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		key, ok := t.(string)
+		if err != nil || !ok {
+			break
+		}
+		switch key {
+		case "language":
+			if dec.Decode(&language) != nil {
+				return segments, language
+			}
+		case "segments":
+			if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+				return segments, language
+			}
+			for dec.More() {
+				var seg rawSegment
+				if dec.Decode(&seg) != nil {
+					return segments, language
+				}
+				segments = append(segments, seg)
+			}
+			if _, err := dec.Token(); err != nil {
+				return segments, language
+			}
+		default:
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				return segments, language
+			}
+		}
+	}
+	return segments, language
+}
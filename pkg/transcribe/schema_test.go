@@ -0,0 +1,102 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeStructured(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   string
+	}{
+		{
+			"cut off in a segment",
+			[]string{
+				`{"language": "en", "segments": [{"start": 0, "end": 2, "text": "One."}, {"start": 2, "end": 4, "te`,
+				`{"language": "en", "segments": [{"start": 2, "end": 4, "text": "Two."}]}`,
+			},
+			`{"language":"en","segments":[{"start":0,"end":2,"speaker":"","topic":"","text":"One.","words":null},{"start":2,"end":4,"speaker":"","topic":"","text":"Two.","words":null}]}`,
+		},
+		{
+			"repeats dropped",
+			[]string{
+				`{"segments": [{"start": 0, "end": 2, "text": "One."}, {"start": 2, "end": 4, "text": "Two."}`,
+				`{"segments": [{"start": 2, "end": 4, "text": "Two."}, {"start": 4, "end": 5, "text": "Three."}]}`,
+			},
+			`{"segments":[{"start":0,"end":2,"speaker":"","topic":"","text":"One.","words":null},{"start":2,"end":4,"speaker":"","topic":"","text":"Two.","words":null},{"start":4,"end":5,"speaker":"","topic":"","text":"Three.","words":null}]}`,
+		},
+		{
+			"cut off before any segment",
+			[]string{`{"language": "de", "segm`},
+			`{"language":"de","segments":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeStructured(tt.pieces); got != tt.want {
+				t.Errorf("mergeStructured() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStructuredContinuation records a structured answer cut off at the
+// token limit and its continuation, and replays them.
+func TestStructuredContinuation(t *testing.T) {
+	responses := []struct{ text, finish string }{
+		{`{"segments": [{"start": 0, "end": 2, "text": "One."}, {"start": 2, "end": 4, "text": "Tw`, "MAX_TOKENS"},
+		{`{"segments": [{"start": 2, "end": 4, "text": "Two."}, {"start": 4, "end": 6, "text": "Three."}]}`, "STOP"},
+	}
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		resp := responses[len(requests)-1]
+		text, _ := json.Marshal(resp.text)
+		fmt.Fprintf(w, `{"candidates": [{"content": {"parts": [{"text": %s}]}, "finishReason": %q}]}`, text, resp.finish)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	opts := Options{MimeType: "audio/mpeg", Timestamps: true, MaxContinuations: 2}
+	want := []Segment{{Start: 0, End: 2, Text: "One."}, {Start: 2, End: 4, Text: "Two."}, {Start: 4, End: 6, Text: "Three."}}
+	for _, transport := range []http.RoundTripper{&RecordTransport{Dir: dir}, &ReplayTransport{Dir: dir}} {
+		c := NewClient("k")
+		c.BaseURL = srv.URL
+		c.HTTPClient = &http.Client{Transport: transport}
+		result, err := c.Transcribe(context.Background(), strings.NewReader("audio"), opts)
+		if err != nil {
+			t.Fatalf("%T: %v", transport, err)
+		}
+		if !reflect.DeepEqual(result.Segments, want) {
+			t.Errorf("%T: segments = %+v, want %+v", transport, result.Segments, want)
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(requests))
+	}
+	var second struct {
+		Contents         []Content `json:"contents"`
+		GenerationConfig struct {
+			ResponseSchema *Schema `json:"responseSchema"`
+		} `json:"generationConfig"`
+	}
+	if err := json.Unmarshal([]byte(requests[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(second.Contents); n != 3 || second.Contents[1].Parts[0].Text != responses[0].text || second.Contents[2].Parts[0].Text != structuredContinuePrompt {
+		t.Errorf("continuation contents = %+v", second.Contents)
+	}
+	if second.GenerationConfig.ResponseSchema == nil {
+		t.Error("continuation sent without the response schema")
+	}
+}
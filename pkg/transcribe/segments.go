@@ -0,0 +1,215 @@
+package transcribe
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Segment is one timed span of the transcript. Times are in seconds from
+// the start of the audio.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+	Words []Word  `json:"words,omitempty"`
+
+	// Speaker labels who is talking, when the response says.
+	Speaker string `json:"speaker,omitempty"`
+
+	// Topic is the topic under discussion, with Options.Topics.
+	Topic string `json:"topic,omitempty"`
+}
+
+// Word is a single timed word within a segment.
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// segmentInstruction is appended to the prompt whenever timed output is
+// needed, so custom prompts keep working with subtitle formats.
+const segmentInstruction = `Split the transcription into short subtitle segments of at most two sentences. Output one segment per line, formatted exactly as:
+[HH:MM:SS.mmm --> HH:MM:SS.mmm] text
+Timestamps are measured from the start of the audio. Output only these lines.`
+
+func segmentPrompt(prompt string) string {
+	return prompt + "\n\n" + segmentInstruction
+}
+
+// wordInstruction replaces segmentInstruction when word-level timing is
+// requested; line-based output can't carry per-word times, so ask for JSON.
+const wordInstruction = `Return the transcription as JSON only, with no surrounding text: an array of segments of at most two sentences, each shaped as
+{"start": 0.0, "end": 0.0, "text": "...", "words": [{"word": "...", "start": 0.0, "end": 0.0}]}
+Times are in seconds from the start of the audio. Every spoken word must appear in "words" with its own start and end time.`
+
+func wordPrompt(prompt string) string {
+	return prompt + "\n\n" + wordInstruction
+}
+
+// languageHint tells the model which language to expect.
+func languageHint(lang string) string {
+	return fmt.Sprintf("The audio is in language %q.", lang)
+}
+
+// languageInstruction asks for the spoken language ahead of the transcript;
+// splitLanguage takes that line back off.
+const languageInstruction = `Start your answer with a single line "Language: <code>" giving the ISO 639-1 code of the main spoken language, then continue as instructed.`
+
+var languageLineRe = regexp.MustCompile(`(?i)^\s*language:\s*([a-z]{2,3}(?:-[a-z0-9]+)?)\s*(?:\n|$)`)
+
+// splitLanguage returns the language code reported on the first line of
+// text, if any, and the text without that line.
+func splitLanguage(text string) (string, string) {
+	m := languageLineRe.FindStringSubmatchIndex(text)
+	if m == nil {
+		return "", text
+	}
+	return strings.ToLower(text[m[2]:m[3]]), strings.TrimSpace(text[m[1]:])
+}
+
+var segmentLineRe = regexp.MustCompile(`^\[?\s*([\d:.,]+)\s*-->\s*([\d:.,]+)\s*\]?\s*(.*)$`)
+
+// parseSegments extracts timed segments from the model's text output. Lines
+// without a timestamp are treated as a continuation of the previous segment.
+func parseSegments(text string) ([]Segment, error) {
+	var segments []Segment
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		m := segmentLineRe.FindStringSubmatch(line)
+		if m == nil {
+			if len(segments) > 0 {
+				last := &segments[len(segments)-1]
+				last.Text = strings.TrimSpace(last.Text + " " + line)
+			}
+			continue
+		}
+		start, err := ParseTimestamp(m[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := ParseTimestamp(m[2])
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			end = start
+		}
+		segments = append(segments, Segment{Start: start, End: end, Text: strings.TrimSpace(m[3])})
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no timestamped segments in response")
+	}
+	return segments, nil
+}
+
+// JoinSegments rebuilds the plain transcript from timed segments.
+func JoinSegments(segments []Segment) string {
+	texts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg.Text != "" {
+			texts = append(texts, seg.Text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// ParseTimestamp converts HH:MM:SS.mmm, MM:SS.mmm or SS.mmm, with either a
+// dot or a comma before the milliseconds, to seconds.
+func ParseTimestamp(ts string) (float64, error) {
+	fields := strings.Split(strings.Replace(ts, ",", ".", 1), ":")
+	if len(fields) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	var seconds float64
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+// flexSeconds decodes a time given either as a number of seconds or as a
+// timestamp string, since models don't always honour the requested shape.
+type flexSeconds float64
+
+func (f *flexSeconds) UnmarshalJSON(data []byte) error {
+	var n float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*f = flexSeconds(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid time %s", data)
+	}
+	v, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*f = flexSeconds(v)
+	return nil
+}
+
+type rawSegment struct {
+	Start   flexSeconds `json:"start"`
+	End     flexSeconds `json:"end"`
+	Speaker string      `json:"speaker"`
+	Topic   string      `json:"topic"`
+	Text    string      `json:"text"`
+	Words   []struct {
+		Word  string      `json:"word"`
+		Start flexSeconds `json:"start"`
+		End   flexSeconds `json:"end"`
+	} `json:"words"`
+}
+
+// parseWordSegments decodes the JSON segment list requested by wordPrompt.
+// Markdown code fences and a {"segments": [...]} wrapper are tolerated.
+func parseWordSegments(text string) ([]Segment, error) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	var raw []rawSegment
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		var wrapped struct {
+			Segments []rawSegment `json:"segments"`
+		}
+		if err2 := json.Unmarshal([]byte(text), &wrapped); err2 != nil {
+			return nil, fmt.Errorf("invalid segment JSON: %v", err)
+		}
+		raw = wrapped.Segments
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no segments in response")
+	}
+	return convertSegments(raw), nil
+}
+
+// convertSegments turns decoded JSON segments into Segments.
+func convertSegments(raw []rawSegment) []Segment {
+	segments := make([]Segment, 0, len(raw))
+	for _, r := range raw {
+		seg := Segment{Start: float64(r.Start), End: float64(r.End), Text: strings.TrimSpace(r.Text), Speaker: strings.TrimSpace(r.Speaker), Topic: strings.TrimSpace(r.Topic)}
+		for _, w := range r.Words {
+			seg.Words = append(seg.Words, Word{Word: w.Word, Start: float64(w.Start), End: float64(w.End)})
+		}
+		if seg.End < seg.Start {
+			seg.End = seg.Start
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
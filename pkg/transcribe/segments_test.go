@@ -0,0 +1,145 @@
+package transcribe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"00:01:02.500", 62.5, true},
+		{"01:00:00,250", 3600.25, true},
+		{"2:03.5", 123.5, true},
+		{"7.25", 7.25, true},
+		{"1:2:3:4", 0, false},
+		{"00:xx:01", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Segment
+		ok   bool
+	}{
+		{
+			"bracketed lines",
+			"[00:00:00.000 --> 00:00:02.500] Hello there.\n[00:00:02.500 --> 00:00:04.000] General Kenobi.",
+			[]Segment{{Start: 0, End: 2.5, Text: "Hello there."}, {Start: 2.5, End: 4, Text: "General Kenobi."}},
+			true,
+		},
+		{
+			"fences, commas and continuation lines",
+			"```\n00:00:01,000 --> 00:00:03,000 First line\nand its second half\n```",
+			[]Segment{{Start: 1, End: 3, Text: "First line and its second half"}},
+			true,
+		},
+		{
+			"end before start",
+			"[00:00:05.000 --> 00:00:04.000] Backwards",
+			[]Segment{{Start: 5, End: 5, Text: "Backwards"}},
+			true,
+		},
+		{"no timestamps", "Just some text.", nil, false},
+		{"bad timestamp", "[1:2:3:4 --> 00:00:01.000] x", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSegments(tt.text)
+			if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSegments() = %+v, %v; want %+v, ok %v", got, err, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseWordSegments(t *testing.T) {
+	want := []Segment{{Start: 1, End: 2, Text: "Hi there", Words: []Word{{"Hi", 1, 1.4}, {"there", 1.5, 2}}}}
+	tests := []struct {
+		name string
+		text string
+		want []Segment
+		ok   bool
+	}{
+		{
+			"array",
+			`[{"start": 1, "end": 2, "text": " Hi there ", "words": [{"word": "Hi", "start": 1, "end": 1.4}, {"word": "there", "start": 1.5, "end": 2}]}]`,
+			want,
+			true,
+		},
+		{
+			"fenced wrapper with timestamp strings",
+			"```json\n" + `{"segments": [{"start": "00:00:01.000", "end": "0:02", "text": "Hi there", "words": [{"word": "Hi", "start": 1, "end": "1.4"}, {"word": "there", "start": 1.5, "end": 2}]}]}` + "\n```",
+			want,
+			true,
+		},
+		{"empty", "[]", nil, false},
+		{"not JSON", "hello", nil, false},
+		{"bad time", `[{"start": "soon", "end": 2, "text": "x"}]`, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWordSegments(tt.text)
+			if (err == nil) != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWordSegments() = %+v, %v; want %+v, ok %v", got, err, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseStructured(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		want     []Segment
+		wantLang string
+		ok       bool
+	}{
+		{
+			"speakers and topics",
+			`{"language": "EN", "segments": [{"start": 0, "end": 3, "text": "Welcome.", "speaker": " Anna ", "topic": "Intro"}]}`,
+			[]Segment{{Start: 0, End: 3, Text: "Welcome.", Speaker: "Anna", Topic: "Intro"}},
+			"en",
+			true,
+		},
+		{"no segments", `{"language": "en", "segments": []}`, nil, "", false},
+		{"truncated", `{"segments": [{"start": 0`, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, lang, err := parseStructured(tt.text)
+			if (err == nil) != tt.ok || lang != tt.wantLang || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStructured() = %+v, %q, %v; want %+v, %q, ok %v", got, lang, err, tt.want, tt.wantLang, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSplitLanguage(t *testing.T) {
+	tests := []struct {
+		text, lang, rest string
+	}{
+		{"Language: DE\nHallo zusammen.", "de", "Hallo zusammen."},
+		{"language: pt-br\n\nOlá.", "pt-br", "Olá."},
+		{"Hello, no language line.", "", "Hello, no language line."},
+		{"The language: en is spoken.", "", "The language: en is spoken."},
+	}
+	for _, tt := range tests {
+		lang, rest := splitLanguage(tt.text)
+		if lang != tt.lang || rest != tt.rest {
+			t.Errorf("splitLanguage(%q) = %q, %q; want %q, %q", tt.text, lang, rest, tt.lang, tt.rest)
+		}
+	}
+}
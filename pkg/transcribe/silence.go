@@ -0,0 +1,141 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+)
+
+// SilenceThresholdDB is the peak level (in dBFS) at or below which a file is
+// treated as silent. Digital silence reports around -91 dB; quiet room tone
+// sits well under -50.
+const SilenceThresholdDB = -50.0
+
+// QuietThresholdDB is the mean (RMS) level at or below which audio that
+// isn't silent, such as hum with the odd click, is near silent: there's
+// little or no speech, and a transcript of it is probably made up.
+const QuietThresholdDB = -60.0
+
+var (
+	maxVolumeRe  = regexp.MustCompile(`max_volume:\s*(-?[\d.]+|-inf) dB`)
+	meanVolumeRe = regexp.MustCompile(`mean_volume:\s*(-?[\d.]+|-inf) dB`)
+)
+
+// levels returns the input's peak and mean levels, as measureLevel does.
+// They are kept in c.Cache, keyed by the file's contents and the slice,
+// track and channel opts select, so a file transcribed before isn't
+// decoded again just to find its transcript in the cache.
+func (c *Client) levels(ctx context.Context, inputFile string, opts Options) (peak, mean float64, ok bool) {
+	key := ""
+	if c.Cache != nil {
+		key, _ = levelKey(inputFile, opts)
+	}
+	if key != "" {
+		if text, hit := c.Cache.get(key); hit {
+			if peak, mean, ok = parseLevels(text); ok {
+				return peak, mean, true
+			}
+		}
+	}
+	peak, mean, ok = c.measureLevel(ctx, inputFile, opts)
+	if ok && key != "" {
+		c.Cache.put(key, strconv.FormatFloat(peak, 'g', -1, 64)+" "+strconv.FormatFloat(mean, 'g', -1, 64))
+	}
+	return peak, mean, ok
+}
+
+// levelKey hashes a file's contents and the part of it opts measures.
+func levelKey(inputFile string, opts Options) (string, error) {
+	f, err := os.Open(inputFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	fmt.Fprintf(h, "levels\x00%d\x00%d\x00%d\x00%s\x00", opts.Start, opts.End, opts.AudioTrack, opts.Channel)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "levels-" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseLevels reads the "peak mean" levels levels caches.
+func parseLevels(text string) (peak, mean float64, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	peak, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	mean, err = strconv.ParseFloat(fields[1], 64)
+	return peak, mean, err == nil
+}
+
+// measureLevel runs ffmpeg's volumedetect filter over the input (the
+// audio track, channel and Start to End slice opts select) and returns
+// its peak and mean levels in dBFS. ok is false when the check could not
+// be performed (no ffmpeg, no audio stream, unparsable output), in which
+// case callers should proceed as if the file contained speech.
+func (c *Client) measureLevel(ctx context.Context, inputFile string, opts Options) (peak, mean float64, ok bool) {
+	if _, err := exec.LookPath(c.ffmpeg()); err != nil {
+		return 0, 0, false
+	}
+
+	args := []string{"-hide_banner", "-nostats"}
+	if opts.Start > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(opts.Start.Seconds(), 'f', 3, 64))
+	}
+	if opts.End > 0 {
+		args = append(args, "-t", strconv.FormatFloat((opts.End-opts.Start).Seconds(), 'f', 3, 64))
+	}
+	args = append(args, "-i", inputFile)
+	args = append(args, opts.trackArgs()...)
+	filter := "volumedetect"
+	if pan, _ := channelFilter(opts.Channel); pan != "" {
+		filter = pan + "," + filter
+	}
+	args = append(args, "-vn", "-af", filter, "-f", "null", "-")
+	cmd := proc.Command(ctx, c.ffmpeg(), args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, 0, false
+	}
+
+	peak, ok = parseLevel(maxVolumeRe, stderr.Bytes())
+	if !ok {
+		return 0, 0, false
+	}
+	// Older ffmpeg builds may not report the mean; count it as loud enough
+	if mean, ok = parseLevel(meanVolumeRe, stderr.Bytes()); !ok {
+		mean = 0
+	}
+	return peak, mean, true
+}
+
+// parseLevel finds a volumedetect level in out, "-inf" for digital silence.
+func parseLevel(re *regexp.Regexp, out []byte) (float64, bool) {
+	m := re.FindSubmatch(out)
+	if m == nil {
+		return 0, false
+	}
+	if string(m[1]) == "-inf" {
+		return math.Inf(-1), true
+	}
+	level, err := strconv.ParseFloat(string(m[1]), 64)
+	return level, err == nil
+}
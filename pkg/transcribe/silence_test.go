@@ -0,0 +1,72 @@
+package transcribe
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		out  string
+		want float64
+		ok   bool
+	}{
+		{"[Parsed_volumedetect_0 @ 0x1] max_volume: -3.2 dB", -3.2, true},
+		{"max_volume: 0.0 dB", 0, true},
+		{"max_volume: -inf dB", math.Inf(-1), true},
+		{"mean_volume: -20.1 dB", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseLevel(maxVolumeRe, []byte(tt.out))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseLevel(%q) = %v, %v; want %v, %v", tt.out, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestLevels measures with a fake ffmpeg that logs its arguments.
+func TestLevels(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args")
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\necho 'mean_volume: -70.5 dB' >&2\necho 'max_volume: -inf dB' >&2\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "talk.wav")
+	os.WriteFile(input, []byte("audio"), 0644)
+
+	c := &Client{FFmpegPath: ffmpeg, Cache: &Cache{Dir: filepath.Join(dir, "cache")}}
+	opts := Options{Channel: "right"}
+	for i := range 2 {
+		peak, mean, ok := c.levels(context.Background(), input, opts)
+		if !ok || !math.IsInf(peak, -1) || mean != -70.5 {
+			t.Errorf("levels() run %d = %v, %v, %v", i, peak, mean, ok)
+		}
+	}
+	data, _ := os.ReadFile(log)
+	runs := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(runs) != 1 {
+		t.Fatalf("ffmpeg ran %d times, want once with the levels cached", len(runs))
+	}
+	if !strings.Contains(runs[0], "-af pan=mono|c0=c1,volumedetect") {
+		t.Errorf("ffmpeg args = %s, want the right channel measured", runs[0])
+	}
+
+	// Another channel is measured again
+	if _, _, ok := c.levels(context.Background(), input, Options{}); !ok {
+		t.Error("levels() of the mix failed")
+	}
+	data, _ = os.ReadFile(log)
+	if runs := strings.Split(strings.TrimSpace(string(data)), "\n"); len(runs) != 2 || strings.Contains(runs[1], "pan=") {
+		t.Errorf("ffmpeg runs = %q, want a second without a pan filter", runs)
+	}
+}
@@ -0,0 +1,66 @@
+package transcribe
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// SniffMimeType identifies the container from the first bytes of a media
+// file by its signature, returning the MIME type MimeType would give its
+// extension, or "" when the format isn't recognised. 512 bytes is enough
+// for every supported format.
+func SniffMimeType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "audio/mpeg"
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")):
+		switch string(head[8:12]) {
+		case "WAVE":
+			return "audio/wav"
+		case "AVI ":
+			return "video/x-msvideo"
+		}
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "audio/flac"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		switch string(head[8:12]) {
+		case "M4A ", "M4B ", "M4P ":
+			return "audio/mp4"
+		case "qt  ":
+			return "video/quicktime"
+		}
+		return "video/mp4"
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// Matroska and WebM share the EBML header; the DocType tells
+		// them apart
+		if bytes.Contains(head, []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0:
+		// ADTS frame sync with layer 0
+		return "audio/aac"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE6 == 0xE2:
+		// MPEG audio frame sync with layer III
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// detectMimeType sniffs the MIME type of a file from its contents, falling
+// back to its extension (lower case, with the dot) when the signature isn't
+// recognised.
+func detectMimeType(path, ext string) string {
+	if f, err := os.Open(path); err == nil {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		if mime := SniffMimeType(head[:n]); mime != "" {
+			return mime
+		}
+	}
+	return MimeType(ext)
+}
@@ -0,0 +1,106 @@
+package transcribe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stats accumulates API call statistics over a run. It is safe for
+// concurrent use so the same instance can be shared by every request a run
+// makes. A nil *Stats records nothing.
+type Stats struct {
+	mu    sync.Mutex
+	start time.Time
+	s     StatsSnapshot
+}
+
+// StatsSnapshot is a point-in-time copy of Stats.
+type StatsSnapshot struct {
+	Requests        int     `json:"requests"`
+	Retries         int     `json:"retries"`
+	PromptTokens    int     `json:"prompt_tokens"`
+	AudioTokens     int     `json:"audio_tokens"`
+	OutputTokens    int     `json:"output_tokens"`
+	ThoughtsTokens  int     `json:"thoughts_tokens"`
+	TotalTokens     int     `json:"total_tokens"`
+	BytesUploaded   int64   `json:"bytes_uploaded"`
+	WallTimeSeconds float64 `json:"wall_time_seconds"`
+}
+
+// NewStats returns an empty Stats whose wall time starts now.
+func NewStats() *Stats {
+	return &Stats{start: time.Now()}
+}
+
+// recordRequest counts one HTTP request carrying n bytes of body.
+func (st *Stats) recordRequest(n int) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.Requests++
+	st.s.BytesUploaded += int64(n)
+}
+
+// recordRetry counts a request that is being repeated after a failure.
+func (st *Stats) recordRetry() {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.Retries++
+}
+
+// recordUsage adds the token counts reported in a response's usageMetadata.
+func (st *Stats) recordUsage(u *UsageMetadata) {
+	if st == nil || u == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.PromptTokens += u.PromptTokenCount
+	st.s.OutputTokens += u.CandidatesTokenCount
+	st.s.ThoughtsTokens += u.ThoughtsTokenCount
+	st.s.TotalTokens += u.TotalTokenCount
+	for _, d := range u.PromptTokensDetails {
+		if d.Modality == "AUDIO" {
+			st.s.AudioTokens += d.TokenCount
+		}
+	}
+}
+
+// Merge adds the counters from another run's snapshot, e.g. a single file
+// within a batch. Wall time is not merged; it always measures this run.
+func (st *Stats) Merge(o StatsSnapshot) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.s.Requests += o.Requests
+	st.s.Retries += o.Retries
+	st.s.PromptTokens += o.PromptTokens
+	st.s.AudioTokens += o.AudioTokens
+	st.s.OutputTokens += o.OutputTokens
+	st.s.ThoughtsTokens += o.ThoughtsTokens
+	st.s.TotalTokens += o.TotalTokens
+	st.s.BytesUploaded += o.BytesUploaded
+}
+
+// Snapshot returns the current counters and the wall time so far.
+func (st *Stats) Snapshot() StatsSnapshot {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s := st.s
+	s.WallTimeSeconds = time.Since(st.start).Seconds()
+	return s
+}
+
+// Summary renders the stats as a human-readable multi-line block.
+func (s StatsSnapshot) Summary() string {
+	return fmt.Sprintf("API requests: %d (%d retried)\nTokens: %d prompt, %d output, %d total\nUploaded: %d bytes\nWall time: %.1fs",
+		s.Requests, s.Retries,
+		s.PromptTokens, s.OutputTokens, s.TotalTokens,
+		s.BytesUploaded,
+		s.WallTimeSeconds)
+}
@@ -0,0 +1,100 @@
+package transcribe
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// generateStream makes one streamGenerateContent call with server-sent
+// events and hands each text fragment to opts.OnText as it arrives. It
+// returns the text and the finish reason. Leading whitespace is dropped
+// from the first response of a turn, matching generate's trimmed result.
+func (c *Client) generateStream(ctx context.Context, opts Options, contents []Content, first bool) (string, string, error) {
+	reqBody, err := requestBody(contents, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	url := c.endpoint(opts.model(), "streamGenerateContent", "alt=sse")
+	start := time.Now()
+	stop := c.waiting("the first text", start)
+	defer func() { stop() }()
+	resp, err := c.doWithRetry(ctx, "POST", url, "application/json", reqBody)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", "", responseError(resp.StatusCode, body)
+	}
+
+	var (
+		text         strings.Builder
+		usage        *UsageMetadata
+		finishReason string
+		started      = !first
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return "", "", fmt.Errorf("failed to parse stream chunk: %v\nChunk: %s", err, data)
+		}
+		if chunk.Error != nil {
+			return "", "", chunk.Error
+		}
+		if blocked := chunk.blocked(); blocked != nil {
+			return "", "", blocked
+		}
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		if r := chunk.Candidates[0].FinishReason; r != "" {
+			finishReason = r
+		}
+		for _, p := range chunk.Candidates[0].Content.Parts {
+			if p.Thought {
+				continue
+			}
+			piece := p.Text
+			if !started {
+				piece = strings.TrimLeft(piece, " \t\r\n")
+				started = piece != ""
+			}
+			if piece == "" {
+				continue
+			}
+			if text.Len() == 0 {
+				stop()
+				stop = func() {}
+				c.logf("First text after %s\n", since(start))
+			}
+			text.WriteString(piece)
+			opts.OnText(piece)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	// Usage is cumulative in each chunk; the last one has the totals.
+	c.Stats.recordUsage(usage)
+
+	return text.String(), finishReason, nil
+}
@@ -0,0 +1,131 @@
+package transcribe
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	cleanInstruction    = "Leave out filler words (um, uh, er), false starts, stutters and words repeated by mistake, keeping the wording otherwise."
+	verbatimInstruction = "Transcribe verbatim: keep every filler word (um, uh), false start, stutter and repetition as spoken, and mark non-speech sounds in brackets, such as [laughter], [music] or [inaudible]."
+)
+
+// styleInstruction returns the prompt addition for Options.Style.
+func styleInstruction(style string) string {
+	switch style {
+	case "clean":
+		return cleanInstruction
+	case "verbatim":
+		return verbatimInstruction
+	}
+	return ""
+}
+
+// fillers are the words clean style drops, in lower case.
+var fillers = map[string]bool{
+	"um": true, "umm": true, "uh": true, "uhh": true, "er": true, "erm": true,
+	"ah": true, "hmm": true, "hm": true, "mm": true,
+}
+
+// doubledWords may rightly come twice in a row ("what it is is", "had
+// had"), so clean style keeps the repeat.
+var doubledWords = map[string]bool{"had": true, "that": true, "is": true}
+
+// applyStyle post-processes the transcript for Options.Style: clean
+// drops the fillers, false starts ("I- I") and repeated words the model
+// left in, from the text and the timed words; verbatim writes the sound
+// annotations the model varies on ("(laughs)", "*Laughter*") as
+// [laughter].
+func applyStyle(result Result, style string) Result {
+	switch style {
+	case "clean":
+		result = mapText(result, cleanText)
+		for i, seg := range result.Segments {
+			if len(seg.Words) == 0 {
+				continue
+			}
+			tokens := make([]string, len(seg.Words))
+			for j, w := range seg.Words {
+				tokens[j] = w.Word
+			}
+			keep, out := cleanTokens(tokens)
+			words := make([]Word, len(keep))
+			for j, k := range keep {
+				words[j] = seg.Words[k]
+				words[j].Word = out[j]
+			}
+			result.Segments[i].Words = words
+		}
+	case "verbatim":
+		result = mapText(result, func(s string) string {
+			return annotationRe.ReplaceAllStringFunc(s, func(m string) string {
+				sound := strings.ToLower(annotationRe.FindStringSubmatch(m)[1])
+				if canonical, ok := soundNames[sound]; ok {
+					sound = canonical
+				}
+				return "[" + sound + "]"
+			})
+		})
+	}
+	return result
+}
+
+// cleanText applies clean style to each line of s.
+func cleanText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		_, out := cleanTokens(strings.Fields(line))
+		lines[i] = strings.Join(out, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cleanTokens drops fillers, false starts and repeated words from tokens,
+// returning the indexes of those kept and their text, which takes over
+// the sentence-ending punctuation and capital letter of dropped ones.
+func cleanTokens(tokens []string) (keep []int, out []string) {
+	capNext := false
+	lastCore := ""
+	for i, t := range tokens {
+		core := strings.ToLower(strings.TrimFunc(t, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+		}))
+		last := len(out) - 1
+		drop := core != "" && (fillers[core] || strings.HasSuffix(t, "-") ||
+			last >= 0 && core == lastCore && !doubledWords[core] && !strings.ContainsAny(out[last][len(out[last])-1:], ".,;:!?"))
+		if drop {
+			switch end := strings.TrimLeftFunc(t, func(r rune) bool { return !strings.ContainsRune(".!?", r) }); {
+			case last < 0:
+			case end != "":
+				out[last] = strings.TrimRight(out[last], ",;:") + end
+			case strings.HasSuffix(t, ","):
+				// "is, uh, good" reads "is good"
+				out[last] = strings.TrimSuffix(out[last], ",")
+			}
+			if first := []rune(t)[0]; unicode.IsUpper(first) && strings.ToUpper(t) != t {
+				capNext = true
+			}
+			continue
+		}
+		if capNext {
+			r := []rune(t)
+			r[0] = unicode.ToUpper(r[0])
+			t = string(r)
+			capNext = false
+		}
+		keep, out = append(keep, i), append(out, t)
+		lastCore = core
+	}
+	return keep, out
+}
+
+// annotationRe matches a sound annotation in any brackets or asterisks.
+var annotationRe = regexp.MustCompile(`(?i)[\[(*]\s*(laugh|laughs|laughing|laughter|music|applause|inaudible|crosstalk|cough|coughs|coughing|sigh|sighs|silence|noise)\s*[\])*]`)
+
+// soundNames gives the one name verbatim style writes for each sound.
+var soundNames = map[string]string{
+	"laugh": "laughter", "laughs": "laughter", "laughing": "laughter",
+	"coughs": "cough", "coughing": "cough",
+	"sighs": "sigh",
+}
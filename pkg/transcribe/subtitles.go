@@ -0,0 +1,258 @@
+package transcribe
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FormatSRT renders segments as a SubRip file.
+func FormatSRT(segments []Segment) string {
+	var b strings.Builder
+	for i, seg := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1,
+			FormatTimecode(seg.Start, ","), FormatTimecode(seg.End, ","), seg.Text)
+	}
+	return b.String()
+}
+
+// FormatVTT renders segments as a WebVTT file. settings, when non-empty,
+// is appended to every cue timing line (e.g. "line:90% align:center").
+func FormatVTT(segments []Segment, settings string) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, seg := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s", i+1, FormatTimecode(seg.Start, "."), FormatTimecode(seg.End, "."))
+		if settings != "" {
+			b.WriteString(" " + settings)
+		}
+		fmt.Fprintf(&b, "\n%s\n\n", seg.Text)
+	}
+	return b.String()
+}
+
+// FormatTimecode renders seconds as HH:MM:SS<sep>mmm.
+func FormatTimecode(seconds float64, sep string) string {
+	ms := milliseconds(seconds)
+	if ms < 0 {
+		ms = 0
+	}
+	h := ms / 3600000
+	m := ms / 60000 % 60
+	s := ms / 1000 % 60
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms%1000)
+}
+
+// CueOptions shapes subtitle cues with ShapeCues. Zero fields leave the
+// cues as they are.
+type CueOptions struct {
+	// FPS snaps cue times to the frames of this frame rate.
+	FPS float64
+	// MinDuration and MaxDuration bound how long each cue is shown.
+	MinDuration, MaxDuration time.Duration
+}
+
+// ShapeCues returns the segments as subtitle cues within o: cues longer
+// than MaxDuration are split between words, cues shorter than MinDuration
+// are merged with the ones soon after them or shown for longer, and every
+// time is snapped to a frame. segments is left unchanged.
+func ShapeCues(segments []Segment, o CueOptions) []Segment {
+	if o == (CueOptions{}) {
+		return segments
+	}
+	var cues []Segment
+	for _, seg := range segments {
+		cues = append(cues, splitCue(seg, o.MaxDuration.Seconds())...)
+	}
+	if o.MinDuration > 0 {
+		cues = mergeCues(cues, o.MinDuration.Seconds(), o.MaxDuration.Seconds())
+	}
+	if o.FPS > 0 {
+		for i := range cues {
+			cue := &cues[i]
+			cue.Start, cue.End = snapFrame(cue.Start, o.FPS), snapFrame(cue.End, o.FPS)
+			if cue.End <= cue.Start {
+				cue.End = cue.Start + 1/o.FPS
+			}
+			cue.Words = slices.Clone(cue.Words)
+			for j := range cue.Words {
+				cue.Words[j].Start, cue.Words[j].End = snapFrame(cue.Words[j].Start, o.FPS), snapFrame(cue.Words[j].End, o.FPS)
+			}
+		}
+	}
+	return cues
+}
+
+// splitCue splits seg between words into cues of at most longest
+// seconds, unless longest is 0. The words are timed by seg.Words when it
+// has one for each word of the text, and in proportion to their length
+// otherwise. A single word longer than longest stays one cue.
+func splitCue(seg Segment, longest float64) []Segment {
+	tokens := strings.Fields(seg.Text)
+	if longest <= 0 || seg.End-seg.Start <= longest || len(tokens) < 2 {
+		return []Segment{seg}
+	}
+	timed := len(seg.Words) == len(tokens)
+	starts, ends := make([]float64, len(tokens)), make([]float64, len(tokens))
+	if timed {
+		for i, w := range seg.Words {
+			starts[i], ends[i] = w.Start, w.End
+		}
+	} else {
+		letters := 0
+		for _, t := range tokens {
+			letters += utf8.RuneCountInString(t)
+		}
+		at, per := seg.Start, (seg.End-seg.Start)/float64(letters)
+		for i, t := range tokens {
+			starts[i] = at
+			at += per * float64(utf8.RuneCountInString(t))
+			ends[i] = at
+		}
+	}
+	starts[0], ends[len(ends)-1] = seg.Start, seg.End
+
+	var cues []Segment
+	first := 0
+	for i := 1; i <= len(tokens); i++ {
+		if i < len(tokens) && ends[i]-starts[first] <= longest {
+			continue
+		}
+		cue := seg
+		cue.Start, cue.End = starts[first], ends[i-1]
+		cue.Text = strings.Join(tokens[first:i], " ")
+		if timed {
+			cue.Words = seg.Words[first:i:i]
+		} else {
+			cue.Words = nil
+			for _, w := range seg.Words {
+				if w.Start >= cue.Start && (w.Start < cue.End || i == len(tokens)) {
+					cue.Words = append(cue.Words, w)
+				}
+			}
+		}
+		cues = append(cues, cue)
+		first = i
+	}
+	return cues
+}
+
+// mergeCues merges each cue shorter than shortest seconds with the cues
+// starting less than shortest after it, while they have the same speaker
+// and the whole lasts at most longest (any length when longest is 0). A
+// cue that's still short is shown for longer, up to the next one.
+func mergeCues(cues []Segment, shortest, longest float64) []Segment {
+	var out []Segment
+	for i := 0; i < len(cues); i++ {
+		cue := cues[i]
+		for ; cue.End-cue.Start < shortest && i+1 < len(cues); i++ {
+			next := cues[i+1]
+			if next.Speaker != cue.Speaker || next.Start-cue.End >= shortest || longest > 0 && next.End-cue.Start > longest {
+				break
+			}
+			cue.End = next.End
+			cue.Text += " " + next.Text
+			cue.Words = slices.Concat(cue.Words, next.Words)
+		}
+		if cue.End-cue.Start < shortest {
+			end := cue.Start + shortest
+			if i+1 < len(cues) {
+				end = math.Min(end, cues[i+1].Start)
+			}
+			cue.End = math.Max(cue.End, end)
+		}
+		out = append(out, cue)
+	}
+	return out
+}
+
+// snapFrame rounds seconds to the nearest frame boundary at fps.
+func snapFrame(seconds, fps float64) float64 {
+	return math.Round(seconds*fps) / fps
+}
+
+// KaraokeVTT returns the segments with a WebVTT timestamp tag before each
+// of their timed words, for FormatVTT, so players highlight the words as
+// they're spoken. Segments without word timings are left as they are.
+func KaraokeVTT(segments []Segment) []Segment {
+	out := slices.Clone(segments)
+	for i, seg := range out {
+		tokens := karaokeTokens(seg)
+		if tokens == nil {
+			continue
+		}
+		var b strings.Builder
+		// Tags must come after the cue's start, before its end and in order
+		last, end := milliseconds(seg.Start), milliseconds(seg.End)
+		for j, w := range seg.Words {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			if at := milliseconds(w.Start); at > last && at < end {
+				fmt.Fprintf(&b, "<%s>", FormatTimecode(w.Start, "."))
+				last = at
+			}
+			b.WriteString(tokens[j])
+		}
+		out[i].Text = b.String()
+	}
+	return out
+}
+
+// KaraokeSRT returns a cue for each timed word of the segments, showing
+// the segment's text with that word underlined, for FormatSRT, which has
+// no inline timing. Segments without word timings stay one cue.
+func KaraokeSRT(segments []Segment) []Segment {
+	var out []Segment
+	for _, seg := range segments {
+		tokens := karaokeTokens(seg)
+		n := len(out)
+		for j := range seg.Words {
+			cue := seg
+			if j > 0 {
+				cue.Start = math.Min(math.Max(seg.Words[j].Start, seg.Start), seg.End)
+			}
+			if j+1 < len(seg.Words) {
+				cue.End = math.Min(math.Max(seg.Words[j+1].Start, cue.Start), seg.End)
+			}
+			if milliseconds(cue.End) <= milliseconds(cue.Start) {
+				continue
+			}
+			text := slices.Clone(tokens)
+			text[j] = "<u>" + text[j] + "</u>"
+			cue.Text = strings.Join(text, " ")
+			cue.Words = seg.Words[j : j+1 : j+1]
+			out = append(out, cue)
+		}
+		if len(out) == n {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// karaokeTokens returns the text to show for each of seg's timed words:
+// the words of its text when there's one for each, so the punctuation is
+// kept, or else the timed words themselves. It's nil without word timings.
+func karaokeTokens(seg Segment) []string {
+	if len(seg.Words) == 0 {
+		return nil
+	}
+	if tokens := strings.Fields(seg.Text); len(tokens) == len(seg.Words) {
+		return tokens
+	}
+	tokens := make([]string, len(seg.Words))
+	for i, w := range seg.Words {
+		tokens[i] = w.Word
+	}
+	return tokens
+}
+
+// milliseconds rounds seconds to whole milliseconds, as timecodes show
+// them.
+func milliseconds(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
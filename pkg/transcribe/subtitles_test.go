@@ -0,0 +1,198 @@
+package transcribe
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatTimecode(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		sep     string
+		want    string
+	}{
+		{0, ",", "00:00:00,000"},
+		{61.5, ",", "00:01:01,500"},
+		{3723.0004, ".", "01:02:03.000"},
+		{59.9996, ".", "00:01:00.000"},
+		{-1, ".", "00:00:00.000"},
+		{100 * 3600, ".", "100:00:00.000"},
+	}
+	for _, tt := range tests {
+		if got := FormatTimecode(tt.seconds, tt.sep); got != tt.want {
+			t.Errorf("FormatTimecode(%v, %q) = %q, want %q", tt.seconds, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSubtitles(t *testing.T) {
+	segments := []Segment{
+		{Start: 0, End: 1.5, Text: "Hello."},
+		{Start: 1.5, End: 3.25, Text: "Two\nlines."},
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			"srt",
+			FormatSRT(segments),
+			"1\n00:00:00,000 --> 00:00:01,500\nHello.\n\n2\n00:00:01,500 --> 00:00:03,250\nTwo\nlines.\n\n",
+		},
+		{
+			"vtt",
+			FormatVTT(segments, ""),
+			"WEBVTT\n\n1\n00:00:00.000 --> 00:00:01.500\nHello.\n\n2\n00:00:01.500 --> 00:00:03.250\nTwo\nlines.\n\n",
+		},
+		{
+			"vtt with settings",
+			FormatVTT(segments[:1], "line:90% align:center"),
+			"WEBVTT\n\n1\n00:00:00.000 --> 00:00:01.500 line:90% align:center\nHello.\n\n",
+		},
+		{"srt without segments", FormatSRT(nil), ""},
+		{"vtt without segments", FormatVTT(nil, ""), "WEBVTT\n\n"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestShapeCues(t *testing.T) {
+	words := []Word{{"one", 0, 1}, {"two", 1, 2}, {"three", 2, 5}, {"four", 5, 6}}
+	tests := []struct {
+		name     string
+		segments []Segment
+		o        CueOptions
+		want     []Segment
+	}{
+		{
+			"no options",
+			[]Segment{{Start: 0, End: 20, Text: "a long one"}},
+			CueOptions{},
+			[]Segment{{Start: 0, End: 20, Text: "a long one"}},
+		},
+		{
+			"split by word timings",
+			[]Segment{{Start: 0, End: 6, Text: "one two three four", Words: words}},
+			CueOptions{MaxDuration: 3 * time.Second},
+			[]Segment{
+				{Start: 0, End: 2, Text: "one two", Words: words[:2]},
+				{Start: 2, End: 5, Text: "three", Words: words[2:3]},
+				{Start: 5, End: 6, Text: "four", Words: words[3:]},
+			},
+		},
+		{
+			"split by word length",
+			[]Segment{{Start: 10, End: 18, Text: "abcd efgh ijkl mnop", Speaker: "Anna"}},
+			CueOptions{MaxDuration: 4 * time.Second},
+			[]Segment{
+				{Start: 10, End: 14, Text: "abcd efgh", Speaker: "Anna"},
+				{Start: 14, End: 18, Text: "ijkl mnop", Speaker: "Anna"},
+			},
+		},
+		{
+			"one long word stays",
+			[]Segment{{Start: 0, End: 10, Text: "Hmmmm."}},
+			CueOptions{MaxDuration: 2 * time.Second},
+			[]Segment{{Start: 0, End: 10, Text: "Hmmmm."}},
+		},
+		{
+			"short cue merged",
+			[]Segment{{Start: 0, End: 0.4, Text: "Yes."}, {Start: 0.5, End: 2, Text: "Go on."}},
+			CueOptions{MinDuration: time.Second},
+			[]Segment{{Start: 0, End: 2, Text: "Yes. Go on."}},
+		},
+		{
+			"other speaker lengthened",
+			[]Segment{{Start: 0, End: 0.4, Text: "Yes.", Speaker: "A"}, {Start: 0.8, End: 2, Text: "Go on.", Speaker: "B"}},
+			CueOptions{MinDuration: time.Second},
+			[]Segment{{Start: 0, End: 0.8, Text: "Yes.", Speaker: "A"}, {Start: 0.8, End: 2, Text: "Go on.", Speaker: "B"}},
+		},
+		{
+			"far apart lengthened",
+			[]Segment{{Start: 0, End: 0.4, Text: "Yes."}, {Start: 5, End: 7, Text: "Go on."}},
+			CueOptions{MinDuration: time.Second},
+			[]Segment{{Start: 0, End: 1, Text: "Yes."}, {Start: 5, End: 7, Text: "Go on."}},
+		},
+		{
+			"merge kept within max",
+			[]Segment{{Start: 0, End: 0.5, Text: "Yes."}, {Start: 0.5, End: 3.4, Text: "Go on then."}},
+			CueOptions{MinDuration: time.Second, MaxDuration: 3 * time.Second},
+			[]Segment{{Start: 0, End: 0.5, Text: "Yes."}, {Start: 0.5, End: 3.4, Text: "Go on then."}},
+		},
+		{
+			"snapped to frames",
+			[]Segment{{Start: 1.01, End: 2.03, Text: "Hi."}, {Start: 3.001, End: 3.01, Text: "Bye."}},
+			CueOptions{FPS: 25},
+			[]Segment{{Start: 1, End: 2.04, Text: "Hi."}, {Start: 3, End: 3.04, Text: "Bye."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShapeCues(tt.segments, tt.o); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShapeCues() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKaraokeVTT(t *testing.T) {
+	tests := []struct {
+		name string
+		seg  Segment
+		want string
+	}{
+		{
+			"text words kept",
+			Segment{Start: 1, End: 3, Text: "Hello, world!", Words: []Word{{"hello", 1, 1.5}, {"world", 1.6, 3}}},
+			"Hello, <00:00:01.600>world!",
+		},
+		{
+			"timed words when the text differs",
+			Segment{Start: 0, End: 3, Text: "It's fine.", Words: []Word{{"it", 0, 0.2}, {"is", 0.2, 0.5}, {"fine", 0.5, 1}}},
+			"it <00:00:00.200>is <00:00:00.500>fine",
+		},
+		{
+			"tags outside the cue or out of order dropped",
+			Segment{Start: 1, End: 2, Text: "a b c d", Words: []Word{{"a", 1, 1.2}, {"b", 1.5, 1.6}, {"c", 1.4, 1.6}, {"d", 2, 2.5}}},
+			"a <00:00:01.500>b c d",
+		},
+		{
+			"no word timings",
+			Segment{Start: 0, End: 1, Text: "Hello."},
+			"Hello.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs := []Segment{tt.seg}
+			got := KaraokeVTT(segs)
+			if len(got) != 1 || got[0].Text != tt.want {
+				t.Errorf("KaraokeVTT() = %+v, want text %q", got, tt.want)
+			}
+			if segs[0].Text != tt.seg.Text {
+				t.Errorf("KaraokeVTT changed its input to %q", segs[0].Text)
+			}
+		})
+	}
+}
+
+func TestKaraokeSRT(t *testing.T) {
+	words := []Word{{"one", 0.5, 1}, {"two", 1, 1}, {"three", 1, 2}}
+	segments := []Segment{
+		{Start: 0, End: 3, Text: "One, two, three.", Words: words},
+		{Start: 4, End: 5, Text: "Untimed."},
+	}
+	want := []Segment{
+		{Start: 0, End: 1, Text: "<u>One,</u> two, three.", Words: words[:1]},
+		{Start: 1, End: 3, Text: "One, two, <u>three.</u>", Words: words[2:]},
+		{Start: 4, End: 5, Text: "Untimed."},
+	}
+	if got := KaraokeSRT(segments); !reflect.DeepEqual(got, want) {
+		t.Errorf("KaraokeSRT() = %+v, want %+v", got, want)
+	}
+}
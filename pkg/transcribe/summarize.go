@@ -0,0 +1,41 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+)
+
+// summaryStyles are the named summary styles; any other SummaryStyle is
+// passed to the model as a free-form instruction.
+var summaryStyles = map[string]string{
+	"":         "Write a short paragraph giving the gist, followed by the key points as a bulleted list.",
+	"brief":    "Write two or three sentences.",
+	"detailed": "Write a detailed summary with a short heading for each topic discussed.",
+	"bullets":  "Write the key points as a bulleted list.",
+}
+
+// SummaryStyleNames lists the named summary styles.
+var SummaryStyleNames = []string{"brief", "detailed", "bullets"}
+
+// summarize adds a summary of the transcript to result when opts asks for
+// one, with a second, text-only request.
+func (c *Client) summarize(ctx context.Context, result Result, opts Options) (Result, error) {
+	if !opts.Summarize || result.Text == "" {
+		return result, nil
+	}
+
+	c.logf("Summarizing...\n")
+	style, ok := summaryStyles[opts.SummaryStyle]
+	if !ok {
+		style = opts.SummaryStyle
+	}
+	prompt := fmt.Sprintf("Summarize the following transcript. %s Write in the same language as the transcript and output only the summary, no extra commentary.\n\n%s", style, result.Text)
+	opts.OnText = nil
+	opts.SystemInstruction = ""
+	summary, err := c.generate(ctx, opts, []Part{{Text: prompt}})
+	if err != nil {
+		return result, fmt.Errorf("summarizing: %w", err)
+	}
+	result.Summary = summary
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// translateInstruction is appended to the prompt for single-pass translation.
+func translateInstruction(lang string) string {
+	return fmt.Sprintf("Translate the speech into %s and output only the %s translation instead of the original-language transcription.", lang, lang)
+}
+
+// translate finishes a result for the requested translation mode: it
+// records the target language and, in two-pass mode, translates the
+// transcript text with a second, text-only request. Timed segments are sent
+// as timestamped lines so their timing survives the round trip.
+func (c *Client) translate(ctx context.Context, result Result, opts Options) (Result, error) {
+	if opts.TranslateTo == "" {
+		return result, nil
+	}
+	result.TranslatedTo = opts.TranslateTo
+	if !opts.TwoPassTranslate || result.Text == "" {
+		return result, nil
+	}
+
+	c.logf("Translating to %s...\n", opts.TranslateTo)
+	result.SourceText = result.Text
+	opts.OnText = nil
+	opts.SystemInstruction = ""
+
+	if len(result.Segments) == 0 {
+		prompt := fmt.Sprintf("Translate the following transcript into %s. Output only the translation, no extra commentary.\n\n%s", opts.TranslateTo, result.Text)
+		text, err := c.generate(ctx, opts, []Part{{Text: prompt}})
+		if err != nil {
+			return result, fmt.Errorf("translating: %w", err)
+		}
+		result.Text = text
+		return result, nil
+	}
+
+	var lines strings.Builder
+	for _, seg := range result.Segments {
+		fmt.Fprintf(&lines, "[%s --> %s] %s\n", FormatTimecode(seg.Start, "."), FormatTimecode(seg.End, "."), seg.Text)
+	}
+	prompt := fmt.Sprintf("Translate the text of each line below into %s. Keep every line and its [start --> end] timestamp exactly as given; output only the translated lines.\n\n%s", opts.TranslateTo, lines.String())
+	text, err := c.generate(ctx, opts, []Part{{Text: prompt}})
+	if err != nil {
+		return result, fmt.Errorf("translating: %w", err)
+	}
+	segments, err := parseSegments(text)
+	if err != nil {
+		return result, fmt.Errorf("parsing translation: %v", err)
+	}
+	if len(segments) == len(result.Segments) {
+		for i := range segments {
+			segments[i].Speaker = result.Segments[i].Speaker
+			segments[i].Topic = result.Segments[i].Topic
+		}
+	}
+	result.Segments = segments
+	result.Text = JoinSegments(segments)
+	return result, nil
+}
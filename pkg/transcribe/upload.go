@@ -0,0 +1,182 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Requests carrying inline data are capped at 20MB in total, and base64
+// inflates the audio by a third, so leave headroom for the rest of the body.
+const maxInlineRequestBytes = 20 * 1024 * 1024
+
+// deleteTimeout bounds the clean-up request after a transcription.
+const deleteTimeout = 30 * time.Second
+
+const (
+	uploadURLTemplate = "%s/upload/v1beta/files?key=%s"
+	fileURLTemplate   = "%s/v1beta/%s?key=%s"
+)
+
+// uploadedFile is the subset of the Files API resource we use.
+type uploadedFile struct {
+	Name     string `json:"name"`
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	State    string `json:"state"`
+	Error    *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// shouldUpload decides between inline data and the Files API. Vertex AI
+// has no Files API, so audio is always inlined there.
+func (c *Client) shouldUpload(size int, mode string) bool {
+	if c.vertex() {
+		return false
+	}
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return base64.StdEncoding.EncodedLen(size) > maxInlineRequestBytes-64*1024
+}
+
+// uploadFile sends data through the Files API resumable upload protocol and
+// waits until the file is ACTIVE and can be referenced from generateContent.
+func (c *Client) uploadFile(ctx context.Context, data []byte, mimeType, displayName string) (*uploadedFile, error) {
+	meta, err := json.Marshal(map[string]any{"file": map[string]string{"display_name": displayName}})
+	if err != nil {
+		return nil, err
+	}
+
+	// Start the session; the upload URL comes back in a response header.
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf(uploadURLTemplate, c.BaseURL, c.APIKey), bytes.NewReader(meta))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.Itoa(len(data)))
+	req.Header.Set("X-Goog-Upload-Header-Content-Type", mimeType)
+
+	c.Stats.recordRequest(len(meta))
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
+	if uploadURL == "" {
+		return nil, fmt.Errorf("no upload URL in response (%s): %w", resp.Status, responseError(resp.StatusCode, body))
+	}
+
+	// Send the bytes and finalize in one go.
+	req, err = http.NewRequestWithContext(ctx, "POST", uploadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(&countingReader{r: bytes.NewReader(data), c: c, total: len(data)}), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.Header.Set("X-Goog-Upload-Offset", "0")
+	req.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+
+	c.Stats.recordRequest(len(data))
+	start := time.Now()
+	resp, err = c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	c.progress("upload", float64(len(data)), float64(len(data)))
+	c.logf("Uploaded %s in %s (%s)\n", byteSize(len(data)), since(start), throughput(len(data), time.Since(start)))
+
+	var uploaded struct {
+		File *uploadedFile `json:"file"`
+	}
+	if err := json.Unmarshal(body, &uploaded); err != nil || uploaded.File == nil {
+		return nil, fmt.Errorf("unexpected upload response (%s): %w", resp.Status, responseError(resp.StatusCode, body))
+	}
+
+	return c.waitForFile(ctx, uploaded.File)
+}
+
+// waitForFile polls a freshly uploaded file until processing finishes.
+// Audio is usually ACTIVE immediately; video can take a while.
+func (c *Client) waitForFile(ctx context.Context, file *uploadedFile) (*uploadedFile, error) {
+	if file.State == "PROCESSING" {
+		c.logf("Waiting for the Files API to process the upload...\n")
+		start := time.Now()
+		defer func() { c.logf("Processed in %s\n", since(start)) }()
+	}
+	for file.State == "PROCESSING" {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(fileURLTemplate, c.BaseURL, file.Name, c.APIKey), nil)
+		if err != nil {
+			return nil, err
+		}
+		c.Stats.recordRequest(0)
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var next uploadedFile
+		if err := json.Unmarshal(body, &next); err != nil || next.Name == "" {
+			return nil, fmt.Errorf("unexpected file status response (%s): %w", resp.Status, responseError(resp.StatusCode, body))
+		}
+		file = &next
+	}
+
+	if file.State == "FAILED" {
+		msg := "processing failed"
+		if file.Error != nil {
+			msg = file.Error.Message
+		}
+		return nil, fmt.Errorf("file %s: %s", file.Name, msg)
+	}
+	return file, nil
+}
+
+// deleteFile removes an uploaded file. Files expire on their own after 48
+// hours, so failures are ignored.
+func (c *Client) deleteFile(name string) {
+	// Not the caller's context: clean up even after a cancelled transcription
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf(fileURLTemplate, c.BaseURL, name, c.APIKey), nil)
+	if err != nil {
+		return
+	}
+	c.Stats.recordRequest(0)
+	if resp, err := c.httpClient().Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,345 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vertexURLTemplate  = "%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s"
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	googleTokenURL     = "https://oauth2.googleapis.com/token"
+	metadataURL        = "http://metadata.google.internal/computeMetadata/v1/"
+)
+
+// TokenSource supplies OAuth access tokens for Vertex AI requests.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// NewVertexClient returns a Client for Vertex AI in project and location,
+// authenticated with the service account key in credentialsJSON or, when
+// that is nil, Application Default Credentials. An empty project falls back
+// to the one named by the credentials.
+func NewVertexClient(project, location string, credentialsJSON []byte) (*Client, error) {
+	ts, credProject, err := findCredentials(credentialsJSON)
+	if err != nil {
+		return nil, authError{err}
+	}
+	if project == "" {
+		project = credProject
+	}
+	if project == "" {
+		project = metadataProject()
+	}
+	if project == "" {
+		return nil, errors.New("no Google Cloud project set and none found in the credentials")
+	}
+	if location == "" {
+		return nil, errors.New("no Vertex AI location set")
+	}
+
+	c := NewClient("")
+	c.BaseURL = VertexBaseURL(location)
+	c.Project = project
+	c.Location = location
+	c.TokenSource = ts
+	return c, nil
+}
+
+// NewTokenSource returns OAuth tokens for Google Cloud APIs (Cloud Storage,
+// say) from the service account key in credentialsJSON or, when that is
+// nil, Application Default Credentials, found as NewVertexClient finds
+// them.
+func NewTokenSource(credentialsJSON []byte) (TokenSource, error) {
+	ts, _, err := findCredentials(credentialsJSON)
+	if err != nil {
+		return nil, authError{err}
+	}
+	return ts, nil
+}
+
+// VertexBaseURL returns the Vertex AI endpoint serving location.
+func VertexBaseURL(location string) string {
+	if location == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return "https://" + location + "-aiplatform.googleapis.com"
+}
+
+// vertex reports whether the client talks to Vertex AI.
+func (c *Client) vertex() bool {
+	return c.Project != ""
+}
+
+// endpoint returns the URL of a model method such as "generateContent",
+// with extra query parameters appended.
+func (c *Client) endpoint(model, method, query string) string {
+	if c.vertex() {
+		u := fmt.Sprintf(vertexURLTemplate, c.BaseURL, c.Project, c.Location, model, method)
+		if query != "" {
+			u += "?" + query
+		}
+		return u
+	}
+	u := fmt.Sprintf(apiURLTemplate, c.BaseURL, model, method, c.APIKey)
+	if query != "" {
+		u += "&" + query
+	}
+	return u
+}
+
+// authorize adds the bearer token when the client uses OAuth.
+func (c *Client) authorize(req *http.Request) error {
+	if c.TokenSource == nil {
+		return nil
+	}
+	token, err := c.TokenSource.Token(req.Context())
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", authError{err})
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// credentialsFile covers the two JSON credential types gcloud produces.
+type credentialsFile struct {
+	Type      string `json:"type"`
+	ProjectID string `json:"project_id"`
+
+	// service_account
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	// authorized_user (gcloud auth application-default login)
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+	QuotaProjectID string `json:"quota_project_id"`
+}
+
+// findCredentials follows the Application Default Credentials order:
+// explicit JSON, GOOGLE_APPLICATION_CREDENTIALS, gcloud's well-known file,
+// then the GCE metadata server. It also returns the project the
+// credentials belong to, when known.
+func findCredentials(credentialsJSON []byte) (TokenSource, string, error) {
+	if credentialsJSON == nil {
+		path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		if path == "" {
+			path = wellKnownCredentialsFile()
+			if _, err := os.Stat(path); err != nil {
+				return newMetadataTokenSource(), "", nil
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading credentials: %v", err)
+		}
+		credentialsJSON = data
+	}
+
+	var f credentialsFile
+	if err := json.Unmarshal(credentialsJSON, &f); err != nil {
+		return nil, "", fmt.Errorf("parsing credentials: %v", err)
+	}
+	switch f.Type {
+	case "service_account":
+		ts, err := newServiceAccountTokenSource(f)
+		return ts, f.ProjectID, err
+	case "authorized_user":
+		return newRefreshTokenSource(f), f.QuotaProjectID, nil
+	}
+	return nil, "", fmt.Errorf("unsupported credentials type %q", f.Type)
+}
+
+// wellKnownCredentialsFile is where `gcloud auth application-default login`
+// stores credentials.
+func wellKnownCredentialsFile() string {
+	const name = "application_default_credentials.json"
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", name)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", name)
+}
+
+// cachedTokenSource reuses a token until shortly before it expires.
+type cachedTokenSource struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	fetch  func(ctx context.Context) (tokenResponse, error)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (s *cachedTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expiry) > time.Minute {
+		return s.token, nil
+	}
+	resp, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", errors.New("no access token in response")
+	}
+	s.token = resp.AccessToken
+	s.expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// newServiceAccountTokenSource exchanges a self-signed JWT for access
+// tokens.
+func newServiceAccountTokenSource(f credentialsFile) (TokenSource, error) {
+	block, _ := pem.Decode([]byte(f.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account private_key is not PEM")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		k, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("service account key is not RSA")
+		}
+		key = k
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("parsing service account key: %v", err)
+	}
+	tokenURI := f.TokenURI
+	if tokenURI == "" {
+		tokenURI = googleTokenURL
+	}
+
+	return &cachedTokenSource{fetch: func(ctx context.Context) (tokenResponse, error) {
+		now := time.Now()
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+		claims, _ := json.Marshal(map[string]any{
+			"iss":   f.ClientEmail,
+			"scope": cloudPlatformScope,
+			"aud":   tokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		})
+		unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+		sum := sha256.Sum256([]byte(unsigned))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			return tokenResponse{}, err
+		}
+		return postTokenForm(ctx, tokenURI, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)},
+		})
+	}}, nil
+}
+
+// newRefreshTokenSource refreshes user credentials from gcloud.
+func newRefreshTokenSource(f credentialsFile) TokenSource {
+	return &cachedTokenSource{fetch: func(ctx context.Context) (tokenResponse, error) {
+		return postTokenForm(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {f.ClientID},
+			"client_secret": {f.ClientSecret},
+			"refresh_token": {f.RefreshToken},
+		})
+	}}
+}
+
+// newMetadataTokenSource asks the GCE metadata server for the attached
+// service account's token.
+func newMetadataTokenSource() TokenSource {
+	return &cachedTokenSource{fetch: func(ctx context.Context) (tokenResponse, error) {
+		var tok tokenResponse
+		body, err := metadataGet(ctx, "instance/service-accounts/default/token")
+		if err != nil {
+			return tok, fmt.Errorf("no credentials found (set GOOGLE_APPLICATION_CREDENTIALS or run gcloud auth application-default login): %v", err)
+		}
+		err = json.Unmarshal(body, &tok)
+		return tok, err
+	}}
+}
+
+// metadataProject returns the project from the metadata server, or "" off
+// Google Cloud.
+func metadataProject() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	body, err := metadataGet(ctx, "project/project-id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(body))
+}
+
+func metadataGet(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", metadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("metadata server: HTTP %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func postTokenForm(ctx context.Context, tokenURL string, form url.Values) (tokenResponse, error) {
+	var tok tokenResponse
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return tok, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return tok, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return tok, err
+	}
+	if resp.StatusCode != 200 {
+		return tok, fmt.Errorf("token endpoint: HTTP %d: %s", resp.StatusCode, body)
+	}
+	err = json.Unmarshal(body, &tok)
+	return tok, err
+}
@@ -0,0 +1,134 @@
+package transcribe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// vocabularyInstruction lists the terms the model should spell exactly.
+func vocabularyInstruction(terms []string) string {
+	return fmt.Sprintf("The recording may mention these names and terms. When you hear them, spell them exactly as written here: %s.", strings.Join(terms, ", "))
+}
+
+var wordSpanRe = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}'’-]*`)
+
+// CorrectTerms replaces near-misses of terms in text with the term as
+// written: different capitalization, small misspellings ("Kubernets") and a
+// single-word term split in two ("Git Hub"). Terms under five letters are
+// only fixed up for case, and under four not at all, so common words aren't
+// rewritten.
+func CorrectTerms(text string, terms []string) string {
+	for _, term := range terms {
+		text = correctTerm(text, term)
+	}
+	return text
+}
+
+func correctTerm(text, term string) string {
+	termWords := strings.Fields(term)
+	if len(termWords) == 0 {
+		return text
+	}
+	want := strings.ToLower(strings.Join(termWords, " "))
+	maxDist := 0
+	switch n := utf8.RuneCountInString(want); {
+	case n < 4:
+		return text
+	case n >= 8:
+		maxDist = 2
+	case n >= 5:
+		maxDist = 1
+	}
+
+	spans := wordSpanRe.FindAllStringIndex(text, -1)
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(spans); {
+		matched := 0
+		// Try the term's own word count, then one more word for a
+		// single-word term split in two.
+		for _, n := range []int{len(termWords), len(termWords) + 1} {
+			if n > len(termWords) && len(termWords) != 1 || i+n > len(spans) || !spaced(text, spans[i:i+n]) {
+				continue
+			}
+			got := text[spans[i][0]:spans[i+n-1][1]]
+			cand, dist := strings.ToLower(got), maxDist
+			if n > len(termWords) {
+				// Joined words must spell the term exactly
+				cand, dist = strings.ReplaceAll(cand, " ", ""), 0
+			}
+			if levenshtein(cand, want) <= dist {
+				matched = n
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		b.WriteString(text[last:spans[i][0]])
+		b.WriteString(term)
+		last = spans[i+matched-1][1]
+		i += matched
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// spaced reports whether consecutive word spans are separated by a single
+// space, i.e. read as one phrase.
+func spaced(text string, spans [][]int) bool {
+	for i := 1; i < len(spans); i++ {
+		if text[spans[i-1][1]:spans[i][0]] != " " {
+			return false
+		}
+	}
+	return true
+}
+
+// levenshtein is the edit distance between a and b, counted in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// correctVocabulary applies CorrectTerms to the transcript and its
+// segments.
+func correctVocabulary(result Result, terms []string) Result {
+	if len(terms) == 0 {
+		return result
+	}
+	return mapText(result, func(s string) string { return CorrectTerms(s, terms) })
+}
+
+// mapText rewrites the text of each segment with fn and rejoins them, or
+// rewrites the plain transcript when there are no segments.
+func mapText(result Result, fn func(string) string) Result {
+	if len(result.Segments) == 0 {
+		result.Text = fn(result.Text)
+		return result
+	}
+	for i := range result.Segments {
+		result.Segments[i].Text = fn(result.Segments[i].Text)
+	}
+	result.Text = JoinSegments(result.Segments)
+	return result
+}
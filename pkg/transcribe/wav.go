@@ -0,0 +1,218 @@
+package transcribe
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// wavRate is the highest sample rate resampleWAV writes; Gemini works at
+// 16 kHz, so anything above only adds bytes.
+const wavRate = 16000
+
+// wavFormat is the part of a WAV fmt chunk needed to read its samples.
+type wavFormat struct {
+	format     uint16 // 1 for integer PCM, 3 for IEEE float
+	channels   int
+	sampleRate int
+	blockAlign int
+	bits       int
+}
+
+// resampleWAV decodes a PCM or floating-point WAV stream and re-encodes it
+// as 16-bit mono WAV at no more than 16 kHz, without ffmpeg. The channels
+// are averaged, or only channel is kept when it isn't negative. Large
+// recordings from field recorders shrink several times over.
+func resampleWAV(r io.Reader, channel int) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+
+	var f *wavFormat
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, errors.New("WAV file has no data chunk")
+		}
+		id, size := string(hdr[0:4]), binary.LittleEndian.Uint32(hdr[4:8])
+		switch id {
+		case "fmt ":
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(br, body); err != nil || size < 16 {
+				return nil, errors.New("WAV file has a truncated fmt chunk")
+			}
+			f = &wavFormat{
+				format:     binary.LittleEndian.Uint16(body[0:2]),
+				channels:   int(binary.LittleEndian.Uint16(body[2:4])),
+				sampleRate: int(binary.LittleEndian.Uint32(body[4:8])),
+				blockAlign: int(binary.LittleEndian.Uint16(body[12:14])),
+				bits:       int(binary.LittleEndian.Uint16(body[14:16])),
+			}
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format in its sub-format GUID
+			if f.format == 0xFFFE && size >= 26 {
+				f.format = binary.LittleEndian.Uint16(body[24:26])
+			}
+		case "data":
+			if f == nil {
+				return nil, errors.New("WAV data comes before its fmt chunk")
+			}
+			if err := f.check(channel); err != nil {
+				return nil, err
+			}
+			// Streamed WAVs leave the size at 0 or the maximum; read to EOF
+			var data io.Reader = br
+			if size != 0 && size != math.MaxUint32 {
+				data = io.LimitReader(br, int64(size))
+			}
+			return f.resample(data, channel)
+		default:
+			if _, err := br.Discard(int(size + size%2)); err != nil {
+				return nil, errors.New("WAV file is truncated")
+			}
+		}
+	}
+}
+
+// check reports whether the samples can be decoded and channel exists.
+func (f *wavFormat) check(channel int) error {
+	switch {
+	case f.format == 1 && (f.bits == 8 || f.bits == 16 || f.bits == 24 || f.bits == 32):
+	case f.format == 3 && (f.bits == 32 || f.bits == 64):
+	default:
+		return fmt.Errorf("unsupported WAV encoding (format %d, %d bits)", f.format, f.bits)
+	}
+	if f.channels < 1 || f.sampleRate < 1 || f.blockAlign < f.channels*f.bits/8 {
+		return errors.New("invalid WAV format")
+	}
+	if channel >= f.channels {
+		return fmt.Errorf("channel %d requested but the WAV file has %d", channel, f.channels)
+	}
+	return nil
+}
+
+// mix returns the sample of channel in frame, or the average of all
+// channels when channel is negative.
+func (f *wavFormat) mix(frame []byte, channel int) float64 {
+	width := f.bits / 8
+	if channel >= 0 {
+		return f.sample(frame[channel*width:])
+	}
+	var v float64
+	for c := 0; c < f.channels; c++ {
+		v += f.sample(frame[c*width:])
+	}
+	return v / float64(f.channels)
+}
+
+// sample decodes one sample to the range -1 to 1.
+func (f *wavFormat) sample(b []byte) float64 {
+	switch {
+	case f.format == 3 && f.bits == 32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case f.format == 3:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case f.bits == 8:
+		return (float64(b[0]) - 128) / 128
+	case f.bits == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case f.bits == 24:
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+	}
+	return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+}
+
+// resample reads frames from data and mixes them down. When the rate
+// comes down they're low-pass filtered below the new Nyquist frequency
+// before every step-th one is kept, so hiss and sibilance above it don't
+// fold back into the speech band.
+func (f *wavFormat) resample(data io.Reader, channel int) ([]byte, error) {
+	outRate := min(f.sampleRate, wavRate)
+	var out bytes.Buffer
+	out.Write(make([]byte, 44)) // header, filled in at the end
+
+	taps := lowpass(float64(outRate) / float64(f.sampleRate))
+	size, half := int64(len(taps)), int64(len(taps)/2)
+	// The last len(taps) samples, sample i at i%size and again at
+	// i%size+size, so they can be read in order without wrapping
+	ring := make([]float64, 2*size)
+	step := float64(f.sampleRate) / float64(outRate)
+	var k, due int64 // the next output sample and the input one it falls on
+	frame := make([]byte, f.blockAlign)
+	total := int64(-1)
+	// Read on past the end, with silence, until the filter has covered
+	// the last samples
+	for n := int64(0); total < 0 || n < total+half; n++ {
+		var v float64
+		if total < 0 {
+			if _, err := io.ReadFull(data, frame); err == io.EOF || err == io.ErrUnexpectedEOF {
+				total = n
+			} else if err != nil {
+				return nil, err
+			} else {
+				v = f.mix(frame, channel)
+			}
+		}
+		ring[n%size], ring[n%size+size] = v, v
+		if c := n - half; c == due && (total < 0 || c < total) {
+			var y float64
+			for j, x := range ring[(n+1)%size:][:size] {
+				y += taps[j] * x
+			}
+			y = max(-1, min(1, y))
+			out.Write(binary.LittleEndian.AppendUint16(nil, uint16(int16(math.Round(y*math.MaxInt16)))))
+			k++
+			due = int64(math.Round(float64(k) * step))
+		}
+	}
+
+	b := out.Bytes()
+	dataSize := uint32(len(b) - 44)
+	copy(b[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(b[4:8], 36+dataSize)
+	copy(b[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(b[16:20], 16)
+	binary.LittleEndian.PutUint16(b[20:22], 1) // PCM
+	binary.LittleEndian.PutUint16(b[22:24], 1) // mono
+	binary.LittleEndian.PutUint32(b[24:28], uint32(outRate))
+	binary.LittleEndian.PutUint32(b[28:32], uint32(outRate*2))
+	binary.LittleEndian.PutUint16(b[32:34], 2)
+	binary.LittleEndian.PutUint16(b[34:36], 16)
+	copy(b[36:40], "data")
+	binary.LittleEndian.PutUint32(b[40:44], dataSize)
+	return b, nil
+}
+
+// lowpass returns the taps of a Blackman-windowed sinc filter for
+// resampling by ratio, the output rate over the input rate. It keeps what
+// lies below about 0.4 of the output rate (6.4 kHz at 16 kHz) and stops
+// what lies above half of it, which would alias. At ratio 1 samples pass
+// through as they are.
+func lowpass(ratio float64) []float64 {
+	if ratio >= 1 {
+		return []float64{1}
+	}
+	cutoff := 0.45 * ratio // in cycles per input sample
+	m := int(math.Ceil(18 / ratio))
+	taps := make([]float64, 2*m+1)
+	var sum float64
+	for i := range taps {
+		x := float64(i - m)
+		h := 2 * cutoff
+		if x != 0 {
+			h = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		w := 0.42 + 0.5*math.Cos(math.Pi*x/float64(m)) + 0.08*math.Cos(2*math.Pi*x/float64(m))
+		taps[i] = h * w
+		sum += taps[i]
+	}
+	for i := range taps {
+		taps[i] /= sum
+	}
+	return taps
+}
@@ -0,0 +1,99 @@
+package transcribe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// sineWAV returns a 16-bit WAV of a sine wave at freq Hz on each channel,
+// seconds long.
+func sineWAV(rate, channels int, freq, seconds float64) []byte {
+	n := int(float64(rate) * seconds)
+	data := make([]byte, 0, n*channels*2)
+	for i := 0; i < n; i++ {
+		v := int16(0.5 * math.MaxInt16 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+		for c := 0; c < channels; c++ {
+			data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		}
+	}
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+len(data)))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*2))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*2))
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// wavRMS returns the sample rate and the RMS level of the middle of a
+// 16-bit mono WAV, away from the edges.
+func wavRMS(t *testing.T, wav []byte) (int, float64) {
+	t.Helper()
+	if len(wav) < 44 || string(wav[0:4]) != "RIFF" || binary.LittleEndian.Uint16(wav[22:24]) != 1 {
+		t.Fatalf("not a mono WAV")
+	}
+	rate := int(binary.LittleEndian.Uint32(wav[24:28]))
+	samples := wav[44:]
+	n := len(samples) / 2
+	var sum float64
+	for i := n / 4; i < 3*n/4; i++ {
+		v := float64(int16(binary.LittleEndian.Uint16(samples[2*i:]))) / math.MaxInt16
+		sum += v * v
+	}
+	return rate, math.Sqrt(sum / float64(n/2))
+}
+
+func TestResampleWAV(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      int
+		channels  int
+		freq      float64
+		wantRate  int
+		minRMS    float64
+		maxRMS    float64
+		wantFrame int
+	}{
+		// A 0.5 sine has an RMS of 0.354
+		{"speech band kept", 48000, 2, 1000, 16000, 0.33, 0.37, 16000},
+		{"odd rate", 44100, 1, 1000, 16000, 0.33, 0.37, 16000},
+		{"above the new Nyquist filtered out", 48000, 1, 12000, 16000, 0, 0.01, 16000},
+		{"just above the new Nyquist filtered out", 44100, 1, 9000, 16000, 0, 0.02, 16000},
+		{"low rate kept", 8000, 1, 1000, 8000, 0.33, 0.37, 8000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := resampleWAV(bytes.NewReader(sineWAV(tt.rate, tt.channels, tt.freq, 1)), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rate, rms := wavRMS(t, out)
+			if rate != tt.wantRate {
+				t.Errorf("rate = %d, want %d", rate, tt.wantRate)
+			}
+			if frames := (len(out) - 44) / 2; frames != tt.wantFrame {
+				t.Errorf("%d samples, want %d", frames, tt.wantFrame)
+			}
+			if rms < tt.minRMS || rms > tt.maxRMS {
+				t.Errorf("RMS = %.4f, want %.3f to %.3f", rms, tt.minRMS, tt.maxRMS)
+			}
+		})
+	}
+}
+
+func TestResampleWAVErrors(t *testing.T) {
+	if _, err := resampleWAV(bytes.NewReader([]byte("ID3\x03not a wav")), -1); err == nil {
+		t.Error("resampleWAV(mp3) = nil error")
+	}
+	if _, err := resampleWAV(bytes.NewReader(sineWAV(16000, 1, 440, 0.1)), 1); err == nil {
+		t.Error("resampleWAV(mono, channel 1) = nil error")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// builtinPresets are the prompts selectable with --preset. Presets in the
+// config file with the same name take precedence.
+var builtinPresets = map[string]string{
+	"verbatim": "Transcribe this audio verbatim. Keep every word as spoken, including filler words (um, uh, like), false starts, repetitions and stutters. " +
+		"Mark unintelligible passages as [inaudible] and non-speech sounds such as [laughter] or [music] in brackets. Output only the transcription, no extra commentary.",
+	"clean": "Transcribe this audio as clean, readable text. Remove filler words, false starts and repetitions, fix obvious grammar slips without changing the meaning, " +
+		"and add punctuation and paragraph breaks. Output only the transcription, no extra commentary.",
+	"meeting-minutes": "Transcribe this meeting recording, then write minutes. Start with the transcript, labelling speakers as Speaker 1, Speaker 2, ... (or by name when they are introduced). " +
+		"Follow it with a \"Minutes\" section listing the topics discussed, decisions made and action items with their owners.",
+	"lyrics": "Transcribe the lyrics of this song. Put each sung line on its own line and separate verses and choruses with a blank line. " +
+		"Ignore instrumental passages. Output only the lyrics, no extra commentary.",
+	"interview": "Transcribe this interview. Start a new paragraph at every change of speaker and prefix it with the speaker's label, " +
+		"using Interviewer and Guest (or their names when they are introduced). Remove filler words but keep the wording otherwise. Output only the transcription, no extra commentary.",
+}
+
+// presetNames lists the built-in presets in a stable order for help text.
+func presetNames() []string {
+	return sortedKeys(builtinPresets)
+}
+
+// preset returns the prompt for name from the config file or the built-ins.
+func (cfg config) preset(name string) (string, error) {
+	if p, ok := cfg.Presets[name]; ok {
+		return strings.TrimSpace(p), nil
+	}
+	if p, ok := builtinPresets[name]; ok {
+		return p, nil
+	}
+	names := presetNames()
+	for n := range cfg.Presets {
+		if _, ok := builtinPresets[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	return "", fmt.Errorf("unknown preset %q (have: %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// progressBarWidth is the number of cells in the bar.
+const progressBarWidth = 30
+
+// progressBar draws download, conversion and upload progress with an ETA
+// on one stderr line, redrawing it at most a few times a second and moving
+// to a new line when a step finishes.
+type progressBar struct {
+	// name is the file being downloaded, for the "download" stage
+	name     string
+	mu       sync.Mutex
+	stage    string
+	start    time.Time
+	drawn    time.Time
+	finished bool
+}
+
+func (b *progressBar) update(p transcribe.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	finished := p.Total > 0 && p.Done >= p.Total
+	// A new step, or the same step again for the next chunk
+	if p.Stage != b.stage || b.finished && !finished {
+		b.stage = p.Stage
+		b.start = time.Now()
+		b.drawn = time.Time{}
+		b.finished = false
+	}
+	if b.finished || !finished && time.Since(b.drawn) < 200*time.Millisecond {
+		return
+	}
+	b.drawn = time.Now()
+	fmt.Fprintf(os.Stderr, "\r%s\033[K", b.line(p))
+	if finished {
+		fmt.Fprintln(os.Stderr)
+		b.finished = true
+	}
+}
+
+// line renders p, e.g. "Uploading [#########.....] 62% 18.6 / 30.0 MB, ETA 0:07".
+func (b *progressBar) line(p transcribe.Progress) string {
+	label, amount := "Converting", formatClock(p.Done)
+	bytes := p.Stage == "upload" || p.Stage == "download"
+	switch p.Stage {
+	case "download":
+		label = "Downloading " + b.name
+	case "upload":
+		label = "Uploading"
+	case "burn-in":
+		label = "Burning in subtitles"
+	}
+	if bytes {
+		amount = fmt.Sprintf("%.1f", p.Done/1e6)
+	}
+	if p.Total <= 0 {
+		if bytes {
+			amount += " MB"
+		}
+		return label + " " + amount
+	}
+
+	frac := min(p.Done/p.Total, 1)
+	filled := int(frac * progressBarWidth)
+	total := formatClock(p.Total)
+	if bytes {
+		total = fmt.Sprintf("%.1f MB", p.Total/1e6)
+	}
+	line := fmt.Sprintf("%s [%s%s] %3.0f%% %s / %s", label,
+		strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), frac*100, amount, total)
+	if elapsed := time.Since(b.start).Seconds(); frac > 0 && frac < 1 && elapsed >= 1 {
+		line += ", ETA " + formatClock(elapsed/frac-elapsed)
+	}
+	return line
+}
+
+// downloadProgress counts the bytes of a download written through it and
+// shows them on a progressBar.
+type downloadProgress struct {
+	bar         progressBar
+	done, total int64
+}
+
+func newDownloadProgress(name string, total int64) *downloadProgress {
+	return &downloadProgress{bar: progressBar{name: name}, total: total}
+}
+
+func (d *downloadProgress) Write(b []byte) (int, error) {
+	d.done += int64(len(b))
+	d.bar.update(transcribe.Progress{Stage: "download", Done: float64(d.done), Total: float64(d.total)})
+	return len(b), nil
+}
+
+// finish completes the line, which a download of unknown size leaves
+// open.
+func (d *downloadProgress) finish() {
+	if d.done > 0 {
+		d.bar.update(transcribe.Progress{Stage: "download", Done: float64(d.done), Total: float64(d.done)})
+	}
+}
+
+// formatClock formats seconds as m:ss, or h:mm:ss from an hour up.
+func formatClock(s float64) string {
+	t := int(s + 0.5)
+	if t >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", t/3600, t/60%60, t%60)
+	}
+	return fmt.Sprintf("%d:%02d", t/60, t%60)
+}
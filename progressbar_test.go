@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+func TestProgressBarLine(t *testing.T) {
+	tests := []struct {
+		p    transcribe.Progress
+		want string
+	}{
+		{transcribe.Progress{Stage: "convert", Done: 30, Total: 120}, "Converting [#######.......................]  25% 0:30 / 2:00"},
+		{transcribe.Progress{Stage: "convert", Done: 75}, "Converting 1:15"},
+		{transcribe.Progress{Stage: "upload", Done: 15e6, Total: 30e6}, "Uploading [###############...............]  50% 15.0 / 30.0 MB"},
+		{transcribe.Progress{Stage: "download", Done: 3e6, Total: 3e6}, "Downloading talk.mp3 [##############################] 100% 3.0 / 3.0 MB"},
+		{transcribe.Progress{Stage: "download", Done: 2.5e6}, "Downloading talk.mp3 2.5 MB"},
+	}
+	for _, tt := range tests {
+		b := progressBar{name: "talk.mp3", start: time.Now()}
+		if got := b.line(tt.p); got != tt.want {
+			t.Errorf("line(%+v) = %q, want %q", tt.p, got, tt.want)
+		}
+	}
+}
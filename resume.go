@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// batchStateFile holds a batch run's progress in the output directory (or
+// the current one), so --resume can pick up where it left off.
+const batchStateFile = "gemini-transcribe-batch.json"
+
+// batchState records how far a batch run got: each input with its status,
+// "done", "failed" or "pending", and the output written for it. Local
+// paths are stored absolute, so --resume finds them from any directory.
+type batchState struct {
+	Inputs []batchEntry `json:"inputs"`
+}
+
+type batchEntry struct {
+	Input  string `json:"input"`
+	Status string `json:"status"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchStatePath is where the state file for outputs in outDir lives.
+func batchStatePath(outDir string) string {
+	return filepath.Join(outDir, batchStateFile)
+}
+
+// loadBatchState reads the state file left in outDir by an earlier run.
+// It returns its inputs in order and the ones already done, mapped to their
+// outputs. Done inputs whose output has since been deleted are redone.
+func loadBatchState(outDir string) ([]string, map[string]string, error) {
+	path := batchStatePath(outDir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, notFoundError("nothing to resume: " + path + " not found")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var state batchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	if len(state.Inputs) == 0 {
+		return nil, nil, fmt.Errorf("reading %s: no inputs", path)
+	}
+
+	inputs := make([]string, len(state.Inputs))
+	done := map[string]string{}
+	for i, e := range state.Inputs {
+		inputs[i] = e.Input
+		if e.Status != "done" {
+			continue
+		}
+		if _, err := os.Stat(e.Output); err == nil {
+			done[e.Input] = e.Output
+		}
+	}
+	return inputs, done, nil
+}
+
+// batchProgress keeps the state file in step with a batch run, rewriting
+// it as each file finishes so a crash loses only the files in progress.
+type batchProgress struct {
+	mu    sync.Mutex
+	path  string
+	state batchState
+}
+
+// newBatchProgress writes the initial state for inputs, with the ones in
+// done already marked. When the file can't be written the run goes on
+// without it, as it does with an empty path.
+func newBatchProgress(path string, inputs []string, done map[string]string) *batchProgress {
+	p := &batchProgress{path: path, state: batchState{Inputs: make([]batchEntry, len(inputs))}}
+	for i, input := range inputs {
+		p.state.Inputs[i] = batchEntry{Input: absPath(input), Status: "pending"}
+		if out, ok := done[input]; ok {
+			p.state.Inputs[i] = batchEntry{Input: absPath(input), Status: "done", Output: absPath(out)}
+		}
+	}
+	p.save()
+	return p
+}
+
+// finish records the outcome of input i. Interrupted inputs stay pending.
+func (p *batchProgress) finish(i int, o batchOutcome) {
+	if o.interrupted {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := &p.state.Inputs[i]
+	if o.err != nil {
+		e.Status, e.Output, e.Error = "failed", "", o.err.Error()
+	} else {
+		e.Status, e.Output, e.Error = "done", absPath(o.outPath), ""
+	}
+	p.save()
+}
+
+// close removes the state file once every input is done, or otherwise
+// says how to carry on.
+func (p *batchProgress) close(complete bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.path == "" {
+		return
+	}
+	if complete {
+		os.Remove(p.path)
+		return
+	}
+	infof("Progress saved to %s; run again with --resume to finish the remaining files", p.path)
+}
+
+// save writes the state file, giving up on it after the first failure.
+// It's written beside the old one and renamed over it, so a crash while
+// saving leaves the last complete state.
+func (p *batchProgress) save() {
+	if p.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err == nil {
+		tmp := p.path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0644); err == nil {
+			err = os.Rename(tmp, p.path)
+		}
+	}
+	if err != nil {
+		warnf("Warning: can't save batch progress: %v", err)
+		p.path = ""
+	}
+}
+
+// absPath makes a local path absolute, leaving stdin, URLs and bucket
+// objects as they are.
+func absPath(path string) string {
+	if path == "" || path == "-" || isURL(path) || isObject(path) {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBatchStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	os.WriteFile("done.txt", []byte("hello\n"), 0644)
+
+	inputs := []string{"a.mp3", "b.mp3", "https://example.com/c.mp3"}
+	p := newBatchProgress(batchStatePath(dir), inputs, nil)
+	p.finish(0, batchOutcome{outPath: "done.txt"})
+	p.finish(1, batchOutcome{err: os.ErrPermission})
+	if _, err := os.Stat(batchStatePath(dir) + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary state file left behind: %v", err)
+	}
+
+	// Resume from another directory
+	t.Chdir(t.TempDir())
+	got, done, err := loadBatchState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.mp3"), filepath.Join(dir, "b.mp3"), "https://example.com/c.mp3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inputs = %q, want %q", got, want)
+	}
+	wantDone := map[string]string{want[0]: filepath.Join(dir, "done.txt")}
+	if !reflect.DeepEqual(done, wantDone) {
+		t.Errorf("done = %q, want %q", done, wantDone)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleEntry is one rule in a --rules file: literal text to find or a
+// regular expression, and what to replace it with.
+type ruleEntry struct {
+	Find       string `yaml:"find"`
+	Regex      string `yaml:"regex"`
+	Replace    string `yaml:"replace"`
+	IgnoreCase bool   `yaml:"ignore_case"`
+}
+
+// loadRules reads a --rules file, a YAML list of find/replace rules
+// applied in the order given.
+func loadRules(path string) ([]transcribe.Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ruleEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	rules := make([]transcribe.Rule, 0, len(entries))
+	for i, e := range entries {
+		if (e.Find == "") == (e.Regex == "") {
+			return nil, fmt.Errorf("%s: rule %d needs exactly one of find or regex", path, i+1)
+		}
+		expr := e.Regex
+		if expr == "" && e.IgnoreCase {
+			expr = regexp.QuoteMeta(e.Find)
+		}
+		if expr == "" {
+			rules = append(rules, transcribe.Rule{Find: e.Find, Replace: e.Replace})
+			continue
+		}
+		if e.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %v", path, i+1, err)
+		}
+		replace := e.Replace
+		if e.Regex == "" {
+			// A literal replacement mustn't expand $ references
+			replace = strings.ReplaceAll(replace, "$", "$$")
+		}
+		rules = append(rules, transcribe.Rule{Pattern: re, Replace: replace})
+	}
+	return rules, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	os.WriteFile(path, []byte(`
+- find: Jiminy
+  replace: Gemini
+- find: "cost $5"
+  replace: "cost $$5 ($1)"
+  ignore_case: true
+- regex: '(\d+) per cent'
+  replace: '${1}%'
+- find: Gemini
+  replace: Gemini API
+`), 0644)
+	rules, err := loadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := transcribe.ApplyRules("Jiminy said it'd COST $5, about 20 per cent more.", rules)
+	want := "Gemini API said it'd cost $$5 ($1), about 20% more."
+	if got != want {
+		t.Errorf("ApplyRules() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"neither", "- replace: x", "rule 1 needs exactly one of find or regex"},
+		{"both", "- find: a\n  regex: b", "rule 1 needs exactly one of find or regex"},
+		{"bad regex", "- find: a\n- regex: '(unclosed'", "rule 2: error parsing regexp"},
+		{"not a list", "find: a", "reading"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.yaml")
+			os.WriteFile(path, []byte(tt.yaml), 0644)
+			if _, err := loadRules(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadRules() err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
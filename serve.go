@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// server answers transcription requests with a shared client. defaults
+// holds the flag-configured options a request can override per call.
+type server struct {
+	client    *transcribe.Client
+	defaults  options
+	maxUpload int64
+	jobs      *jobQueue
+	metrics   *metrics
+
+	// tokens, when set, are the clients allowed in; allowClientKeys lets
+	// a request pay with its own Gemini key
+	tokens          []clientToken
+	allowClientKeys bool
+}
+
+// serveFlags are the serve command's flag values.
+type serveFlags struct {
+	addr        string
+	grpcAddr    string
+	jobsDir     string
+	workers     int
+	tokensFile  string
+	clientKeys  bool
+	noUI        bool
+	promptFile  string
+	maxUploadMB int64
+	opts        options
+	g           globalFlags
+}
+
+// flagSet returns the serve command's flags, bound to v.
+func (v *serveFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.addr, "addr", ":8080", "Address to listen on")
+	fs.StringVar(&v.grpcAddr, "grpc-addr", "", "Also serve the gRPC Transcriber service (proto/transcriber.proto) on this address")
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Default Gemini model")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Default Gemini model")
+	fs.StringVar(&v.opts.Prompt, "p", "", "Default prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.opts.Prompt, "prompt", "", "Default prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.promptFile, "prompt-file", "", "Read the default prompt from a file (or set GEMINI_PROMPT_FILE)")
+	fs.Int64Var(&v.maxUploadMB, "max-upload-mb", 500, "Largest accepted upload in MB")
+	fs.StringVar(&v.jobsDir, "jobs-dir", defaultJobsDir(), "Directory that keeps the POST /jobs queue across restarts")
+	fs.IntVar(&v.workers, "workers", 2, "Number of queued jobs transcribed at once")
+	fs.StringVar(&v.tokensFile, "tokens", "", "File of client names and bearer tokens, one pair per line; requests must then send a token")
+	fs.BoolVar(&v.clientKeys, "allow-client-keys", false, "Let requests use their own Gemini API key, sent in X-Gemini-Api-Key")
+	fs.BoolVar(&v.noUI, "no-ui", false, "Don't serve the web upload page at /")
+	fs.StringVar(&v.opts.webhook, "webhook", "", "POST a JSON notification to this URL as each request completes or fails")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe serve [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Serves POST /transcribe (multipart field \"file\") and returns JSON transcripts, or queues\n")
+		fmt.Fprintf(os.Stderr, "the upload with POST /jobs for GET /jobs/{id} to report on.\n")
+		fmt.Fprintf(os.Stderr, "Also serves the OpenAI-compatible POST /v1/audio/transcriptions, a WebSocket for live\n")
+		fmt.Fprintf(os.Stderr, "captions at /stream, gRPC with --grpc-addr, and a web upload page at /.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runServe implements the serve subcommand.
+func runServe(args []string) {
+	var v serveFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	opts, g := v.opts, v.g
+	g.initLogging(true)
+	opts.verbose = g.verbose
+	opts.proxy = g.proxy
+
+	if opts.Prompt == "" {
+		var err error
+		opts.Prompt, err = resolvePrompt(v.promptFile)
+		if err != nil {
+			errorf("Error reading prompt: %v", err)
+			os.Exit(1)
+		}
+	}
+	opts.format = "json"
+	opts.Upload = "auto"
+	opts.ChunkDuration = transcribe.DefaultChunkDuration
+	opts.ChunkOverlap = transcribe.DefaultChunkOverlap
+	opts.MaxContinuations = transcribe.DefaultMaxContinuations
+
+	client := g.newClient()
+	if opts.verbose {
+		client.Logf = debugf
+	}
+
+	if v.workers < 1 {
+		errorf("Error: --workers must be at least 1")
+		os.Exit(1)
+	}
+	jobs, err := openJobQueue(v.jobsDir)
+	if err != nil {
+		errorf("Error: opening the job queue: %v", err)
+		os.Exit(1)
+	}
+
+	s := &server{client: client, defaults: opts, maxUpload: v.maxUploadMB << 20, jobs: jobs, metrics: newMetrics(), allowClientKeys: v.clientKeys}
+	if v.tokensFile != "" {
+		if s.tokens, err = loadTokens(v.tokensFile); err != nil {
+			errorf("Error reading tokens: %v", err)
+			os.Exit(1)
+		}
+		infof("Requiring a token from %d client(s)", len(s.tokens))
+	} else if !isLoopback(v.addr) {
+		warnf("Warning: serving %s without --tokens; anyone who can reach it can spend your API quota", v.addr)
+	}
+	s.metrics.queueDepth = jobs.depth
+	s.runJobWorkers(v.workers)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /transcribe", s.handleTranscribe)
+	mux.HandleFunc("POST /v1/audio/transcriptions", s.handleOpenAITranscription)
+	mux.HandleFunc("GET /stream", s.handleLiveStream)
+	mux.HandleFunc("POST /jobs", s.handleSubmitJob)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("GET /jobs/{id}/result", s.handleGetJobResult)
+	mux.HandleFunc("GET /models", s.handleModels)
+	mux.HandleFunc("GET /metrics", s.metrics.handle)
+	if !v.noUI {
+		mux.HandleFunc("GET /{$}", handleWebUI)
+	}
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		b := currentBuild()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"version": b.Version, "commit": b.Commit, "date": b.Date})
+	})
+
+	if v.grpcAddr != "" {
+		go func() {
+			if err := s.serveGRPC(v.grpcAddr); err != nil {
+				errorf("Error: gRPC server: %v", err)
+				os.Exit(1)
+			}
+		}()
+		infof("Serving gRPC on %s", v.grpcAddr)
+	}
+	infof("Listening on %s (version %s)", v.addr, currentBuild().Version)
+	if err := http.ListenAndServe(v.addr, withVersion(s.withAuth(mux))); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// withVersion adds an X-Gemini-Transcribe-Version header to every response,
+// so a transcript can be traced back to the build that produced it.
+func withVersion(h http.Handler) http.Handler {
+	v := currentBuild().Version
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Gemini-Transcribe-Version", v)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// handleTranscribe accepts a multipart upload with the audio in "file" and
+// optional "model", "prompt", "format" (json, text, srt, vtt) and
+// "translate" fields.
+func (s *server) handleTranscribe(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("missing audio in multipart field \"file\": %v", err))
+		return
+	}
+	defer file.Close()
+
+	opts, err := s.uploadOptions(r.FormValue)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The pipeline works on files; keep the extension for MIME detection.
+	tmpPath, err := saveUpload("", file, header.Filename)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.Remove(tmpPath)
+
+	result, _, err := s.transcribeUpload(r.Context(), "transcribe", tmpPath, header.Filename, opts)
+	if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+		errorf("POST /transcribe %s: %v", header.Filename, err)
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	infof("POST /transcribe %s (%d bytes) in %s", header.Filename, header.Size, time.Since(start).Round(time.Millisecond))
+
+	writeRendered(w, result, opts)
+}
+
+// writeRendered answers with the result in opts.format.
+func writeRendered(w http.ResponseWriter, result jsonResult, opts options) {
+	switch opts.format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+	case "vtt":
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	io.WriteString(w, renderResult(result, opts))
+}
+
+// uploadOptions applies an upload's optional "model", "prompt", "format",
+// "translate" and "language" fields, read with field, to the server's
+// defaults.
+func (s *server) uploadOptions(field func(string) string) (options, error) {
+	opts := s.defaults
+	if v := field("model"); v != "" {
+		opts.Model = v
+	}
+	if v := field("prompt"); v != "" {
+		opts.Prompt = v
+	}
+	if v := field("format"); v != "" {
+		opts.format = v
+	}
+	if v := field("translate"); v != "" {
+		opts.TranslateTo = v
+	}
+	if v := field("language"); v != "" {
+		opts.Language = v
+	}
+	switch opts.format {
+	case "text":
+	case "json":
+		opts.Timestamps = true
+		opts.DetectLanguage = true
+	case "srt", "vtt":
+		opts.Timestamps = true
+	default:
+		return opts, fmt.Errorf("unknown format %q (want json, text, srt or vtt)", opts.format)
+	}
+	return opts, nil
+}
+
+// transcribeUpload transcribes one upload, named name, that came in
+// through source (the endpoint), records it in the metrics and reports it
+// to the --webhook once done. Each request counts its own usage, which is
+// returned with the result.
+func (s *server) transcribeUpload(ctx context.Context, source, path, name string, opts options) (jsonResult, transcribe.StatsSnapshot, error) {
+	start := time.Now()
+	client := s.clientFor(ctx)
+	result, err := transcribeFile(ctx, client, path, opts)
+	result.File = name
+	snap := client.Stats.Snapshot()
+	s.client.Stats.Merge(snap)
+	model := result.Model
+	if model == "" {
+		model = opts.Model
+	}
+	s.metrics.observe(source, model, result.Duration, snap, time.Since(start), err)
+	// The response doesn't wait for the webhook
+	go sendWebhook(opts, newWebhookPayload(result, "", snap, time.Since(start), err))
+	return result, snap, err
+}
+
+// saveUpload copies an uploaded file to a new file in dir (the temp
+// directory when empty) with the same extension.
+func saveUpload(dir string, src io.Reader, name string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	tmp, err := os.CreateTemp(dir, "gemini-transcribe-upload-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
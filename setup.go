@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// setupChoice is one numbered answer offered by the setup wizard.
+type setupChoice struct {
+	value, description string
+}
+
+var (
+	setupModels = []setupChoice{
+		{"gemini-2.5-flash", "fast and inexpensive, good for most recordings"},
+		{"gemini-2.5-pro", "most accurate, slower and about four times the price"},
+		{"gemini-2.5-flash-lite", "cheapest, for clear speech"},
+	}
+	setupFormats = []setupChoice{
+		{"text", "plain text"},
+		{"srt", "subtitles with timestamps"},
+		{"vtt", "web subtitles with timestamps"},
+		{"json", "text plus timed segments, for scripts"},
+	}
+)
+
+// initFlagSet returns the init command's flags.
+func initFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe init\n\n")
+		fmt.Fprintf(os.Stderr, "Asks for your API key, a default model and output format, stores the key\n")
+		fmt.Fprintf(os.Stderr, "in the OS keyring (or ~/.config/gemini/api_key) and writes the config file.\n")
+	}
+	return fs
+}
+
+// runInit implements the init subcommand.
+func runInit(args []string) {
+	fs := initFlagSet()
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := runSetup(bufio.NewReader(os.Stdin)); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// offerSetup asks whether to run the setup wizard, for a first run on a
+// terminal without any API key or config file, and runs it if so.
+func offerSetup() {
+	in := bufio.NewReader(os.Stdin)
+	fmt.Fprintln(os.Stderr, "No Gemini API key is set up yet.")
+	if !confirm(in, "Set one up now?", true) {
+		return
+	}
+	if err := runSetup(in); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// runSetup walks through the key, model and format and saves them.
+func runSetup(in *bufio.Reader) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.exists && !confirm(in, fmt.Sprintf("%s already exists. Replace it?", cfg.path), false) {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Get a free API key at https://aistudio.google.com/apikey")
+	existing, _ := resolveAPIKey("")
+	var key string
+	for key == "" {
+		label := "Gemini API key: "
+		if existing != "" {
+			label = "Gemini API key (Enter keeps the current one): "
+		}
+		if key, err = readSecret(in, label); err != nil {
+			return err
+		}
+		if key == "" && existing != "" {
+			break
+		}
+		if key != "" && !validKeyringKey(key) {
+			fmt.Fprintln(os.Stderr, "That doesn't look like an API key.")
+			key = ""
+			continue
+		}
+		if key != "" && !checkKey(key) {
+			key = ""
+		}
+	}
+
+	model := choose(in, "Default model", setupModels)
+	format := choose(in, "Default output format", setupFormats)
+
+	if key != "" {
+		if err := saveKey(key); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.path), 0755); err != nil {
+		return err
+	}
+	data := fmt.Sprintf(setupTemplate, model, format)
+	if err := os.WriteFile(cfg.path, []byte(data), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n\n", cfg.path)
+	fmt.Fprintln(os.Stderr, "You're set. Try: gemini-transcribe -i recording.mp3")
+	return nil
+}
+
+// setupTemplate is the config file written by init, with the chosen model
+// and format filled in.
+const setupTemplate = `# gemini-transcribe configuration, written by gemini-transcribe init.
+# Flags on the command line take precedence over the profile in use.
+
+default_profile: default
+
+profiles:
+  default:
+    model: %s
+    format: %s
+
+presets:
+  # podcast: Transcribe this podcast episode, labelling each host by name.
+`
+
+// checkKey lists models with key to catch a mistyped key, reporting false
+// only when the API rejects it.
+func checkKey(key string) bool {
+	fmt.Fprint(os.Stderr, "Checking the key... ")
+	client := transcribe.NewClient(key)
+	client.BaseURL = resolveBaseURL("")
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	_, err := client.ListModels(ctx)
+	switch {
+	case err == nil:
+		fmt.Fprintln(os.Stderr, "ok")
+	case errors.Is(err, transcribe.ErrAuth):
+		fmt.Fprintln(os.Stderr, "the API rejected it, please try again.")
+		return false
+	default:
+		fmt.Fprintf(os.Stderr, "couldn't check it (%v), keeping it anyway.\n", err)
+	}
+	return true
+}
+
+// saveKey stores key in the OS keyring, or in ~/.config/gemini/api_key
+// when there is no keyring.
+func saveKey(key string) error {
+	err := keyringSet(key)
+	if err == nil {
+		fmt.Fprintln(os.Stderr, "Stored the API key in the OS keyring")
+		return nil
+	}
+	home, herr := os.UserHomeDir()
+	if herr != nil {
+		return herr
+	}
+	path := filepath.Join(home, ".config", "gemini", "api_key")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Stored the API key in %s (no OS keyring: %v)\n", path, err)
+	return nil
+}
+
+// choose offers numbered choices and returns the picked value; Enter takes
+// the first. A value typed out in full is accepted too.
+func choose(in *bufio.Reader, question string, choices []setupChoice) string {
+	fmt.Fprintf(os.Stderr, "\n%s:\n", question)
+	for i, c := range choices {
+		fmt.Fprintf(os.Stderr, "  %d) %-22s %s\n", i+1, c.value, c.description)
+	}
+	for {
+		answer := ask(in, fmt.Sprintf("Choose 1-%d [1]: ", len(choices)))
+		if answer == "" {
+			return choices[0].value
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1].value
+		}
+		for _, c := range choices {
+			if strings.EqualFold(answer, c.value) {
+				return c.value
+			}
+		}
+	}
+}
+
+// confirm asks a yes/no question, returning def for an empty answer.
+func confirm(in *bufio.Reader, question string, def bool) bool {
+	hint := " [y/N] "
+	if def {
+		hint = " [Y/n] "
+	}
+	switch strings.ToLower(ask(in, question+hint)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// ask prints prompt and reads one trimmed line; end of input reads as an
+// empty answer.
+func ask(in *bufio.Reader, prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+	}
+	return strings.TrimSpace(line)
+}
+
+// firstRun reports whether this looks like a first run worth offering the
+// setup wizard: no API key, no config file, and someone at a terminal.
+func firstRun() bool {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		return false
+	}
+	if _, err := resolveAPIKey(""); err == nil {
+		return false
+	}
+	cfg, err := loadConfig()
+	return err == nil && !cfg.exists
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/objstore"
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// objectStore reads s3:// and gs:// inputs and writes outputs there. S3
+// uses the AWS environment variables and ~/.aws files, Cloud Storage the
+// Application Default Credentials; newClient gives it the proxy.
+var objectStore = &objstore.Store{
+	NewGCSTokens: func() (objstore.TokenSource, error) {
+		return transcribe.NewTokenSource(nil)
+	},
+}
+
+// isObject reports whether a path names a cloud storage object or prefix.
+func isObject(path string) bool {
+	return objstore.IsURI(path)
+}
+
+// downloadObject streams an s3:// or gs:// object into a temp file with the
+// same extension and returns its path.
+func downloadObject(ctx context.Context, uri string) (string, error) {
+	body, size, err := objectStore.Get(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	tmp, err := os.CreateTemp("", "gemini-transcribe-download-*"+strings.ToLower(path.Ext(uri)))
+	if err != nil {
+		return "", err
+	}
+
+	var src io.Reader = body
+	var p *downloadProgress
+	if showProgress() {
+		p = newDownloadProgress(path.Base(uri), size)
+		src = io.TeeReader(body, p)
+	}
+	_, err = io.Copy(tmp, src)
+	if p != nil {
+		p.finish()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("downloading %s: %w", uri, err)
+	}
+	return tmp.Name(), nil
+}
+
+// listObjects returns the media objects directly under an s3:// or gs://
+// prefix, as expandInputs does for a directory.
+func listObjects(ctx context.Context, prefix string) ([]string, error) {
+	uris, err := objectStore.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var media []string
+	for _, uri := range uris {
+		if mediaExts[strings.ToLower(path.Ext(uri))] {
+			media = append(media, uri)
+		}
+	}
+	return media, nil
+}
+
+// writeOutput writes an output file, uploading it when path is an s3:// or
+// gs:// URI.
+func writeOutput(path string, data []byte) error {
+	if !isObject(path) {
+		return os.WriteFile(path, data, 0644)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	if err := objectStore.Put(context.Background(), path, data, contentType); err != nil {
+		return fmt.Errorf("uploading: %w", err)
+	}
+	return nil
+}
+
+// outputExistsAt reports whether an output file (or object) is already
+// there. Objects that can't be checked count as missing.
+func outputExistsAt(path string) bool {
+	if isObject(path) {
+		ok, err := objectStore.Exists(context.Background(), path)
+		if err != nil && !errors.Is(err, objstore.ErrNotFound) {
+			debugf("Can't check %s: %v", path, err)
+		}
+		return ok
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// makeOutputDir creates a local output directory; object prefixes need
+// none.
+func makeOutputDir(dir string) error {
+	if isObject(dir) {
+		return nil
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// joinOutput puts name in dir, which may be an s3:// or gs:// prefix.
+func joinOutput(dir, name string) string {
+	if isObject(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// templateFuncs are the functions --template files can call besides the
+// text/template built-ins.
+var templateFuncs = template.FuncMap{
+	// timecode formats seconds as HH:MM:SS.mmm, clock as M:SS or H:MM:SS
+	"timecode": func(s float64) string { return transcribe.FormatTimecode(s, ".") },
+	"clock":    formatClock,
+	"srt":      transcribe.FormatSRT,
+	"vtt":      func(segs []transcribe.Segment) string { return transcribe.FormatVTT(segs, "") },
+	"json": func(v any) (string, error) {
+		out, err := json.MarshalIndent(v, "", "  ")
+		return string(out), err
+	},
+	"xml": func(s string) string {
+		var b strings.Builder
+		template.HTMLEscape(&b, []byte(s))
+		return b.String()
+	},
+	"latex":   latexEscape,
+	"join":    strings.Join,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+	"add":     func(a, b int) int { return a + b },
+}
+
+// loadTemplate parses a --template file and tries it on a sample result,
+// so mistakes such as a misspelt field fail before anything is sent. The
+// output extension is the template's own without .tmpl: page.html.tmpl
+// writes .html files.
+func loadTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	sample := jsonResult{
+		File:          "sample.mp3",
+		Model:         defaultModel,
+		Language:      "en",
+		Duration:      4,
+		Transcription: "Hello there.",
+		Summary:       "A greeting.",
+		Segments:      []transcribe.Segment{{Start: 0, End: 4, Text: "Hello there.", Speaker: "Speaker 1", Topic: "Greetings", Words: []transcribe.Word{{Word: "Hello", Start: 0, End: 1}}}},
+		Usage:         &usageJSON{},
+		Title:         "Sample",
+		Chapters:      []chapter{{Start: 0, Title: "Intro"}},
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(strings.TrimSuffix(filepath.Base(path), ".tmpl"))
+	if ext == "" {
+		ext = ".txt"
+	}
+	outputExts["template"] = ext
+	return tmpl, nil
+}
+
+// renderTemplate runs the --template on result. The result's fields are
+// the JSON output's, plus Title and Chapters.
+func renderTemplate(tmpl *template.Template, result jsonResult) string {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, result); err != nil {
+		errorf("Error in --template: %v", err)
+	}
+	return b.String()
+}
+
+// latexEscape escapes the characters LaTeX treats specially.
+func latexEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`, `_`, `\_`,
+		`{`, `\{`, `}`, `\}`,
+		`~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+	).Replace(s)
+}
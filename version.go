@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at link time by release builds:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Whatever is left unset is filled in from the module and VCS information
+// the Go toolchain embeds (go install ...@v1.2.0, or a build in a checkout).
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo is the version the binary reports.
+type buildInfo struct {
+	Version  string
+	Commit   string
+	Date     string
+	Modified bool // built from a checkout with uncommitted changes
+}
+
+// currentBuild returns the version, commit and build date, preferring the
+// link-time values.
+func currentBuild() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, Date: date}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if b.Version == "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				if b.Date == "" {
+					b.Date = s.Value
+				}
+			case "vcs.modified":
+				b.Modified = s.Value == "true" && commit == ""
+			}
+		}
+	}
+	if b.Version == "" {
+		b.Version = "dev"
+	}
+	return b
+}
+
+// String renders the version for --version, e.g.
+// "gemini-transcribe v1.2.0 (commit 1a2b3c4, built 2026-01-02T03:04:05Z, go1.25.5)".
+func (b buildInfo) String() string {
+	s := "gemini-transcribe " + b.Version + " ("
+	if b.Commit != "" {
+		c := b.Commit
+		if len(c) > 7 {
+			c = c[:7]
+		}
+		if b.Modified {
+			c += "-dirty"
+		}
+		s += "commit " + c + ", "
+	}
+	if b.Date != "" {
+		s += "built " + b.Date + ", "
+	}
+	return s + fmt.Sprintf("%s %s/%s)", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
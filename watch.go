@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// watchSettle is how long a new file must go without changes before it is
+// considered completely written.
+const watchSettle = 2 * time.Second
+
+// watchFlags are the watch command's flag values.
+type watchFlags struct {
+	promptFile  string
+	vocabFile   string
+	rulesFile   string
+	moveDone    bool
+	noCache     bool
+	metricsAddr string
+	dbPath      string
+	opts        options
+	g           globalFlags
+}
+
+// flagSet returns the watch command's flags, bound to v.
+func (v *watchFlags) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	v.g.register(fs)
+	fs.StringVar(&v.opts.Model, "m", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Model, "model", defaultModel, "Gemini model to use")
+	fs.StringVar(&v.opts.Prompt, "p", "", "Custom prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.opts.Prompt, "prompt", "", "Custom prompt (or set GEMINI_PROMPT)")
+	fs.StringVar(&v.promptFile, "prompt-file", "", "Read the prompt from a file (or set GEMINI_PROMPT_FILE)")
+	fs.StringVar(&v.rulesFile, "rules", "", "YAML file of find/replace rules (plain or regex) applied to the transcript")
+	fs.StringVar(&v.vocabFile, "vocab", "", "File of names and terms, one per line, to spell exactly (added to the prompt and corrected afterwards)")
+	fs.StringVar(&v.opts.format, "f", "text", "Output format: text, json, srt, vtt, md, whisper-json, chapters")
+	fs.StringVar(&v.opts.format, "format", "text", "Output format: text, json, srt, vtt, md, whisper-json, chapters")
+	fs.StringVar(&v.opts.output, "o", "", "Write transcripts to this directory instead of next to the inputs")
+	fs.StringVar(&v.opts.output, "output", "", "Write transcripts to this directory instead of next to the inputs")
+	fs.BoolVar(&v.moveDone, "done", false, "Move processed inputs into a done/ folder inside the watched directory")
+	fs.BoolVar(&v.noCache, "no-cache", false, "Always call the API instead of reusing a cached transcript of the same audio")
+	fs.StringVar(&v.dbPath, "db", "", "Also store each transcript in this SQLite database, for the search command (needs sqlite3)")
+	fs.StringVar(&v.metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address (e.g. :9090)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gemini-transcribe watch [options] <dir>\n\n")
+		fmt.Fprintf(os.Stderr, "Transcribes media files as they appear in dir, writing a transcript next to each.\n")
+		fmt.Fprintf(os.Stderr, "Files already in dir without a transcript are picked up at startup.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runWatch implements the watch subcommand.
+func runWatch(args []string) {
+	var v watchFlags
+	fs := v.flagSet()
+	fs.Parse(args)
+	opts, g := v.opts, v.g
+	g.initLogging(true)
+	opts.verbose = g.verbose
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	dir := fs.Arg(0)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		errorf("Error: %s is not a directory", dir)
+		os.Exit(1)
+	}
+
+	if opts.Prompt == "" {
+		var err error
+		opts.Prompt, err = resolvePrompt(v.promptFile)
+		if err != nil {
+			errorf("Error reading prompt: %v", err)
+			os.Exit(1)
+		}
+	}
+	if v.vocabFile != "" {
+		var err error
+		if opts.Vocabulary, err = readVocabulary(v.vocabFile); err != nil {
+			errorf("Error reading vocabulary: %v", err)
+			os.Exit(1)
+		}
+	}
+	if v.rulesFile != "" {
+		var err error
+		if opts.Rules, err = loadRules(v.rulesFile); err != nil {
+			errorf("Error reading rules: %v", err)
+			os.Exit(1)
+		}
+	}
+	switch opts.format {
+	case "text", "json", "srt", "vtt", "md", "whisper-json", "chapters":
+	default:
+		errorf("Error: Unknown format %q (want text, json, srt, vtt, md, whisper-json or chapters)", opts.format)
+		os.Exit(1)
+	}
+	if opts.output != "" {
+		if err := os.MkdirAll(opts.output, 0755); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+	opts.Timestamps = opts.format != "text"
+	opts.DetectLanguage = hasLanguage(opts.format)
+	opts.Topics = opts.format == "md" || opts.format == "chapters"
+	opts.Upload = "auto"
+	opts.ChunkDuration = transcribe.DefaultChunkDuration
+	opts.ChunkOverlap = transcribe.DefaultChunkOverlap
+	opts.MaxContinuations = transcribe.DefaultMaxContinuations
+
+	client := g.newClient()
+	if !v.noCache {
+		client.Cache = newCache()
+	}
+	if opts.verbose {
+		client.Logf = debugf
+	}
+
+	if v.dbPath != "" {
+		var err error
+		if opts.db, err = openTranscriptDB(v.dbPath); err != nil {
+			errorf("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	var m *metrics
+	if v.metricsAddr != "" {
+		m = newMetrics()
+		go serveMetrics(v.metricsAddr, m)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := watchDir(ctx, client, dir, v.moveDone, opts, m); err != nil {
+		errorf("Error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// watchDir transcribes media files in dir that have no transcript yet, then
+// every file created in it, until ctx is cancelled. A file is picked up once
+// it has stopped changing for watchSettle. Each one is recorded in m.
+func watchDir(ctx context.Context, client *transcribe.Client, dir string, moveDone bool, opts options, m *metrics) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		return err
+	}
+
+	pending := map[string]time.Time{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if watchable(path) {
+			if _, err := os.Stat(batchOutputPath(path, opts.output, opts.format)); errors.Is(err, os.ErrNotExist) {
+				pending[path] = time.Time{}
+			}
+		}
+	}
+
+	infof("Watching %s for new media files", dir)
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.Errors:
+			errorf("Watch error: %v", err)
+		case ev := <-w.Events:
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+				if watchable(ev.Name) {
+					pending[ev.Name] = time.Now()
+				}
+			}
+		case <-tick.C:
+			for path, last := range pending {
+				if time.Since(last) < watchSettle {
+					continue
+				}
+				delete(pending, path)
+				if info, err := os.Stat(path); err == nil && !info.IsDir() {
+					watchItem(ctx, client, dir, path, moveDone, opts, m)
+				}
+			}
+		}
+	}
+}
+
+// watchable reports whether a path looks like a finished media file rather
+// than a partial download or hidden file.
+func watchable(path string) bool {
+	name := filepath.Base(path)
+	return !strings.HasPrefix(name, ".") && mediaExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// watchItem transcribes one file, writes its transcript and optionally
+// moves the input into dir/done.
+func watchItem(ctx context.Context, client *transcribe.Client, dir, path string, moveDone bool, opts options, m *metrics) {
+	start := time.Now()
+	fileClient := *client
+	fileClient.Stats = transcribe.NewStats()
+	result, err := transcribeFile(ctx, &fileClient, path, opts)
+	snap := fileClient.Stats.Snapshot()
+	client.Stats.Merge(snap)
+	m.observe("watch", opts.Model, result.Duration, snap, time.Since(start), err)
+	if err != nil && !errors.Is(err, transcribe.ErrNoSpeech) {
+		errorf("%s: Error %v", path, err)
+		return
+	}
+
+	outPath := batchOutputPath(path, opts.output, opts.format)
+	if err := os.WriteFile(outPath, []byte(renderResult(result, opts)), 0644); err != nil {
+		errorf("%s: Error writing output: %v", path, err)
+		return
+	}
+	infof("%s -> %s (%s)", path, outPath, time.Since(start).Round(time.Millisecond))
+	recordTranscript(opts, result)
+
+	if moveDone {
+		doneDir := filepath.Join(dir, "done")
+		if err := os.MkdirAll(doneDir, 0755); err != nil {
+			errorf("%s: Error %v", path, err)
+			return
+		}
+		if err := os.Rename(path, filepath.Join(doneDir, filepath.Base(path))); err != nil {
+			errorf("%s: Error moving to done/: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mukhtharcm/gemini-transcribe/pkg/transcribe"
+)
+
+// webhookTimeout bounds each --webhook delivery, so a slow receiver can't
+// hold up a batch.
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload is what --webhook POSTs when a file finishes or fails.
+// Text is a one-line summary, which is all Slack's incoming webhooks show.
+type webhookPayload struct {
+	Event         string     `json:"event"`
+	Status        string     `json:"status"`
+	File          string     `json:"file"`
+	Output        string     `json:"output,omitempty"`
+	Transcription string     `json:"transcription,omitempty"`
+	Model         string     `json:"model,omitempty"`
+	Duration      float64    `json:"duration,omitempty"`
+	Elapsed       float64    `json:"elapsed_seconds"`
+	Usage         *usageJSON `json:"usage,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	Version       string     `json:"version"`
+	Text          string     `json:"text"`
+}
+
+// newWebhookPayload describes the outcome for one file: its output
+// location when it was written to one, otherwise the transcript itself.
+// The usage always carries the estimated cost.
+func newWebhookPayload(result jsonResult, outPath string, snap transcribe.StatsSnapshot, elapsed time.Duration, err error) webhookPayload {
+	p := webhookPayload{
+		Event:    "transcription.completed",
+		Status:   "done",
+		File:     result.File,
+		Output:   outPath,
+		Model:    result.Model,
+		Duration: result.Duration,
+		Elapsed:  elapsed.Round(time.Millisecond).Seconds(),
+		Usage:    usageFor(result.Model, snap, true),
+		Version:  currentBuild().Version,
+	}
+	if outPath == "" {
+		p.Transcription = result.Transcription
+	}
+	switch {
+	case errors.Is(err, transcribe.ErrNoSpeech):
+		p.Status = "no_speech"
+		p.Text = fmt.Sprintf("%s: no speech detected", p.File)
+	case err != nil:
+		p.Event, p.Status, p.Error = "transcription.failed", "failed", err.Error()
+		p.Text = fmt.Sprintf("%s: transcription failed: %v", p.File, err)
+	case outPath != "":
+		p.Text = fmt.Sprintf("%s transcribed in %s -> %s", p.File, elapsed.Round(time.Second), outPath)
+	default:
+		p.Text = fmt.Sprintf("%s transcribed in %s", p.File, elapsed.Round(time.Second))
+	}
+	if cost := p.Usage.CostUSD; cost != nil && err == nil {
+		prec := 4
+		if *cost < 0.01 {
+			prec = 6
+		}
+		p.Text += fmt.Sprintf(" (about $%.*f)", prec, *cost)
+	}
+	return p
+}
+
+// sendWebhook POSTs the payload to opts.webhook, when set. Delivery
+// failures are logged and never fail the transcription.
+func sendWebhook(opts options, p webhookPayload) {
+	if opts.webhook == "" {
+		return
+	}
+	if err := postWebhook(opts.webhook, opts.proxy, p); err != nil {
+		warnf("Warning: webhook for %s: %v", p.File, err)
+		return
+	}
+	debugf("Webhook sent for %s (%s)", p.File, p.Status)
+}
+
+func postWebhook(url, proxy string, p webhookPayload) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+	httpClient, err := newHTTPClient(proxy)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gemini-transcribe/"+currentBuild().Version)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
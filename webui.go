@@ -0,0 +1,40 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// webUI is the upload page served at /, for people who'd rather not use
+// curl. It queues the upload with POST /jobs and follows the job.
+//
+//go:embed web/index.html
+var webUI []byte
+
+func handleWebUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Write(webUI)
+}
+
+// handleModels lists the models that accept audio, for the web UI's model
+// picker, along with the server's default. When listing fails only the
+// default is offered.
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		Default string   `json:"default"`
+		Models  []string `json:"models"`
+	}{Default: s.defaults.Model, Models: []string{}}
+	models, err := s.clientFor(r.Context()).ListModels(r.Context())
+	if err != nil {
+		warnf("Warning: listing models: %v", err)
+	}
+	for _, m := range models {
+		if m.AcceptsAudio() {
+			resp.Models = append(resp.Models, m.ID())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
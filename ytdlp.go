@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mukhtharcm/gemini-transcribe/internal/proc"
+)
+
+// fetchWithYtDlp downloads the best audio stream of an online video with
+// yt-dlp. The audio goes to a temp directory that cleanup removes, or to the
+// current directory when keep is set, in which case cleanup does nothing.
+// proxy, when set, is passed on to yt-dlp.
+func fetchWithYtDlp(ctx context.Context, url string, keep bool, proxy string) (path string, cleanup func(), err error) {
+	if _, err := exec.LookPath("yt-dlp"); err != nil {
+		return "", nil, errors.New("--from-url requires yt-dlp (https://github.com/yt-dlp/yt-dlp)")
+	}
+
+	dir := "."
+	cleanup = func() {}
+	if !keep {
+		if dir, err = os.MkdirTemp("", "gemini-transcribe-ytdlp-*"); err != nil {
+			return "", nil, err
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+
+	args := []string{
+		"--no-playlist",
+		"-f", "bestaudio/best",
+		"-P", dir,
+		"-o", "%(title).100B [%(id)s].%(ext)s",
+		"--print", "after_move:filepath",
+	}
+	if proxy != "" {
+		args = append(args, "--proxy", proxy)
+	}
+	cmd := proc.Command(ctx, "yt-dlp", append(args, url)...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	// yt-dlp's progress only belongs on a terminal; otherwise its output is
+	// kept for the error message
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if showProgress() {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		cleanup()
+		if ctx.Err() != nil {
+			return "", nil, ctx.Err()
+		}
+		if msg := lastLine(stderr.String()); msg != "" {
+			return "", nil, fmt.Errorf("yt-dlp failed: %v: %s", err, msg)
+		}
+		return "", nil, fmt.Errorf("yt-dlp failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	path = strings.TrimSpace(lines[len(lines)-1])
+	if path == "" {
+		cleanup()
+		return "", nil, errors.New("yt-dlp did not report a downloaded file")
+	}
+	if keep {
+		infof("Saved audio to %s", path)
+	}
+	return path, cleanup, nil
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}